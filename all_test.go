@@ -10,6 +10,7 @@ package gpumaths
 import (
 	"gitlab.com/elixxir/crypto/cyclic"
 	"gitlab.com/xx_network/crypto/large"
+	"math/rand"
 )
 
 func makeTestGroup4096() *cyclic.Group {
@@ -27,3 +28,17 @@ func makeTestGroup2048() *cyclic.Group {
 		large.NewInt(2),
 	)
 }
+
+// Makes a buffer of pseudorandom ints in the group
+func randomTestBuffer(g *cyclic.Group, n uint32, seed int64) *cyclic.IntBuffer {
+	rng := rand.New(rand.NewSource(seed))
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	b := make([]byte, len(g.GetPBytes()))
+	for i := uint32(0); i < n; i++ {
+		rng.Read(b)
+		v := large.NewIntFromBytes(b)
+		v.Mod(v, g.GetP())
+		g.SetLargeInt(result.Get(i), v)
+	}
+	return result
+}
@@ -0,0 +1,212 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// Package client offloads gpumaths operations to a server over gRPC
+// Its methods have the same signatures as the gpumaths operations, so a node
+// without a GPU can use them in place of the local ones, or Install them
+// over the local ones.
+package client
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/elixxir/gpumathsgo/internal/wire"
+	"google.golang.org/grpc"
+	"time"
+)
+
+// DefaultMaxSlotsPerCall keeps a call with four 4096-bit operands well under
+// gRPC's default 4 MiB message limit
+const DefaultMaxSlotsPerCall = 1024
+
+// Client runs operations on a remote server
+// The StreamPool that its methods take is ignored, because the server runs
+// the operations on its own pool. Passing nil is fine.
+type Client struct {
+	conn grpc.ClientConnInterface
+	// Set if the client made the connection, and should close it
+	owned *grpc.ClientConn
+
+	// Larger operations are split into this many slots per call. It can't
+	// be more than wire.MaxSlotsPerCall, the most that servers accept.
+	MaxSlotsPerCall uint32
+	// Each call gets this long to finish, or forever if it's zero
+	Timeout time.Duration
+}
+
+// New makes a client that calls the server on conn
+func New(conn grpc.ClientConnInterface) *Client {
+	return &Client{
+		conn:            conn,
+		MaxSlotsPerCall: DefaultMaxSlotsPerCall,
+	}
+}
+
+// Dial connects to the server at target
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't connect to gpumaths "+
+			"server at %v", target)
+	}
+	c := New(conn)
+	c.owned = conn
+	return c, nil
+}
+
+// Close closes the connection, if the client made it with Dial
+func (c *Client) Close() error {
+	if c.owned == nil {
+		return nil
+	}
+	conn := c.owned
+	c.owned = nil
+	return conn.Close()
+}
+
+// Install replaces the gpumaths operations with the client's, and returns
+// a function that puts the old ones back
+func (c *Client) Install() (restore func()) {
+	exp, elGamal, reveal := gpumaths.ExpChunk, gpumaths.ElGamalChunk,
+		gpumaths.RevealChunk
+	mul2, mul2Slice, mul3 := gpumaths.Mul2Chunk, gpumaths.Mul2Slice,
+		gpumaths.Mul3Chunk
+	gpumaths.ExpChunk = c.ExpChunk
+	gpumaths.ElGamalChunk = c.ElGamalChunk
+	gpumaths.RevealChunk = c.RevealChunk
+	gpumaths.Mul2Chunk = c.Mul2Chunk
+	gpumaths.Mul2Slice = c.Mul2Slice
+	gpumaths.Mul3Chunk = c.Mul3Chunk
+	return func() {
+		gpumaths.ExpChunk, gpumaths.ElGamalChunk, gpumaths.RevealChunk =
+			exp, elGamal, reveal
+		gpumaths.Mul2Chunk, gpumaths.Mul2Slice, gpumaths.Mul3Chunk =
+			mul2, mul2Slice, mul3
+	}
+}
+
+// ExpChunk computes z = x**y on the server, and returns z if it's an
+// IntBuffer
+func (c *Client) ExpChunk(p *gpumaths.StreamPool, g *cyclic.Group,
+	x, y, z gpumaths.Operands) (*cyclic.IntBuffer, error) {
+	err := c.run(wire.MethodExp, g, uint32(z.Len()), nil,
+		[]gpumaths.Operands{x, y}, []gpumaths.Operands{z})
+	if err != nil {
+		return nil, err
+	}
+	buf, _ := z.(*cyclic.IntBuffer)
+	return buf, nil
+}
+
+// ElGamalChunk runs ElGamal on the server
+func (c *Client) ElGamalChunk(p *gpumaths.StreamPool, g *cyclic.Group,
+	key, privateKey gpumaths.Operands, publicCypherKey *cyclic.Int,
+	ecrKey, cypher gpumaths.Operands) error {
+	return c.run(wire.MethodElGamal, g, uint32(ecrKey.Len()),
+		[]*cyclic.Int{publicCypherKey},
+		[]gpumaths.Operands{key, privateKey, ecrKey, cypher},
+		[]gpumaths.Operands{ecrKey, cypher})
+}
+
+// RevealChunk runs Reveal on the server
+func (c *Client) RevealChunk(p *gpumaths.StreamPool, g *cyclic.Group,
+	publicCypherKey *cyclic.Int, cypher, result gpumaths.Operands) error {
+	return c.run(wire.MethodReveal, g, uint32(result.Len()),
+		[]*cyclic.Int{publicCypherKey}, []gpumaths.Operands{cypher},
+		[]gpumaths.Operands{result})
+}
+
+// Mul2Chunk computes result = x*y on the server
+func (c *Client) Mul2Chunk(p *gpumaths.StreamPool, g *cyclic.Group,
+	x, y, result gpumaths.Operands) error {
+	return c.run(wire.MethodMul2, g, uint32(result.Len()), nil,
+		[]gpumaths.Operands{x, y}, []gpumaths.Operands{result})
+}
+
+// Mul2Slice runs Mul2Chunk with slices for y and result
+func (c *Client) Mul2Slice(p *gpumaths.StreamPool, g *cyclic.Group,
+	x *cyclic.IntBuffer, y, result []*cyclic.Int) error {
+	return c.Mul2Chunk(p, g, x, gpumaths.IntSlice(y),
+		gpumaths.IntSlice(result))
+}
+
+// Mul3Chunk computes result = x*y*z on the server
+func (c *Client) Mul3Chunk(p *gpumaths.StreamPool, g *cyclic.Group,
+	x, y, z, result gpumaths.Operands) error {
+	return c.run(wire.MethodMul3, g, uint32(result.Len()), nil,
+		[]gpumaths.Operands{x, y, z}, []gpumaths.Operands{result})
+}
+
+// run calls method once per MaxSlotsPerCall slots
+// Every chunk's results are written before the next chunk is sent, so
+// outputs can also be inputs, as they can with the local operations.
+func (c *Client) run(method string, g *cyclic.Group, numSlots uint32,
+	scalars []*cyclic.Int, inputs, outputs []gpumaths.Operands) error {
+	req := &wire.OpRequest{
+		Group:   wire.NewGroup(g),
+		Scalars: make([][]byte, len(scalars)),
+	}
+	for i := range scalars {
+		req.Scalars[i] = scalars[i].Bytes()
+	}
+	maxSlots := c.MaxSlotsPerCall
+	if maxSlots == 0 {
+		maxSlots = DefaultMaxSlotsPerCall
+	} else if maxSlots > wire.MaxSlotsPerCall {
+		maxSlots = wire.MaxSlotsPerCall
+	}
+	for begin := uint32(0); begin < numSlots; begin += maxSlots {
+		n := maxSlots
+		if begin+n > numSlots {
+			n = numSlots - begin
+		}
+		req.NumSlots = n
+		req.Operands = make([][]byte, len(inputs))
+		for i := range inputs {
+			var err error
+			req.Operands[i], err = wire.PackOperands(g, inputs[i], begin, n)
+			if err != nil {
+				return errors.Wrapf(err, "couldn't pack operand %v", i)
+			}
+		}
+		resp, err := c.invoke(method, req)
+		if err != nil {
+			return err
+		}
+		if len(resp.Results) != len(outputs) {
+			return errors.Errorf("%v returned %v results, expected %v",
+				method, len(resp.Results), len(outputs))
+		}
+		for i := range outputs {
+			err = wire.UnpackOperands(g, resp.Results[i], outputs[i], begin,
+				n, wire.GroupElements)
+			if err != nil {
+				return errors.Wrapf(err, "couldn't unpack result %v", i)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) invoke(method string, req *wire.OpRequest) (
+	*wire.OpResponse, error) {
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	resp := new(wire.OpResponse)
+	err := c.conn.Invoke(ctx, wire.FullMethod(method), req, resp,
+		grpc.CallContentSubtype(wire.CodecName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "offloaded %v failed", method)
+	}
+	return resp, nil
+}
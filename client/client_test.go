@@ -0,0 +1,285 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package client
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/elixxir/gpumathsgo/internal/wire"
+	"gitlab.com/elixxir/gpumathsgo/server"
+	"gitlab.com/xx_network/crypto/large"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"net"
+	"testing"
+)
+
+func makeTestGroup() *cyclic.Group {
+	p := large.NewIntFromString("F6FAC7E480EE519354C058BF856AEBDC43AD60141BAD5573910476D030A869979A7E23F5FC006B6CE1B1D7CDA849BDE46A145F80EE97C21AA2154FA3A5CF25C75E225C6F3384D3C0C6BEF5061B87E8D583BEFDF790ECD351F6D2B645E26904DE3F8A9861CC3EAD0AA40BD7C09C1F5F655A9E7BA7986B92B73FD9A6A69F54EFC92AC7E21D15C9B85A76084D1EEFBC4781B91E231E9CE5F007BC75A8656CBD98E282671C08A5400C4E4D039DE5FD63AA89A618C5668256B12672C66082F0348B6204DD0ADE58532C967D055A5D2C34C43DF9998820B5DFC4C49C6820191CB3EC81062AA51E23CEEA9A37AB523B24C0E93B440FDC17A50B219AB0D373014C25EE8F", 16)
+	return cyclic.NewGroup(p, large.NewInt(2))
+}
+
+// Makes a buffer of pseudorandom ints in the group
+func randomBuffer(g *cyclic.Group, n uint32, seed int64) *cyclic.IntBuffer {
+	rng := rand.New(rand.NewSource(seed))
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	b := make([]byte, len(g.GetPBytes()))
+	for i := uint32(0); i < n; i++ {
+		rng.Read(b)
+		v := large.NewIntFromBytes(b)
+		v.Mod(v, g.GetP())
+		g.SetLargeInt(result.Get(i), v)
+	}
+	return result
+}
+
+// Starts a server with the CPU backend on a local port, and connects a
+// client to it
+func startTestServer(t *testing.T) *Client {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer()
+	server.NewWithOps(nil, gpumaths.CPUOps).Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	c, err := Dial(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		err := c.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	return c
+}
+
+// Offloaded operations should give the same results as the local ones, even
+// when they're split across several calls
+func TestClient(t *testing.T) {
+	const n = 10
+	c := startTestServer(t)
+	c.MaxSlotsPerCall = 3
+	g := makeTestGroup()
+	x := randomBuffer(g, n, 1)
+	y := randomBuffer(g, n, 2)
+	z := randomBuffer(g, n, 3)
+
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	_, err := c.ExpChunk(nil, g, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if result.Get(i).Cmp(g.Exp(x.Get(i), y.Get(i), g.NewInt(1))) != 0 {
+			t.Errorf("ExpChunk was wrong in slot %v", i)
+		}
+	}
+
+	err = c.Mul2Chunk(nil, g, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if result.Get(i).Cmp(g.Mul(x.Get(i), y.Get(i), g.NewInt(1))) != 0 {
+			t.Errorf("Mul2Chunk was wrong in slot %v", i)
+		}
+	}
+
+	// Writing over an input should still give the right product
+	expected := z.DeepCopy()
+	for i := uint32(0); i < n; i++ {
+		cryptops.Mul3(g, x.Get(i), y.Get(i), expected.Get(i))
+	}
+	err = c.Mul3Chunk(nil, g, x, y, z, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if z.Get(i).Cmp(expected.Get(i)) != 0 {
+			t.Errorf("Mul3Chunk was wrong in slot %v", i)
+		}
+	}
+
+	publicCypherKey := g.NewInt(65537)
+	ecrKey := randomBuffer(g, n, 4)
+	cypher := randomBuffer(g, n, 5)
+	expectedEcrKey := ecrKey.DeepCopy()
+	expectedCypher := cypher.DeepCopy()
+	for i := uint32(0); i < n; i++ {
+		cryptops.ElGamal(g, x.Get(i), y.Get(i), publicCypherKey,
+			expectedEcrKey.Get(i), expectedCypher.Get(i))
+	}
+	err = c.ElGamalChunk(nil, g, x, y, publicCypherKey, ecrKey, cypher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if ecrKey.Get(i).Cmp(expectedEcrKey.Get(i)) != 0 ||
+			cypher.Get(i).Cmp(expectedCypher.Get(i)) != 0 {
+			t.Errorf("ElGamalChunk was wrong in slot %v", i)
+		}
+	}
+
+	for i := uint32(0); i < n; i++ {
+		cryptops.RootCoprime(g, x.Get(i), publicCypherKey, expected.Get(i))
+	}
+	err = c.RevealChunk(nil, g, publicCypherKey, x, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if result.Get(i).Cmp(expected.Get(i)) != 0 {
+			t.Errorf("RevealChunk was wrong in slot %v", i)
+		}
+	}
+}
+
+// Exponents and private keys can be 0, so the server should take them
+func TestClient_ZeroExponents(t *testing.T) {
+	const n = 3
+	c := startTestServer(t)
+	g := makeTestGroup()
+	x := randomBuffer(g, n, 1)
+	y := randomBuffer(g, n, 2)
+	g.SetUint64(y.Get(1), 0)
+
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	_, err := c.ExpChunk(nil, g, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if result.Get(i).Cmp(g.Exp(x.Get(i), y.Get(i), g.NewInt(1))) != 0 {
+			t.Errorf("ExpChunk was wrong in slot %v", i)
+		}
+	}
+
+	publicCypherKey := g.NewInt(65537)
+	ecrKey := randomBuffer(g, n, 4)
+	cypher := randomBuffer(g, n, 5)
+	expectedEcrKey := ecrKey.DeepCopy()
+	expectedCypher := cypher.DeepCopy()
+	for i := uint32(0); i < n; i++ {
+		cryptops.ElGamal(g, x.Get(i), y.Get(i), publicCypherKey,
+			expectedEcrKey.Get(i), expectedCypher.Get(i))
+	}
+	err = c.ElGamalChunk(nil, g, x, y, publicCypherKey, ecrKey, cypher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if ecrKey.Get(i).Cmp(expectedEcrKey.Get(i)) != 0 ||
+			cypher.Get(i).Cmp(expectedCypher.Get(i)) != 0 {
+			t.Errorf("ElGamalChunk was wrong in slot %v", i)
+		}
+	}
+}
+
+// Installed operations should go to the server, and restoring should put the
+// local ones back
+func TestClient_Install(t *testing.T) {
+	const n = 4
+	c := startTestServer(t)
+	g := makeTestGroup()
+	x := randomBuffer(g, n, 1)
+	y := randomBuffer(g, n, 2)
+
+	restore := c.Install()
+	// Closing the connection makes any call that still reaches the server fail
+	err := c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gpumaths.Mul2Chunk(nil, g, x, y, g.NewIntBuffer(n, g.NewInt(1)))
+	if err == nil {
+		t.Error("installed Mul2Chunk didn't call the server")
+	}
+
+	restore()
+	err = gpumaths.Mul2Chunk(nil, g, x, y, g.NewIntBuffer(n, g.NewInt(1)))
+	if err != nil {
+		t.Errorf("restored Mul2Chunk failed: %v", err)
+	}
+}
+
+// The server should reject malformed requests
+func TestClient_BadRequest(t *testing.T) {
+	c := startTestServer(t)
+	g := makeTestGroup()
+	x := randomBuffer(g, 2, 1)
+
+	// Reveal needs a key, so leaving it out should be caught by the server
+	err := c.run("Reveal", g, 2, nil, []gpumaths.Operands{x},
+		[]gpumaths.Operands{x})
+	if status.Code(errors.Cause(err)) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+// Requests that would make the server panic or allocate more than they send
+// should be rejected too
+func TestClient_InvalidValues(t *testing.T) {
+	c := startTestServer(t)
+	g := makeTestGroup()
+	group := wire.NewGroup(g)
+	packed, err := wire.PackOperands(g, randomBuffer(g, 2, 1), 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero := make([]byte, wire.IntLen(g))
+	withZero := append(append([]byte{}, packed[:wire.IntLen(g)]...), zero...)
+	// An odd prime that's one bit longer than the limit
+	tooBig := make([]byte, wire.MaxPrimeBits/8+1)
+	tooBig[0] = 1
+	tooBig[len(tooBig)-1] = 1
+	// Otherwise valid for that prime, so only its length is wrong
+	tooBigOperand := make([]byte, len(tooBig))
+	tooBigOperand[len(tooBigOperand)-1] = 5
+	requests := map[string]*wire.OpRequest{
+		"zero scalar": {Group: group, NumSlots: 2,
+			Operands: [][]byte{packed}, Scalars: [][]byte{zero}},
+		"empty scalar": {Group: group, NumSlots: 2,
+			Operands: [][]byte{packed}, Scalars: [][]byte{{}}},
+		"scalar equal to p": {Group: group, NumSlots: 2,
+			Operands: [][]byte{packed}, Scalars: [][]byte{g.GetPBytes()}},
+		"zero operand": {Group: group, NumSlots: 2,
+			Operands: [][]byte{withZero}, Scalars: [][]byte{{2}}},
+		"too many slots": {Group: group, NumSlots: 1 << 31,
+			Operands: [][]byte{packed}, Scalars: [][]byte{{2}}},
+		"even prime": {Group: wire.Group{P: []byte{8}, G: []byte{3}},
+			NumSlots: 1, Operands: [][]byte{{5}}, Scalars: [][]byte{{3}}},
+		"prime of 1": {Group: wire.Group{P: []byte{1}, G: []byte{3}},
+			NumSlots: 1, Operands: [][]byte{{0}}, Scalars: [][]byte{{0}}},
+		"generator of 1": {Group: wire.Group{P: []byte{7}, G: []byte{1}},
+			NumSlots: 1, Operands: [][]byte{{5}}, Scalars: [][]byte{{3}}},
+		"generator past p": {Group: wire.Group{P: []byte{7}, G: []byte{9}},
+			NumSlots: 1, Operands: [][]byte{{5}}, Scalars: [][]byte{{3}}},
+		"prime too big": {Group: wire.Group{P: tooBig, G: []byte{3}},
+			NumSlots: 1, Operands: [][]byte{tooBigOperand},
+			Scalars: [][]byte{{3}}},
+	}
+	for name, req := range requests {
+		_, err := c.invoke(wire.MethodReveal, req)
+		if status.Code(errors.Cause(err)) != codes.InvalidArgument {
+			t.Errorf("%v: expected InvalidArgument, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/elixxir/gpumathsgo/client"
+	"gitlab.com/xx_network/crypto/large"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"io"
+	"os"
+	"strings"
+)
+
+// batch.go runs one op on operands from files, for offline analysis and
+// reproducing incidents without writing Go.
+
+// batchOp describes an op's operands and how to run it
+type batchOp struct {
+	// Per-slot operands, in the order they're passed with -in
+	inputs []string
+	// Inputs that are exponents, which can be 0
+	exponents map[string]bool
+	// Values that apply to every slot. They follow the group in the group
+	// file.
+	scalars []string
+	// Per-slot results, in the order they're written with -out
+	outputs []string
+	run     func(p *gpumaths.StreamPool, g *cyclic.Group,
+		in []*cyclic.IntBuffer, scalars []*cyclic.Int) ([]*cyclic.IntBuffer,
+		error)
+}
+
+var batchOps = map[string]batchOp{
+	"exp": {
+		inputs:    []string{"x", "y"},
+		exponents: map[string]bool{"y": true},
+		outputs:   []string{"z"},
+		run: func(p *gpumaths.StreamPool, g *cyclic.Group,
+			in []*cyclic.IntBuffer, _ []*cyclic.Int) ([]*cyclic.IntBuffer, error) {
+			z := newBuffer(g, in[0])
+			_, err := gpumaths.ExpChunk(p, g, in[0], in[1], z)
+			return []*cyclic.IntBuffer{z}, err
+		},
+	},
+	"mul2": {
+		inputs:  []string{"x", "y"},
+		outputs: []string{"result"},
+		run: func(p *gpumaths.StreamPool, g *cyclic.Group,
+			in []*cyclic.IntBuffer, _ []*cyclic.Int) ([]*cyclic.IntBuffer, error) {
+			result := newBuffer(g, in[0])
+			err := gpumaths.Mul2Chunk(p, g, in[0], in[1], result)
+			return []*cyclic.IntBuffer{result}, err
+		},
+	},
+	"mul3": {
+		inputs:  []string{"x", "y", "z"},
+		outputs: []string{"result"},
+		run: func(p *gpumaths.StreamPool, g *cyclic.Group,
+			in []*cyclic.IntBuffer, _ []*cyclic.Int) ([]*cyclic.IntBuffer, error) {
+			result := newBuffer(g, in[0])
+			err := gpumaths.Mul3Chunk(p, g, in[0], in[1], in[2], result)
+			return []*cyclic.IntBuffer{result}, err
+		},
+	},
+	"elgamal": {
+		inputs:    []string{"key", "privateKey", "ecrKey", "cypher"},
+		exponents: map[string]bool{"privateKey": true},
+		scalars:   []string{"publicCypherKey"},
+		outputs:   []string{"ecrKey", "cypher"},
+		run: func(p *gpumaths.StreamPool, g *cyclic.Group,
+			in []*cyclic.IntBuffer, scalars []*cyclic.Int) ([]*cyclic.IntBuffer, error) {
+			// ElGamal updates ecrKey and cypher in place
+			err := gpumaths.ElGamalChunk(p, g, in[0], in[1], scalars[0], in[2],
+				in[3])
+			return []*cyclic.IntBuffer{in[2], in[3]}, err
+		},
+	},
+	"reveal": {
+		inputs:  []string{"cypher"},
+		scalars: []string{"publicCypherKey"},
+		outputs: []string{"result"},
+		run: func(p *gpumaths.StreamPool, g *cyclic.Group,
+			in []*cyclic.IntBuffer, scalars []*cyclic.Int) ([]*cyclic.IntBuffer, error) {
+			result := newBuffer(g, in[0])
+			err := gpumaths.RevealChunk(p, g, scalars[0], in[0], result)
+			return []*cyclic.IntBuffer{result}, err
+		},
+	},
+}
+
+func newBuffer(g *cyclic.Group, like *cyclic.IntBuffer) *cyclic.IntBuffer {
+	return g.NewIntBuffer(uint32(like.Len()), g.NewInt(1))
+}
+
+func runBatch(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	opName := flags.String("op", "", "op to run: exp, mul2, mul3, elgamal "+
+		"or reveal")
+	formatName := flags.String("format", string(formatHex), "format of "+
+		"every file: hex, base64, raw or jsonl")
+	groupPath := flags.String("group", "", "file with the group's prime "+
+		"and generator, then the op's scalars (publicCypherKey for elgamal "+
+		"and reveal). In jsonl, one line with p, g and the scalars' names.")
+	in := flags.String("in", "", "comma-separated operand files in the op's "+
+		"order (exp and mul2: x,y; mul3: x,y,z; elgamal: key,privateKey,"+
+		"ecrKey,cypher; reveal: cypher). In jsonl, one file with every "+
+		"operand on each line.")
+	outPaths := flags.String("out", "", "comma-separated result files "+
+		"(elgamal: ecrKey,cypher; others: one file). In jsonl, one file.")
+	configPath := flags.String("config", "", "gpumaths config file for the "+
+		"local stream pool")
+	remote := addRemoteFlags(flags, "run the op on")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	op, ok := batchOps[strings.ToLower(*opName)]
+	if !ok {
+		return errors.Errorf("unknown op %q", *opName)
+	}
+	f, err := parseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	if *groupPath == "" || *in == "" || *outPaths == "" {
+		return errors.New("-group, -in and -out are required")
+	}
+
+	g, scalars, err := readGroup(*groupPath, f, op.scalars)
+	if err != nil {
+		return err
+	}
+	inputs, err := readOperands(g, splitPaths(*in), f, op.inputs,
+		op.exponents)
+	if err != nil {
+		return err
+	}
+
+	p, cleanup, err := openBackend(primeBits(g), *configPath, remote)
+	if err != nil {
+		return err
+	}
+	results, err := op.run(p, g, inputs, scalars)
+	cleanup()
+	if err != nil {
+		return errors.Wrapf(err, "%v failed", *opName)
+	}
+	err = writeOperands(g, splitPaths(*outPaths), f, op.outputs, results)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Ran %v on %v slots\n", *opName, results[0].Len())
+	return nil
+}
+
+func splitPaths(s string) []string {
+	var result []string
+	for _, path := range strings.Split(s, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+// readGroup reads the group and the op's scalars
+func readGroup(path string, f format, scalarNames []string) (*cyclic.Group,
+	[]*cyclic.Int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	names := append([]string{"p", "g"}, scalarNames...)
+	var values [][]byte
+	if f == formatJSONL {
+		columns, err := readRecords(file, names)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "couldn't read group")
+		}
+		if len(columns["p"]) != 1 {
+			return nil, nil, errors.Errorf("group file has %v lines, "+
+				"expected 1", len(columns["p"]))
+		}
+		for _, name := range names {
+			values = append(values, columns[name][0])
+		}
+	} else {
+		values, err = readValues(file, f, 0, len(names))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "couldn't read group")
+		}
+		if len(values) != len(names) {
+			return nil, nil, errors.Errorf("group file has %v values, "+
+				"expected %v (%v)", len(values), len(names),
+				strings.Join(names, ", "))
+		}
+	}
+
+	g := cyclic.NewGroup(large.NewIntFromBytes(values[0]),
+		large.NewIntFromBytes(values[1]))
+	scalars := make([]*cyclic.Int, len(scalarNames))
+	for i := range scalars {
+		scalars[i], err = newInt(g, values[i+2], false)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, scalarNames[i])
+		}
+	}
+	return g, scalars, nil
+}
+
+// readOperands reads the op's per-slot operands
+// The ones named in exponents can be 0.
+func readOperands(g *cyclic.Group, paths []string, f format,
+	names []string, exponents map[string]bool) ([]*cyclic.IntBuffer, error) {
+	columns := make([][][]byte, len(names))
+	if f == formatJSONL {
+		if len(paths) != 1 {
+			return nil, errors.New("jsonl operands must be in one file")
+		}
+		file, err := os.Open(paths[0])
+		if err != nil {
+			return nil, err
+		}
+		records, err := readRecords(file, names)
+		file.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't read %v", paths[0])
+		}
+		for i, name := range names {
+			columns[i] = records[name]
+		}
+	} else {
+		if len(paths) != len(names) {
+			return nil, errors.Errorf("got %v operand files, expected %v "+
+				"(%v)", len(paths), len(names), strings.Join(names, ", "))
+		}
+		for i, path := range paths {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			columns[i], err = readValues(file, f, len(g.GetPBytes()), 0)
+			file.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "couldn't read %v", path)
+			}
+		}
+	}
+
+	buffers := make([]*cyclic.IntBuffer, len(names))
+	for i, column := range columns {
+		if len(column) != len(columns[0]) {
+			return nil, errors.Errorf("%v has %v slots, but %v has %v",
+				names[i], len(column), names[0], len(columns[0]))
+		}
+		buffers[i] = g.NewIntBuffer(uint32(len(column)), g.NewInt(1))
+		for slot, value := range column {
+			v, err := newInt(g, value, exponents[names[i]])
+			if err != nil {
+				return nil, errors.Wrapf(err, "slot %v of %v", slot, names[i])
+			}
+			g.Set(buffers[i].Get(uint32(slot)), v)
+		}
+	}
+	return buffers, nil
+}
+
+// newInt makes a group member from big-endian bytes, or an exponent, which
+// can also be 0
+func newInt(g *cyclic.Group, value []byte, exponent bool) (*cyclic.Int,
+	error) {
+	v := large.NewIntFromBytes(value)
+	if v.Cmp(g.GetP()) >= 0 {
+		return nil, errors.New("value isn't less than the group's prime")
+	}
+	if v.BitLen() == 0 {
+		if !exponent {
+			return nil, errors.New("value isn't between 0 and the group's " +
+				"prime")
+		}
+		// 0 isn't in the group, so NewIntFromLargeInt would panic
+		return g.SetUint64(g.NewInt(1), 0), nil
+	}
+	return g.NewIntFromLargeInt(v), nil
+}
+
+// writeOperands writes the op's results
+func writeOperands(g *cyclic.Group, paths []string, f format,
+	names []string, results []*cyclic.IntBuffer) error {
+	columns := make([][][]byte, len(results))
+	for i, result := range results {
+		columns[i] = make([][]byte, result.Len())
+		for slot := range columns[i] {
+			columns[i][slot] = result.Get(uint32(slot)).Bytes()
+		}
+	}
+
+	if f == formatJSONL {
+		if len(paths) != 1 {
+			return errors.New("jsonl results must go in one file")
+		}
+		return writeFile(paths[0], func(w io.Writer) error {
+			return writeRecords(w, names, columns)
+		})
+	}
+	if len(paths) != len(names) {
+		return errors.Errorf("got %v result files, expected %v (%v)",
+			len(paths), len(names), strings.Join(names, ", "))
+	}
+	for i, path := range paths {
+		err := writeFile(path, func(w io.Writer) error {
+			return writeValues(w, f, len(g.GetPBytes()), columns[i])
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	closeErr := file.Close()
+	if err != nil {
+		return errors.Wrapf(err, "couldn't write %v", path)
+	}
+	return closeErr
+}
+
+// remoteOptions are the flags for connecting to a gpumaths server
+type remoteOptions struct {
+	addr     *string
+	ca       *string
+	insecure *bool
+}
+
+func addRemoteFlags(flags *flag.FlagSet, action string) remoteOptions {
+	return remoteOptions{
+		addr: flags.String("remote", "", "address of a gpumaths server to "+
+			action+", instead of the local backend"),
+		ca: flags.String("ca", "", "PEM file with the CA certificates for "+
+			"the -remote server's TLS certificate. Without it, the "+
+			"system's CAs are used."),
+		insecure: flags.Bool("insecure", false, "connect to -remote "+
+			"without TLS, which sends every operand, including ElGamal "+
+			"private keys, in cleartext"),
+	}
+}
+
+// credentials returns the transport credentials for the remote server
+// Connections use TLS unless -insecure is passed.
+func (o remoteOptions) credentials() (credentials.TransportCredentials,
+	error) {
+	if *o.insecure {
+		if *o.ca != "" {
+			return nil, errors.New("-ca and -insecure can't be used together")
+		}
+		return insecure.NewCredentials(), nil
+	}
+	if *o.ca != "" {
+		return credentials.NewClientTLSFromFile(*o.ca, "")
+	}
+	return credentials.NewTLS(&tls.Config{}), nil
+}
+
+// openBackend gets ready to run ops on a remote server, if one is given, or
+// the best local backend, with streams for primes of up to bits bits
+// The returned cleanup function closes whatever was opened.
+func openBackend(bits int, configPath string, remote remoteOptions) (
+	*gpumaths.StreamPool, func(), error) {
+	if *remote.addr != "" {
+		creds, err := remote.credentials()
+		if err != nil {
+			return nil, nil, err
+		}
+		c, err := client.Dial(*remote.addr,
+			grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, nil, err
+		}
+		restore := c.Install()
+		return nil, func() {
+			restore()
+			c.Close()
+		}, nil
+	}
+
+	c := gpumaths.DefaultConfig()
+	var err error
+	if configPath != "" {
+		c, err = gpumaths.LoadConfig(configPath)
+	} else {
+		// One stream is enough to run one op at a time
+		c.NumStreams = 1
+		c.PrimeBits = bits
+		err = c.ApplyEnv()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := gpumaths.NewStreamPoolFromConfig(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, func() {
+		if p != nil {
+			p.Destroy()
+		}
+	}, nil
+}
+
+// primeBits is the smallest environment that fits g's prime
+func primeBits(g *cyclic.Group) int {
+	for _, bits := range []int{2048, 3200} {
+		if g.GetP().BitLen() <= bits {
+			return bits
+		}
+	}
+	return 4096
+}
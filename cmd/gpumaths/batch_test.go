@@ -0,0 +1,276 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"bytes"
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeTestGroup() *cyclic.Group {
+	p := large.NewIntFromString("F6FAC7E480EE519354C058BF856AEBDC43AD60141BAD5573910476D030A869979A7E23F5FC006B6CE1B1D7CDA849BDE46A145F80EE97C21AA2154FA3A5CF25C75E225C6F3384D3C0C6BEF5061B87E8D583BEFDF790ECD351F6D2B645E26904DE3F8A9861CC3EAD0AA40BD7C09C1F5F655A9E7BA7986B92B73FD9A6A69F54EFC92AC7E21D15C9B85A76084D1EEFBC4781B91E231E9CE5F007BC75A8656CBD98E282671C08A5400C4E4D039DE5FD63AA89A618C5668256B12672C66082F0348B6204DD0ADE58532C967D055A5D2C34C43DF9998820B5DFC4C49C6820191CB3EC81062AA51E23CEEA9A37AB523B24C0E93B440FDC17A50B219AB0D373014C25EE8F", 16)
+	return cyclic.NewGroup(p, large.NewInt(2))
+}
+
+// Makes n pseudorandom members of the group. Every fourth one is small, so
+// the files have values that need padding.
+func randomValues(g *cyclic.Group, n int, seed int64) [][]byte {
+	rng := rand.New(rand.NewSource(seed))
+	values := make([][]byte, n)
+	for i := range values {
+		b := make([]byte, len(g.GetPBytes()))
+		if i%4 == 0 {
+			b = b[:3]
+		}
+		rng.Read(b)
+		v := large.NewIntFromBytes(b)
+		values[i] = v.Mod(v, g.GetP()).Bytes()
+	}
+	return values
+}
+
+func writeTestFile(t *testing.T, path string, write func(w io.Writer) error) {
+	err := writeFile(path, write)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestValues(t *testing.T, g *cyclic.Group, path string,
+	f format) []*cyclic.Int {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	values, err := readValues(file, f, len(g.GetPBytes()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make([]*cyclic.Int, len(values))
+	for i := range values {
+		result[i] = g.NewIntFromBytes(values[i])
+	}
+	return result
+}
+
+// Every format should round trip, and the results should match the CPU
+func TestRunBatch_Mul2(t *testing.T) {
+	const n = 9
+	g := makeTestGroup()
+	x := randomValues(g, n, 1)
+	y := randomValues(g, n, 2)
+	width := len(g.GetPBytes())
+	for _, f := range []format{formatHex, formatBase64, formatRaw} {
+		dir := t.TempDir()
+		groupPath := filepath.Join(dir, "group")
+		xPath := filepath.Join(dir, "x")
+		yPath := filepath.Join(dir, "y")
+		outPath := filepath.Join(dir, "out")
+		writeTestFile(t, groupPath, func(w io.Writer) error {
+			return writeValues(w, f, width, [][]byte{g.GetPBytes(),
+				g.GetG().Bytes()})
+		})
+		writeTestFile(t, xPath, func(w io.Writer) error {
+			return writeValues(w, f, width, x)
+		})
+		writeTestFile(t, yPath, func(w io.Writer) error {
+			return writeValues(w, f, width, y)
+		})
+
+		var out bytes.Buffer
+		err := runBatch([]string{"-op", "mul2", "-format", string(f),
+			"-group", groupPath, "-in", xPath + "," + yPath,
+			"-out", outPath}, &out)
+		if err != nil {
+			t.Fatalf("%v: %v", f, err)
+		}
+		results := readTestValues(t, g, outPath, f)
+		if len(results) != n {
+			t.Fatalf("%v: got %v results, expected %v", f, len(results), n)
+		}
+		for i := range results {
+			expected := g.Mul(g.NewIntFromBytes(x[i]), g.NewIntFromBytes(y[i]),
+				g.NewInt(1))
+			if results[i].Cmp(expected) != 0 {
+				t.Errorf("%v: result was wrong in slot %v", f, i)
+			}
+		}
+	}
+}
+
+// Exponents and private keys can be 0, unlike the other operands
+func TestRunBatch_ZeroExponents(t *testing.T) {
+	const n = 3
+	g := makeTestGroup()
+	x := randomValues(g, n, 1)
+	y := randomValues(g, n, 2)
+	y[1] = []byte{0}
+	dir := t.TempDir()
+	groupPath := filepath.Join(dir, "group")
+	keyedGroupPath := filepath.Join(dir, "keyedGroup")
+	xPath := filepath.Join(dir, "x")
+	yPath := filepath.Join(dir, "y")
+	writeTestFile(t, groupPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, [][]byte{g.GetPBytes(),
+			g.GetG().Bytes()})
+	})
+	writeTestFile(t, keyedGroupPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, [][]byte{g.GetPBytes(),
+			g.GetG().Bytes(), {3}})
+	})
+	writeTestFile(t, xPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, x)
+	})
+	writeTestFile(t, yPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, y)
+	})
+
+	zPath := filepath.Join(dir, "z")
+	err := runBatch([]string{"-op", "exp", "-group", groupPath, "-in",
+		xPath + "," + yPath, "-out", zPath}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := readTestValues(t, g, zPath, formatHex)
+	if z[1].Cmp(g.NewInt(1)) != 0 {
+		t.Errorf("x**0 was %v, expected 1", z[1].Text(16))
+	}
+
+	// ElGamal with a private key of 0 multiplies ecrKey by key and leaves
+	// cypher alone
+	ecrKeyPath := filepath.Join(dir, "ecrKey")
+	cypherPath := filepath.Join(dir, "cypher")
+	err = runBatch([]string{"-op", "elgamal", "-group", keyedGroupPath, "-in",
+		xPath + "," + yPath + "," + xPath + "," + xPath, "-out",
+		ecrKeyPath + "," + cypherPath}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := g.NewIntFromBytes(x[1])
+	ecrKey := readTestValues(t, g, ecrKeyPath, formatHex)
+	cypher := readTestValues(t, g, cypherPath, formatHex)
+	if ecrKey[1].Cmp(g.Mul(key, key, g.NewInt(1))) != 0 ||
+		cypher[1].Cmp(key) != 0 {
+		t.Error("ElGamal with a private key of 0 was wrong")
+	}
+}
+
+// JSON lines files have every operand on one line
+func TestRunBatch_ElGamalJSONL(t *testing.T) {
+	const n = 5
+	g := makeTestGroup()
+	publicCypherKey := g.NewInt(65537)
+	names := []string{"key", "privateKey", "ecrKey", "cypher"}
+	columns := make([][][]byte, len(names))
+	for i := range columns {
+		columns[i] = randomValues(g, n, int64(i))
+	}
+	dir := t.TempDir()
+	groupPath := filepath.Join(dir, "group.jsonl")
+	inPath := filepath.Join(dir, "in.jsonl")
+	outPath := filepath.Join(dir, "out.jsonl")
+	writeTestFile(t, groupPath, func(w io.Writer) error {
+		return writeRecords(w, []string{"p", "g", "publicCypherKey"},
+			[][][]byte{{g.GetPBytes()}, {g.GetG().Bytes()},
+				{publicCypherKey.Bytes()}})
+	})
+	writeTestFile(t, inPath, func(w io.Writer) error {
+		return writeRecords(w, names, columns)
+	})
+
+	var out bytes.Buffer
+	err := runBatch([]string{"-op", "elgamal", "-format", "jsonl",
+		"-group", groupPath, "-in", inPath, "-out", outPath}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	results, err := readRecords(file, []string{"ecrKey", "cypher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		ecrKey := g.NewIntFromBytes(columns[2][i])
+		cypher := g.NewIntFromBytes(columns[3][i])
+		cryptops.ElGamal(g, g.NewIntFromBytes(columns[0][i]),
+			g.NewIntFromBytes(columns[1][i]), publicCypherKey, ecrKey, cypher)
+		if g.NewIntFromBytes(results["ecrKey"][i]).Cmp(ecrKey) != 0 ||
+			g.NewIntFromBytes(results["cypher"][i]).Cmp(cypher) != 0 {
+			t.Errorf("result was wrong in slot %v", i)
+		}
+	}
+}
+
+// Mistakes in the files or flags should be reported
+func TestRunBatch_Errors(t *testing.T) {
+	g := makeTestGroup()
+	dir := t.TempDir()
+	groupPath := filepath.Join(dir, "group")
+	writeTestFile(t, groupPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, [][]byte{g.GetPBytes(),
+			g.GetG().Bytes()})
+	})
+	shortPath := filepath.Join(dir, "short")
+	writeTestFile(t, shortPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, randomValues(g, 2, 1))
+	})
+	longPath := filepath.Join(dir, "long")
+	writeTestFile(t, longPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, randomValues(g, 3, 1))
+	})
+	outOfGroupPath := filepath.Join(dir, "outside")
+	writeTestFile(t, outOfGroupPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, [][]byte{g.GetPBytes(),
+			g.GetPBytes()})
+	})
+	zeroPath := filepath.Join(dir, "zero")
+	writeTestFile(t, zeroPath, func(w io.Writer) error {
+		return writeValues(w, formatHex, 0, [][]byte{{0}, {0}})
+	})
+	out := filepath.Join(dir, "out")
+
+	for name, args := range map[string][]string{
+		"unknown op": {"-op", "div", "-group", groupPath, "-in",
+			shortPath + "," + shortPath, "-out", out},
+		"unknown format": {"-op", "mul2", "-format", "octal", "-group",
+			groupPath, "-in", shortPath + "," + shortPath, "-out", out},
+		"missing key": {"-op", "reveal", "-group", groupPath, "-in",
+			shortPath, "-out", out},
+		"wrong operand count": {"-op", "mul3", "-group", groupPath, "-in",
+			shortPath + "," + shortPath, "-out", out},
+		"mismatched slots": {"-op", "mul2", "-group", groupPath, "-in",
+			shortPath + "," + longPath, "-out", out},
+		"value outside group": {"-op", "mul2", "-group", groupPath, "-in",
+			shortPath + "," + outOfGroupPath, "-out", out},
+		"zero value": {"-op", "mul2", "-group", groupPath, "-in",
+			shortPath + "," + zeroPath, "-out", out},
+		"ca with insecure": {"-op", "mul2", "-group", groupPath, "-in",
+			shortPath + "," + shortPath, "-out", out, "-remote",
+			"127.0.0.1:1", "-insecure", "-ca", groupPath},
+		"missing ca": {"-op", "mul2", "-group", groupPath, "-in",
+			shortPath + "," + shortPath, "-out", out, "-remote",
+			"127.0.0.1:1", "-ca", filepath.Join(dir, "missing.pem")},
+	} {
+		err := runBatch(args, io.Discard)
+		if err == nil {
+			t.Errorf("%v wasn't reported", name)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"gitlab.com/elixxir/gpumathsgo"
+	"io"
+)
+
+// runInfo prints what the build can run, and how many slots fit in the
+// configured stream size
+func runInfo(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("info", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print JSON instead of text")
+	configPath := flags.String("config", "", "gpumaths config file to "+
+		"take the stream size from")
+	memSize := flags.Int("memsize", 0, "stream size in bytes, instead of "+
+		"the configured one")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	size := *memSize
+	if size == 0 {
+		c := gpumaths.DefaultConfig()
+		if *configPath != "" {
+			c, err = gpumaths.LoadConfig(*configPath)
+		} else {
+			err = c.ApplyEnv()
+		}
+		if err != nil {
+			return err
+		}
+		size = c.MemSize()
+	}
+
+	info := gpumaths.GetInfo(size)
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	return info.WriteText(out)
+}
@@ -0,0 +1,53 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"gitlab.com/elixxir/gpumathsgo"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The JSON output should be the same as the Go API's
+func TestRunInfo_JSON(t *testing.T) {
+	var out bytes.Buffer
+	err := runInfo([]string{"-json", "-memsize", "100000"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info gpumaths.Info
+	err = json.Unmarshal(out.Bytes(), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := gpumaths.GetInfo(100000)
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("printed %+v, expected %+v", info, expected)
+	}
+}
+
+func TestRunInfo_Text(t *testing.T) {
+	var out bytes.Buffer
+	err := runInfo(nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Backend:", "ExpChunk"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output didn't have %q:\n%v", expected, out.String())
+		}
+	}
+
+	err = runInfo([]string{"-config", "/nonexistent.yaml"}, &out)
+	if err == nil {
+		t.Error("missing config file wasn't reported")
+	}
+}
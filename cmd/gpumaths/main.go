@@ -0,0 +1,65 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// gpumaths is a tool for inspecting and debugging gpumaths nodes
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// A subcommand gets the arguments after its name
+type command struct {
+	run     func(args []string, out io.Writer) error
+	summary string
+}
+
+var commands = map[string]command{
+	"batch": {runBatch, "run an op on operands from files"},
+	"info": {runInfo, "print the backend, native library, environments " +
+		"and kernel sizes"},
+	"replay": {runReplay, "run a recording of kernel invocations again and " +
+		"compare the results"},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: gpumaths <command> [flags]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8v %v\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(w, "\nRun gpumaths <command> -h for the command's flags.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	err := cmd.run(os.Args[2:], os.Stdout)
+	if err == flag.ErrHelp {
+		// The flag set already printed the command's usage
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "gpumaths %v: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
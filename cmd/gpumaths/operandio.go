@@ -0,0 +1,190 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// operandio.go reads and writes the files that batch jobs use. Every value is
+// a big-endian integer.
+
+// format is how a file stores its values
+type format string
+
+const (
+	// One hex value per line
+	formatHex format = "hex"
+	// One base64 value per line
+	formatBase64 format = "base64"
+	// Values left-padded to the same width and concatenated
+	formatRaw format = "raw"
+	// One JSON object per line, with a hex string for each named value
+	formatJSONL format = "jsonl"
+)
+
+func parseFormat(s string) (format, error) {
+	switch f := format(strings.ToLower(s)); f {
+	case formatHex, formatBase64, formatRaw, formatJSONL:
+		return f, nil
+	default:
+		return "", errors.Errorf("unknown format %q: use hex, base64, raw "+
+			"or jsonl", s)
+	}
+}
+
+// readValues reads a file of values in the hex, base64 or raw format
+// Raw values are width bytes each. If width is zero, the file must hold
+// count values, and they're split evenly.
+func readValues(r io.Reader, f format, width, count int) ([][]byte,
+	error) {
+	if f == formatRaw {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if width == 0 {
+			if count == 0 || len(data)%count != 0 {
+				return nil, errors.Errorf("raw file has %v bytes, which "+
+					"can't be split into %v values", len(data), count)
+			}
+			width = len(data) / count
+		}
+		if len(data)%width != 0 {
+			return nil, errors.Errorf("raw file has %v bytes, which isn't "+
+				"a multiple of the %v-byte value width", len(data), width)
+		}
+		values := make([][]byte, 0, len(data)/width)
+		for i := 0; i < len(data); i += width {
+			values = append(values, data[i:i+width])
+		}
+		return values, nil
+	}
+
+	var values [][]byte
+	scanner := bufio.NewScanner(r)
+	// Lines can be a few thousand bits long
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var value []byte
+		var err error
+		if f == formatHex {
+			value, err = decodeHex(text)
+		} else {
+			value, err = base64.StdEncoding.DecodeString(text)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %v", line)
+		}
+		values = append(values, value)
+	}
+	return values, scanner.Err()
+}
+
+// writeValues writes values in the hex, base64 or raw format, left-padding
+// raw values to width bytes
+func writeValues(w io.Writer, f format, width int, values [][]byte) error {
+	bw := bufio.NewWriter(w)
+	for _, value := range values {
+		var err error
+		switch f {
+		case formatRaw:
+			_, err = bw.Write(leftPad(value, width))
+		case formatHex:
+			_, err = bw.WriteString(hex.EncodeToString(value) + "\n")
+		default:
+			_, err = bw.WriteString(
+				base64.StdEncoding.EncodeToString(value) + "\n")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// readRecords reads a JSON lines file, in which every line has a value for
+// each of names
+// The result has a column of values for each name.
+func readRecords(r io.Reader, names []string) (map[string][][]byte, error) {
+	columns := make(map[string][][]byte, len(names))
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record map[string]string
+		err := json.Unmarshal([]byte(text), &record)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %v", line)
+		}
+		for _, name := range names {
+			encoded, ok := record[name]
+			if !ok {
+				return nil, errors.Errorf("line %v doesn't have %v", line,
+					name)
+			}
+			value, err := decodeHex(encoded)
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %v: %v", line, name)
+			}
+			columns[name] = append(columns[name], value)
+		}
+	}
+	return columns, scanner.Err()
+}
+
+// writeRecords writes a JSON lines file with a value from each column on
+// every line
+func writeRecords(w io.Writer, names []string, columns [][][]byte) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i := range columns[0] {
+		record := make(map[string]string, len(names))
+		for j, name := range names {
+			record[name] = hex.EncodeToString(columns[j][i])
+		}
+		err := enc.Encode(record)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// decodeHex decodes a hex value, with or without a 0x prefix or an even
+// number of digits
+func decodeHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return hex.DecodeString(s)
+}
+
+func leftPad(value []byte, width int) []byte {
+	if len(value) >= width {
+		return value
+	}
+	result := make([]byte, width)
+	copy(result[width-len(value):], value)
+	return result
+}
@@ -0,0 +1,158 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/gpumathsgo"
+	"io"
+	"os"
+)
+
+// replay.go runs a recording from StreamPool.SetRecorder again, and reports
+// the slots whose results differ from what was recorded. It can also copy a
+// recording without its ElGamal private keys, so it can be shared.
+
+// replayRecord is a replay result in the -json output
+type replayRecord struct {
+	Index      int                 `json:"index"`
+	Op         string              `json:"op"`
+	Skipped    string              `json:"skipped,omitempty"`
+	Mismatches map[string][]uint32 `json:"mismatches,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	DurationNs int64               `json:"durationNs"`
+}
+
+func runReplay(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	recording := flags.String("recording", "", "recording to replay")
+	configPath := flags.String("config", "", "gpumaths config file for the "+
+		"local backend")
+	remote := addRemoteFlags(flags, "replay on")
+	bits := flags.Int("bits", 4096, "length of the largest prime in the "+
+		"recording, for sizing streams")
+	asJSON := flags.Bool("json", false, "print a JSON object for each "+
+		"invocation")
+	redactTo := flags.String("redact", "", "instead of replaying, write a "+
+		"copy of the recording without ElGamal private keys to this file")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *recording == "" {
+		return errors.New("-recording is required")
+	}
+
+	file, err := os.Open(*recording)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rr, err := gpumaths.NewRecordingReader(file)
+	if err != nil {
+		return err
+	}
+
+	if *redactTo != "" {
+		return redactRecording(rr, *redactTo, out)
+	}
+
+	p, cleanup, err := openBackend(*bits, *configPath, remote)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	enc := json.NewEncoder(out)
+	var total, matched, mismatched, skipped, failed int
+	for {
+		inv, err := rr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		result := gpumaths.Replay(p, inv)
+		total++
+		switch {
+		case result.Skipped != "":
+			skipped++
+		case result.Err != nil:
+			failed++
+		case len(result.Mismatches) > 0:
+			mismatched++
+		default:
+			matched++
+		}
+
+		if *asJSON {
+			record := replayRecord{
+				Index:      result.Index,
+				Op:         result.Op,
+				Skipped:    result.Skipped,
+				Mismatches: result.Mismatches,
+				DurationNs: int64(result.Duration),
+			}
+			if result.Err != nil {
+				record.Error = result.Err.Error()
+			}
+			err = enc.Encode(record)
+			if err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintln(out, result)
+		}
+	}
+
+	if !*asJSON {
+		fmt.Fprintf(out, "Replayed %v invocations: %v matched, %v "+
+			"mismatched, %v skipped, %v failed\n", total, matched, mismatched,
+			skipped, failed)
+	}
+	if mismatched > 0 || failed > 0 {
+		return errors.Errorf("%v of %v invocations didn't match the "+
+			"recording", mismatched+failed, total)
+	}
+	return nil
+}
+
+// redactRecording copies the invocations from rr to a new recording at path,
+// leaving out ElGamal private keys
+func redactRecording(rr *gpumaths.RecordingReader, path string,
+	out io.Writer) error {
+	r, err := gpumaths.CreateRecording(path, gpumaths.RecordOptions{
+		Redact: gpumaths.RedactElGamalPrivateKeys,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		inv, err := rr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			r.Close()
+			return err
+		}
+		err = r.Write(inv)
+		if err != nil {
+			r.Close()
+			return errors.Wrapf(err, "couldn't write %v", path)
+		}
+	}
+	err = r.Close()
+	if err != nil {
+		return errors.Wrapf(err, "couldn't write %v", path)
+	}
+	fmt.Fprintf(out, "Wrote %v invocations to %v\n", r.Count(), path)
+	return nil
+}
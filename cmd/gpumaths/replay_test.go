@@ -0,0 +1,145 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/elixxir/gpumathsgo"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Packs values the way recordings store them
+func packValues(g *cyclic.Group, values [][]byte) []byte {
+	var result []byte
+	for _, value := range values {
+		result = append(result, leftPad(value, len(g.GetPBytes()))...)
+	}
+	return result
+}
+
+// Makes a Mul2Chunk invocation with correct outputs, and an ElGamalChunk
+// invocation whose outputs weren't checked
+func makeTestInvocations(g *cyclic.Group) []*gpumaths.Invocation {
+	const n = 4
+	x := randomValues(g, n, 1)
+	y := randomValues(g, n, 2)
+	privateKeys := randomValues(g, n, 3)
+	products := make([][]byte, n)
+	for i := range products {
+		products[i] = g.Mul(g.NewIntFromBytes(x[i]), g.NewIntFromBytes(y[i]),
+			g.NewInt(1)).Bytes()
+	}
+	return []*gpumaths.Invocation{{
+		Op:       "Mul2Chunk",
+		P:        g.GetPBytes(),
+		G:        g.GetG().Bytes(),
+		NumSlots: n,
+		Inputs:   [][]byte{packValues(g, x), packValues(g, y)},
+		Outputs:  [][]byte{packValues(g, products)},
+	}, {
+		Op:       "ElGamalChunk",
+		P:        g.GetPBytes(),
+		G:        g.GetG().Bytes(),
+		Scalars:  [][]byte{{1, 1}},
+		NumSlots: n,
+		Inputs: [][]byte{packValues(g, x), packValues(g, privateKeys),
+			packValues(g, x), packValues(g, y)},
+		Outputs: [][]byte{packValues(g, x), packValues(g, y)},
+	}}
+}
+
+func writeTestRecording(t *testing.T, path string,
+	invocations []*gpumaths.Invocation) {
+	r, err := gpumaths.CreateRecording(path, gpumaths.RecordOptions{
+		IncludePrivateKeys: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inv := range invocations {
+		err = r.Write(inv)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A redacted copy should replay, skipping the invocation without its
+// private keys
+func TestRunReplay_Redact(t *testing.T) {
+	dir := t.TempDir()
+	g := makeTestGroup()
+	recording := filepath.Join(dir, "recording")
+	redacted := filepath.Join(dir, "redacted")
+	writeTestRecording(t, recording, makeTestInvocations(g))
+
+	var out bytes.Buffer
+	err := runReplay([]string{"-recording", recording, "-redact", redacted},
+		&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(redacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, packValues(g, randomValues(g, 4, 3))) {
+		t.Error("Redacted copy has the private keys")
+	}
+
+	out.Reset()
+	err = runReplay([]string{"-recording", redacted, "-bits", "2048",
+		"-json"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []replayRecord
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var record replayRecord
+		err = dec.Decode(&record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 || records[0].Skipped != "" ||
+		records[0].Error != "" || len(records[0].Mismatches) != 0 ||
+		records[1].Skipped == "" {
+		t.Errorf("Unexpected replay results: %+v", records)
+	}
+}
+
+// A wrong recorded output should fail the replay, naming the slot
+func TestRunReplay_Mismatch(t *testing.T) {
+	dir := t.TempDir()
+	g := makeTestGroup()
+	recording := filepath.Join(dir, "recording")
+	inv := makeTestInvocations(g)[0]
+	inv.Outputs[0][2*len(inv.P)] ^= 1
+	writeTestRecording(t, recording, []*gpumaths.Invocation{inv})
+
+	var out bytes.Buffer
+	err := runReplay([]string{"-recording", recording, "-bits", "2048"},
+		&out)
+	if err == nil {
+		t.Fatal("Replay should fail when an output doesn't match")
+	}
+	if !strings.Contains(out.String(), "result slots [2]") {
+		t.Errorf("Output should name the mismatched slot:\n%v", out.String())
+	}
+}
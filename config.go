@@ -0,0 +1,361 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// config.go loads stream pool settings from a file and the environment, so
+// services don't have to hard-code numStreams and memSize.
+
+// Backend chooses where the ops run
+type Backend string
+
+const (
+	// BackendAuto uses the GPU if the build has it, and the CPU otherwise
+	BackendAuto Backend = "auto"
+	// BackendGPU requires a build with the gpu tag
+	BackendGPU Backend = "gpu"
+	// BackendCPU runs CPUOps in either build
+	BackendCPU Backend = "cpu"
+)
+
+// Environment variables that override the settings from a config file
+const (
+	EnvBackend          = "GPUMATHS_BACKEND"
+	EnvNumStreams       = "GPUMATHS_NUM_STREAMS"
+	EnvPrimeBits        = "GPUMATHS_PRIME_BITS"
+	EnvTargetSlots      = "GPUMATHS_TARGET_SLOTS"
+	EnvDeviceIDs        = "GPUMATHS_DEVICE_IDS"
+	EnvVerifySampleRate = "GPUMATHS_VERIFY_SAMPLE_RATE"
+	EnvLogLevel         = "GPUMATHS_LOG_LEVEL"
+)
+
+// Config describes a stream pool and how the ops should run
+type Config struct {
+	Backend Backend `yaml:"backend" json:"backend"`
+	// Number of streams in the pool
+	NumStreams int `yaml:"numStreams" json:"numStreams"`
+	// Length of the largest prime the pool will be used with. The native
+	// library has environments for 2048, 3200 and 4096 bits.
+	PrimeBits int `yaml:"primeBits" json:"primeBits"`
+	// Slots that each stream should fit in one kernel launch, by op name,
+	// e.g. "ExpChunk". Streams are made big enough for the largest target.
+	// Ops without a target use their GetInputSize.
+	TargetSlots map[string]int `yaml:"targetSlots" json:"targetSlots"`
+	// CUDA devices to use. The native library drives one device, so this
+	// can have at most one ID. It's applied with CUDA_VISIBLE_DEVICES, which
+	// only has an effect before the process's first stream pool is made, so
+	// a different device after that is an error.
+	DeviceIDs []int `yaml:"deviceIDs" json:"deviceIDs"`
+	// Fraction of each call's slots that the GPU ops compute again with
+	// CPUOps, between 0 and 1, to catch wrong results. The CPU backend's
+	// results aren't checked.
+	VerifySampleRate float64 `yaml:"verifySampleRate" json:"verifySampleRate"`
+	// Threshold for the jww logs: trace, debug, info, warn, error, critical
+	// or fatal. Empty leaves the threshold alone.
+	LogLevel string `yaml:"logLevel" json:"logLevel"`
+}
+
+// Default slot targets, from the ops' GetInputSize
+var defaultTargetSlots = map[string]int{
+	ExpChunk.GetName():     int(ExpChunk.GetInputSize()),
+	ElGamalChunk.GetName(): int(ElGamalChunk.GetInputSize()),
+	RevealChunk.GetName():  int(RevealChunk.GetInputSize()),
+	Mul2Chunk.GetName():    int(Mul2Chunk.GetInputSize()),
+	Mul3Chunk.GetName():    int(Mul3Chunk.GetInputSize()),
+}
+
+var logLevels = map[string]jww.Threshold{
+	"trace":    jww.LevelTrace,
+	"debug":    jww.LevelDebug,
+	"info":     jww.LevelInfo,
+	"warn":     jww.LevelWarn,
+	"error":    jww.LevelError,
+	"critical": jww.LevelCritical,
+	"fatal":    jww.LevelFatal,
+}
+
+// DefaultConfig returns the settings used for anything that a config file
+// and the environment don't set
+func DefaultConfig() Config {
+	return Config{
+		Backend:    BackendAuto,
+		NumStreams: 4,
+		PrimeBits:  4096,
+	}
+}
+
+// LoadConfig reads the config file at path, then applies the environment
+// The file is parsed as JSON if it ends in .json, and as YAML otherwise.
+// Settings that neither sets keep their DefaultConfig values.
+func LoadConfig(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "couldn't read gpumaths config")
+	}
+	c, err := ParseConfig(data, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return Config{}, errors.Wrapf(err, "couldn't parse %v", path)
+	}
+	err = c.ApplyEnv()
+	if err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// ParseConfig reads a JSON or YAML config over DefaultConfig
+// Unknown settings are errors in either format, so misspellings aren't
+// ignored.
+func ParseConfig(data []byte, isJSON bool) (Config, error) {
+	c := DefaultConfig()
+	var err error
+	if isJSON {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+	} else {
+		err = yaml.UnmarshalStrict(data, &c)
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides c with any GPUMATHS_ environment variables that are set
+// GPUMATHS_TARGET_SLOTS is a list like "ExpChunk=1024,Mul2Chunk=4096" and
+// GPUMATHS_DEVICE_IDS is a comma-separated list of IDs.
+func (c *Config) ApplyEnv() error {
+	var err error
+	if v, ok := os.LookupEnv(EnvBackend); ok {
+		c.Backend = Backend(v)
+	}
+	if v, ok := os.LookupEnv(EnvNumStreams); ok {
+		c.NumStreams, err = strconv.Atoi(v)
+		if err != nil {
+			return errors.Wrap(err, EnvNumStreams)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvPrimeBits); ok {
+		c.PrimeBits, err = strconv.Atoi(v)
+		if err != nil {
+			return errors.Wrap(err, EnvPrimeBits)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvTargetSlots); ok {
+		c.TargetSlots = make(map[string]int)
+		for _, entry := range splitList(v) {
+			kv := strings.SplitN(entry, "=", 2)
+			if len(kv) != 2 {
+				return errors.Errorf("%v: %q isn't op=slots", EnvTargetSlots,
+					entry)
+			}
+			c.TargetSlots[strings.TrimSpace(kv[0])], err =
+				strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil {
+				return errors.Wrap(err, EnvTargetSlots)
+			}
+		}
+	}
+	if v, ok := os.LookupEnv(EnvDeviceIDs); ok {
+		c.DeviceIDs = nil
+		for _, entry := range splitList(v) {
+			id, err := strconv.Atoi(entry)
+			if err != nil {
+				return errors.Wrap(err, EnvDeviceIDs)
+			}
+			c.DeviceIDs = append(c.DeviceIDs, id)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvVerifySampleRate); ok {
+		c.VerifySampleRate, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return errors.Wrap(err, EnvVerifySampleRate)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvLogLevel); ok {
+		c.LogLevel = v
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, skipping empty entries
+func splitList(s string) []string {
+	var result []string
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Validate returns an error describing every setting that can't work
+func (c Config) Validate() error {
+	var problems []string
+	switch c.Backend {
+	case BackendAuto, BackendCPU:
+	case BackendGPU:
+		if !gpuBuild {
+			problems = append(problems, "the gpu backend needs a build with "+
+				"the gpu tag")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown backend %q",
+			c.Backend))
+	}
+	if c.NumStreams < 1 {
+		problems = append(problems, fmt.Sprintf("numStreams must be at "+
+			"least 1, not %v", c.NumStreams))
+	}
+	switch c.PrimeBits {
+	case 2048, 3200, 4096:
+	default:
+		problems = append(problems, fmt.Sprintf("primeBits must be 2048, "+
+			"3200 or 4096, not %v", c.PrimeBits))
+	}
+	names := make([]string, 0, len(c.TargetSlots))
+	for name := range c.TargetSlots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := defaultTargetSlots[name]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown op %q in "+
+				"targetSlots", name))
+		} else if c.TargetSlots[name] < 1 {
+			problems = append(problems, fmt.Sprintf("targetSlots for %v "+
+				"must be at least 1", name))
+		}
+	}
+	if len(c.DeviceIDs) > 1 {
+		problems = append(problems, "the native library drives one "+
+			"device, so deviceIDs can have at most one ID")
+	}
+	for _, id := range c.DeviceIDs {
+		if id < 0 {
+			problems = append(problems, fmt.Sprintf("device ID %v is "+
+				"negative", id))
+		}
+	}
+	if c.VerifySampleRate < 0 || c.VerifySampleRate > 1 {
+		problems = append(problems, fmt.Sprintf("verifySampleRate must be "+
+			"between 0 and 1, not %v", c.VerifySampleRate))
+	}
+	if _, ok := logLevels[strings.ToLower(c.LogLevel)]; c.LogLevel != "" && !ok {
+		problems = append(problems, fmt.Sprintf("unknown logLevel %q",
+			c.LogLevel))
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("invalid gpumaths config: %v",
+			strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// targetSlots returns the slot target for every op
+func (c Config) targetSlots() map[string]int {
+	result := make(map[string]int, len(defaultTargetSlots))
+	for name, slots := range defaultTargetSlots {
+		result[name] = slots
+	}
+	for name, slots := range c.TargetSlots {
+		result[name] = slots
+	}
+	return result
+}
+
+// NewStreamPoolFromConfig validates c, applies its log level and devices,
+// installs the ops for its backend and makes the pool it describes
+// The installed ops replace anything that was installed before: CPUOps for
+// the CPU backend, and otherwise the build's own ops, wrapped by VerifiedOps
+// if VerifySampleRate is set. The CPU ops don't use a pool, so with the CPU
+// backend the pool is nil.
+func NewStreamPoolFromConfig(c Config) (*StreamPool, error) {
+	err := c.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if c.LogLevel != "" {
+		level := logLevels[strings.ToLower(c.LogLevel)]
+		jww.SetStdoutThreshold(level)
+		jww.SetLogThreshold(level)
+	}
+	if !gpuBuild || c.Backend == BackendCPU {
+		CPUOps.Install()
+		return nil, nil
+	}
+	if c.VerifySampleRate > 0 {
+		VerifiedOps(buildOps, c.VerifySampleRate).Install()
+	} else {
+		buildOps.Install()
+	}
+	if len(c.DeviceIDs) > 0 {
+		err = selectCudaDevice(c.DeviceIDs[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewStreamPool(c.NumStreams, c.MemSize())
+}
+
+// CUDA reads CUDA_VISIBLE_DEVICES when it's initialized, which happens when
+// the process's first stream pool is made. After that, the value it read is
+// the one in effect.
+var cudaDevices struct {
+	sync.Mutex
+	initialized bool
+	visible     string
+}
+
+// noteCudaInit remembers CUDA_VISIBLE_DEVICES when CUDA is first initialized
+func noteCudaInit() {
+	cudaDevices.Lock()
+	defer cudaDevices.Unlock()
+	if !cudaDevices.initialized {
+		cudaDevices.initialized = true
+		cudaDevices.visible = os.Getenv("CUDA_VISIBLE_DEVICES")
+	}
+}
+
+// selectCudaDevice makes id the only visible CUDA device
+// It returns an error if CUDA was already initialized with other devices.
+func selectCudaDevice(id int) error {
+	cudaDevices.Lock()
+	defer cudaDevices.Unlock()
+	visible := strconv.Itoa(id)
+	if cudaDevices.initialized {
+		if cudaDevices.visible != visible {
+			return errors.Errorf("can't select CUDA device %v, because "+
+				"CUDA was already initialized with CUDA_VISIBLE_DEVICES=%q",
+				id, cudaDevices.visible)
+		}
+		return nil
+	}
+	err := os.Setenv("CUDA_VISIBLE_DEVICES", visible)
+	if err != nil {
+		return errors.Wrap(err, "couldn't select CUDA device")
+	}
+	return nil
+}
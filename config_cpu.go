@@ -0,0 +1,18 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+// Builds without the gpu tag run the ops on the CPU
+const gpuBuild = false
+
+// MemSize is zero, because the CPU ops don't use streams
+func (c Config) MemSize() int {
+	return 0
+}
@@ -0,0 +1,50 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+/*
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#include <powm_odd_export.h>
+*/
+import "C"
+
+// Builds with the gpu tag run the ops on CUDA streams
+const gpuBuild = true
+
+// Kernel that runs each op, by op name
+var opKernels = map[string]C.enum_kernel{
+	ExpChunk.GetName():     kernelPowmOdd,
+	ElGamalChunk.GetName(): kernelElgamal,
+	RevealChunk.GetName():  kernelReveal,
+	Mul2Chunk.GetName():    kernelMul2,
+	Mul3Chunk.GetName():    kernelMul3,
+}
+
+// MemSize is the stream size that fits every op's target slots in one kernel
+// launch, for primes of PrimeBits bits
+func (c Config) MemSize() int {
+	var env gpumathsEnv
+	switch {
+	case c.PrimeBits <= gpumathsEnv2048.getBitLen():
+		env = &gpumathsEnv2048
+	case c.PrimeBits <= gpumathsEnv3200.getBitLen():
+		env = &gpumathsEnv3200
+	default:
+		env = &gpumathsEnv4096
+	}
+	memSize := 0
+	for name, slots := range c.targetSlots() {
+		size := env.streamSizeContaining(slots, int(opKernels[name]))
+		if size > memSize {
+			memSize = size
+		}
+	}
+	return memSize
+}
@@ -0,0 +1,58 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import "testing"
+
+// Streams should be big enough for every op's target, in every environment
+func TestConfig_MemSize(t *testing.T) {
+	envs := map[int]gpumathsEnv{
+		2048: &gpumathsEnv2048,
+		3200: &gpumathsEnv3200,
+		4096: &gpumathsEnv4096,
+	}
+	for bits, env := range envs {
+		c := DefaultConfig()
+		c.PrimeBits = bits
+		c.TargetSlots = map[string]int{"Mul3Chunk": 1000}
+		for name, slots := range c.targetSlots() {
+			maxSlots := env.maxSlots(c.MemSize(), opKernels[name])
+			if maxSlots < slots {
+				t.Errorf("%v-bit streams fit %v slots of %v, expected %v",
+					bits, maxSlots, name, slots)
+			}
+		}
+	}
+}
+
+// Once CUDA is initialized, a config can't switch to another device
+func TestNewStreamPoolFromConfig_DeviceAfterInit(t *testing.T) {
+	c := DefaultConfig()
+	c.NumStreams = 1
+	c.PrimeBits = 2048
+	p, err := NewStreamPoolFromConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pick a device that isn't the visible one
+	c.DeviceIDs = []int{0}
+	if cudaDevices.visible == "0" {
+		c.DeviceIDs = []int{1}
+	}
+	_, err = NewStreamPoolFromConfig(c)
+	if err == nil {
+		t.Error("device was changed after CUDA was initialized")
+	}
+}
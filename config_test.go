@@ -0,0 +1,200 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// YAML and JSON configs should give the same settings, over the defaults
+func TestParseConfig(t *testing.T) {
+	yamlConfig := `
+numStreams: 8
+targetSlots:
+  ExpChunk: 1024
+deviceIDs: [1]
+verifySampleRate: 0.01
+logLevel: warn
+`
+	jsonConfig := `{"numStreams": 8, "targetSlots": {"ExpChunk": 1024},
+"deviceIDs": [1], "verifySampleRate": 0.01, "logLevel": "warn"}`
+	expected := Config{
+		Backend:          BackendAuto,
+		NumStreams:       8,
+		PrimeBits:        4096,
+		TargetSlots:      map[string]int{"ExpChunk": 1024},
+		DeviceIDs:        []int{1},
+		VerifySampleRate: 0.01,
+		LogLevel:         "warn",
+	}
+	for _, tc := range []struct {
+		data   string
+		isJSON bool
+	}{{yamlConfig, false}, {jsonConfig, true}} {
+		c, err := ParseConfig([]byte(tc.data), tc.isJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c, expected) {
+			t.Errorf("parsed %+v, expected %+v", c, expected)
+		}
+	}
+
+	// Misspelled settings shouldn't be ignored
+	_, err := ParseConfig([]byte("numStream: 8\n"), false)
+	if err == nil {
+		t.Error("unknown YAML setting was accepted")
+	}
+	_, err = ParseConfig([]byte(`{"numStream": 8}`), true)
+	if err == nil {
+		t.Error("unknown JSON setting was accepted")
+	}
+}
+
+// The environment should override the file
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpumaths.yaml")
+	err := ioutil.WriteFile(path, []byte("numStreams: 8\nprimeBits: 2048\n"),
+		0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvNumStreams, "2")
+	t.Setenv(EnvTargetSlots, "Mul2Chunk=4096, Mul3Chunk=512")
+	t.Setenv(EnvDeviceIDs, "0")
+	t.Setenv(EnvVerifySampleRate, "0.5")
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NumStreams != 2 || c.PrimeBits != 2048 {
+		t.Errorf("environment didn't override the file: %+v", c)
+	}
+	expectedSlots := map[string]int{"Mul2Chunk": 4096, "Mul3Chunk": 512}
+	if !reflect.DeepEqual(c.TargetSlots, expectedSlots) {
+		t.Errorf("target slots were %v, expected %v", c.TargetSlots,
+			expectedSlots)
+	}
+	if !reflect.DeepEqual(c.DeviceIDs, []int{0}) {
+		t.Errorf("device IDs were %v", c.DeviceIDs)
+	}
+	if c.VerifySampleRate != 0.5 {
+		t.Errorf("verify sample rate was %v", c.VerifySampleRate)
+	}
+
+	t.Setenv(EnvNumStreams, "lots")
+	_, err = LoadConfig(path)
+	if err == nil {
+		t.Error("malformed environment variable was accepted")
+	}
+}
+
+// Impossible settings should all be reported
+func TestConfig_Validate(t *testing.T) {
+	err := DefaultConfig().Validate()
+	if err != nil {
+		t.Errorf("default config was invalid: %v", err)
+	}
+
+	c := Config{
+		Backend:          "tpu",
+		NumStreams:       0,
+		PrimeBits:        1024,
+		TargetSlots:      map[string]int{"ExpChunk": 0, "SortChunk": 12},
+		DeviceIDs:        []int{0, -1},
+		VerifySampleRate: 2,
+		LogLevel:         "loud",
+	}
+	err = c.Validate()
+	if err == nil {
+		t.Fatal("invalid config was accepted")
+	}
+	for _, problem := range []string{"backend", "numStreams", "primeBits",
+		"ExpChunk", "SortChunk", "at most one", "negative",
+		"verifySampleRate", "logLevel"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("validation error didn't mention %v: %v", problem, err)
+		}
+	}
+
+	// The GPU backend has to be in the build
+	if !gpuBuild {
+		c = DefaultConfig()
+		c.Backend = BackendGPU
+		_, err = NewStreamPoolFromConfig(c)
+		if err == nil {
+			t.Errorf("backend %v was accepted", c.Backend)
+		}
+	}
+}
+
+// The CPU backend should install CPUOps in either build, without a pool
+func TestNewStreamPoolFromConfig_CPU(t *testing.T) {
+	restore := InstalledOps().Install()
+	defer restore()
+	c := DefaultConfig()
+	c.Backend = BackendCPU
+	p, err := NewStreamPoolFromConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != nil {
+		t.Error("CPU backend made a pool")
+	}
+	g := makeTestGroup2048()
+	x := randomTestBuffer(g, 4, 1)
+	result := g.NewIntBuffer(4, g.NewInt(1))
+	// The CPU ops don't need a pool
+	err = Mul2Chunk(nil, g, x, x, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := g.NewInt(1)
+	for i := uint32(0); i < 4; i++ {
+		g.Mul(x.Get(i), x.Get(i), expected)
+		if result.Get(i).Cmp(expected) != 0 {
+			t.Errorf("Mul2Chunk was wrong in slot %v", i)
+		}
+	}
+}
+
+// The CPU ops don't need a pool, but the GPU ops need streams that fit their
+// targets
+func TestNewStreamPoolFromConfig(t *testing.T) {
+	restore := InstalledOps().Install()
+	defer restore()
+	c := DefaultConfig()
+	c.NumStreams = 2
+	c.PrimeBits = 2048
+	c.TargetSlots = map[string]int{"ElGamalChunk": 100}
+	p, err := NewStreamPoolFromConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gpuBuild {
+		if p != nil {
+			t.Error("CPU backend made a pool")
+		}
+		return
+	}
+	defer p.Destroy()
+	if p.NumStreams() != 2 {
+		t.Errorf("pool had %v streams, expected 2", p.NumStreams())
+	}
+	for _, s := range p.Metrics().Streams {
+		if s.MemSize != c.MemSize() {
+			t.Errorf("stream had %v bytes, expected %v", s.MemSize,
+				c.MemSize())
+		}
+	}
+}
@@ -0,0 +1,120 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+/*#cgo LDFLAGS: -Llib -lpowmosm75 -Wl,-rpath -Wl,./lib:/opt/xxnetwork/lib
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#include <powm_odd_export.h>
+*/
+import "C"
+import "gitlab.com/xx_network/crypto/large"
+
+// constants_gpu.go keeps track of the constants (prime, generator, public
+// cypher key) packed at the start of each stream's buffer. Ops usually run
+// with the same group and key thousands of times in a row, so the constants
+// only need to be packed when they change.
+//
+// This only saves packing them on the host. The native enqueue functions
+// upload the constants region along with the inputs every time, and skipping
+// that upload needs a change to the native library.
+
+// constantsCache remembers which kernel's constants are in a stream's
+// constants region, and what they are
+// The constants regions of all kernels start at the beginning of the buffer,
+// and each kernel's inputs start right after its own constants, so running a
+// different kernel can overwrite them. Only the constants of the kernel that
+// ran last are known to be intact.
+type constantsCache struct {
+	valid   bool
+	kernel  C.enum_kernel
+	wordLen int
+	values  []large.Bits
+	// How often the constants could be reused, for tests
+	hits   int
+	misses int
+}
+
+// putConstants packs values into the stream's constants region for kernel,
+// unless that's already been done
+// Each value takes up one bignum of the environment's size, in order.
+func (s *Stream) putConstants(env gpumathsEnv, kernel C.enum_kernel,
+	values ...large.Bits) {
+	bnLengthWords := env.getWordLen()
+	if s.constants.matches(kernel, bnLengthWords, values) {
+		s.constants.hits++
+		return
+	}
+
+	constants := s.getCpuConstantsWords(env, kernel)
+	offset := 0
+	for i := range values {
+		putBits(constants[offset:offset+bnLengthWords], values[i],
+			bnLengthWords)
+		offset += bnLengthWords
+	}
+	s.constants.remember(kernel, bnLengthWords, values)
+}
+
+// matches is true if the constants region already holds values for kernel
+func (c *constantsCache) matches(kernel C.enum_kernel, wordLen int,
+	values []large.Bits) bool {
+	if c == nil || !c.valid || c.kernel != kernel || c.wordLen != wordLen ||
+		len(c.values) != len(values) {
+		return false
+	}
+	for i := range values {
+		if !equalBits(c.values[i], values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// remember records that values were packed for kernel
+// The values are copied, because the ints they came from could change.
+func (c *constantsCache) remember(kernel C.enum_kernel, wordLen int,
+	values []large.Bits) {
+	if c == nil {
+		return
+	}
+	c.misses++
+	c.valid = true
+	c.kernel = kernel
+	c.wordLen = wordLen
+	if cap(c.values) < len(values) {
+		c.values = make([]large.Bits, len(values))
+	}
+	c.values = c.values[:len(values)]
+	for i := range values {
+		c.values[i] = append(c.values[i][:0], values[i]...)
+	}
+}
+
+// invalidate forgets what's in the constants region, for when something else
+// overwrites it
+func (c *constantsCache) invalidate() {
+	if c != nil {
+		c.valid = false
+	}
+}
+
+// equalBits compares two numbers' words
+// Bits from large.Int are normalized, so equal numbers have equal lengths.
+func equalBits(a, b large.Bits) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
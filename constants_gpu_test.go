@@ -0,0 +1,110 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"testing"
+)
+
+// Constants should only be packed again when the kernel or values change
+func TestConstantsCache(t *testing.T) {
+	const numSlots = 4
+	g := makeTestGroup4096()
+	env := chooseEnv(g)
+	streamPool, err := NewStreamPool(1, env.streamSizeContaining(numSlots,
+		kernelElgamal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer streamPool.Destroy()
+	stream, err := streamPool.TryTakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := stream.constants
+	streamPool.ReturnStream(stream)
+
+	x := initRandomIntBuffer(g, numSlots, 42, 0)
+	y := initRandomIntBuffer(g, numSlots, 43, 32)
+	z := g.NewIntBuffer(numSlots, g.NewInt(1))
+	expectHitsMisses := func(hits, misses int) {
+		t.Helper()
+		if cache.hits != hits || cache.misses != misses {
+			t.Errorf("Cache had %v hits and %v misses, expected %v and %v",
+				cache.hits, cache.misses, hits, misses)
+		}
+	}
+
+	// Mul2Chunk doesn't wipe its inputs on this pool, so it runs the same
+	// kernel repeatedly
+	for i := 0; i < 3; i++ {
+		err = Mul2Chunk(streamPool, g, x, y, z)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectHitsMisses(2, 1)
+	// A different kernel overwrites the constants
+	err = Mul3Chunk(streamPool, g, x, y, x, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectHitsMisses(2, 2)
+	err = Mul2Chunk(streamPool, g, x, y, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectHitsMisses(2, 3)
+	for i := uint32(0); i < numSlots; i++ {
+		if z.Get(i).Cmp(g.Mul(x.Get(i), y.Get(i), g.NewInt(1))) != 0 {
+			t.Errorf("Mul2 result %v was wrong after repacking constants", i)
+		}
+	}
+}
+
+// Changing the public cypher key, even in place, should repack the constants
+// ElGamalChunk wipes its private keys with a mul2 kernel, whose inputs
+// overwrite the ElGamal constants, so they're packed again for the same key.
+func TestConstantsCache_KeyChange(t *testing.T) {
+	const numSlots = 4
+	g := makeTestGroup4096()
+	env := chooseEnv(g)
+	streamPool, err := NewStreamPool(1, env.streamSizeContaining(numSlots,
+		kernelElgamal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer streamPool.Destroy()
+
+	publicCypherKey := g.NewInt(5)
+	for _, key := range []int64{5, 5, 7} {
+		g.SetUint64(publicCypherKey, uint64(key))
+		keys := initRandomIntBuffer(g, numSlots, 42, 32)
+		privateKeys := initRandomIntBuffer(g, numSlots, 43, 32)
+		ecrKeys := initRandomIntBuffer(g, numSlots, 44, 0)
+		cyphers := initRandomIntBuffer(g, numSlots, 45, 0)
+		expectedEcrKeys := ecrKeys.DeepCopy()
+		expectedCyphers := cyphers.DeepCopy()
+		err = ElGamalChunk(streamPool, g, keys, privateKeys, publicCypherKey,
+			ecrKeys, cyphers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := uint32(0); i < numSlots; i++ {
+			cryptops.ElGamal(g, keys.Get(i), privateKeys.Get(i),
+				publicCypherKey, expectedEcrKeys.Get(i), expectedCyphers.Get(i))
+			if ecrKeys.Get(i).Cmp(expectedEcrKeys.Get(i)) != 0 ||
+				cyphers.Get(i).Cmp(expectedCyphers.Get(i)) != 0 {
+				t.Errorf("ElGamal with key %v was wrong in slot %v", key, i)
+			}
+		}
+	}
+}
@@ -9,11 +9,12 @@
 
 package gpumaths
 
-// api_cpu.go (and all of the *_cpu.go files) hold stub information necessary
-// to make the api build without the importers having to do anything.
-// Instead of crashing/breaking the build, we return error messages back
-// on all the api calls in lieu of performing the operation in the cpu (this
-// is future work)
+// api_cpu.go (and all of the *_cpu.go files) make the api build without the
+// importers having to do anything. The operations run on the CPU with the
+// implementations in cpuops.go, and the stream pool isn't used, so a nil
+// pool can be passed to them. They used to return NoGpuErrStr instead, so
+// callers that need to know whether there's a GPU should check the error
+// from NewStreamPool, which still returns it.
 
 // NoGpuErrStr is the error returned when the gpu is not supported inthe build.
 const NoGpuErrStr = "gpumaths stubbed build doesn't support CUDA stream pool"
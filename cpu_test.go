@@ -0,0 +1,93 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"testing"
+)
+
+// The CPU ops should match the elixxir/crypto implementations, without a pool
+func TestCPUOps(t *testing.T) {
+	const n = 8
+	g := makeTestGroup2048()
+	x := randomTestBuffer(g, n, 1)
+	y := randomTestBuffer(g, n, 2)
+	z := randomTestBuffer(g, n, 3)
+
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	_, err := ExpChunk(nil, g, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if result.Get(i).Cmp(g.Exp(x.Get(i), y.Get(i), g.NewInt(1))) != 0 {
+			t.Errorf("ExpChunk was wrong in slot %v", i)
+		}
+	}
+
+	err = Mul2Chunk(nil, g, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if result.Get(i).Cmp(g.Mul(x.Get(i), y.Get(i), g.NewInt(1))) != 0 {
+			t.Errorf("Mul2Chunk was wrong in slot %v", i)
+		}
+	}
+
+	// Writing over an input should still give the right product
+	expected := z.DeepCopy()
+	for i := uint32(0); i < n; i++ {
+		cryptops.Mul3(g, x.Get(i), y.Get(i), expected.Get(i))
+	}
+	err = Mul3Chunk(nil, g, x, y, z, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if z.Get(i).Cmp(expected.Get(i)) != 0 {
+			t.Errorf("Mul3Chunk was wrong in slot %v", i)
+		}
+	}
+
+	publicCypherKey := g.NewInt(65537)
+	ecrKey := randomTestBuffer(g, n, 4)
+	cypher := randomTestBuffer(g, n, 5)
+	expectedEcrKey := ecrKey.DeepCopy()
+	expectedCypher := cypher.DeepCopy()
+	for i := uint32(0); i < n; i++ {
+		cryptops.ElGamal(g, x.Get(i), y.Get(i), publicCypherKey,
+			expectedEcrKey.Get(i), expectedCypher.Get(i))
+	}
+	err = ElGamalChunk(nil, g, x, y, publicCypherKey, ecrKey, cypher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if ecrKey.Get(i).Cmp(expectedEcrKey.Get(i)) != 0 ||
+			cypher.Get(i).Cmp(expectedCypher.Get(i)) != 0 {
+			t.Errorf("ElGamalChunk was wrong in slot %v", i)
+		}
+	}
+
+	for i := uint32(0); i < n; i++ {
+		cryptops.RootCoprime(g, x.Get(i), publicCypherKey, expected.Get(i))
+	}
+	err = RevealChunk(nil, g, publicCypherKey, x, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if result.Get(i).Cmp(expected.Get(i)) != 0 {
+			t.Errorf("RevealChunk was wrong in slot %v", i)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+)
+
+// cpuops.go has the CPU implementations of the ops, with the elixxir/crypto
+// functions. They're in every build, so code that needs the CPU, like the
+// offload server's tests, can ask for them explicitly with CPUOps. Builds
+// without the gpu tag also use them for the op variables.
+
+// Ops is a set of implementations of the ops
+type Ops struct {
+	ExpChunk     ExpChunkPrototype
+	ElGamalChunk ElGamalChunkPrototype
+	RevealChunk  RevealChunkPrototype
+	Mul2Chunk    Mul2ChunkPrototype
+	Mul2Slice    Mul2SlicePrototype
+	Mul3Chunk    Mul3ChunkPrototype
+}
+
+// CPUOps runs the ops on the CPU in either build
+// They don't use the stream pool, so it can be nil.
+var CPUOps = Ops{
+	ExpChunk:     cpuExpChunk,
+	ElGamalChunk: cpuElGamalChunk,
+	RevealChunk:  cpuRevealChunk,
+	Mul2Chunk:    cpuMul2Chunk,
+	Mul2Slice:    cpuMul2Slice,
+	Mul3Chunk:    cpuMul3Chunk,
+}
+
+// InstalledOps returns the op variables: the GPU ops in builds with the gpu
+// tag, the CPU ops without it, or whatever has been installed in their place
+func InstalledOps() Ops {
+	return Ops{
+		ExpChunk:     ExpChunk,
+		ElGamalChunk: ElGamalChunk,
+		RevealChunk:  RevealChunk,
+		Mul2Chunk:    Mul2Chunk,
+		Mul2Slice:    Mul2Slice,
+		Mul3Chunk:    Mul3Chunk,
+	}
+}
+
+// buildOps are the op variables the build starts with, before anything is
+// installed in their place
+var buildOps = InstalledOps()
+
+// Install replaces the op variables with o, and returns a function that puts
+// the old ones back
+func (o Ops) Install() (restore func()) {
+	old := InstalledOps()
+	ExpChunk = o.ExpChunk
+	ElGamalChunk = o.ElGamalChunk
+	RevealChunk = o.RevealChunk
+	Mul2Chunk = o.Mul2Chunk
+	Mul2Slice = o.Mul2Slice
+	Mul3Chunk = o.Mul3Chunk
+	return func() {
+		old.Install()
+	}
+}
+
+// cpuExpChunk computes z = x**y for every slot
+func cpuExpChunk(p *StreamPool, g *cyclic.Group, x, y,
+	z Operands) (*cyclic.IntBuffer, error) {
+	rec := p.beginInvocation("ExpChunk", "", 0, g, nil, x, y)
+	for i := uint32(0); i < uint32(z.Len()); i++ {
+		cryptops.Exp(g, x.Get(i), y.Get(i), z.Get(i))
+	}
+	rec.end(nil, z)
+	return expResult(z), nil
+}
+
+// cpuElGamalChunk runs ElGamal for every slot
+func cpuElGamalChunk(p *StreamPool, g *cyclic.Group, key,
+	privateKey Operands, publicCypherKey *cyclic.Int, ecrKey,
+	cypher Operands) error {
+	rec := p.beginInvocation("ElGamalChunk", "", 0, g,
+		[]*cyclic.Int{publicCypherKey}, key, privateKey, ecrKey, cypher)
+	for i := uint32(0); i < uint32(ecrKey.Len()); i++ {
+		cryptops.ElGamal(g, key.Get(i), privateKey.Get(i), publicCypherKey,
+			ecrKey.Get(i), cypher.Get(i))
+	}
+	rec.end(nil, ecrKey, cypher)
+	return nil
+}
+
+// cpuRevealChunk takes the publicCypherKey'th root of every slot of cypher
+func cpuRevealChunk(p *StreamPool, g *cyclic.Group,
+	publicCypherKey *cyclic.Int, cypher, result Operands) error {
+	rec := p.beginInvocation("RevealChunk", "", 0, g,
+		[]*cyclic.Int{publicCypherKey}, cypher)
+	for i := uint32(0); i < uint32(result.Len()); i++ {
+		cryptops.RootCoprime(g, cypher.Get(i), publicCypherKey, result.Get(i))
+	}
+	rec.end(nil, result)
+	return nil
+}
+
+// cpuMul2Chunk computes result = x*y for every slot
+func cpuMul2Chunk(p *StreamPool, g *cyclic.Group, x, y,
+	result Operands) error {
+	rec := p.beginInvocation("Mul2Chunk", "", 0, g, nil, x, y)
+	for i := uint32(0); i < uint32(result.Len()); i++ {
+		g.Mul(x.Get(i), y.Get(i), result.Get(i))
+	}
+	rec.end(nil, result)
+	return nil
+}
+
+// cpuMul2Slice runs cpuMul2Chunk with slices for y and result
+func cpuMul2Slice(p *StreamPool, g *cyclic.Group, x *cyclic.IntBuffer, y,
+	result []*cyclic.Int) error {
+	return cpuMul2Chunk(p, g, x, IntSlice(y), IntSlice(result))
+}
+
+// cpuMul3Chunk computes result = x*y*z for every slot
+func cpuMul3Chunk(p *StreamPool, g *cyclic.Group, x, y, z,
+	result Operands) error {
+	rec := p.beginInvocation("Mul3Chunk", "", 0, g, nil, x, y, z)
+	product := g.NewInt(1)
+	for i := uint32(0); i < uint32(result.Len()); i++ {
+		// result can be one of the inputs, so it's only written at the end
+		g.Mul(x.Get(i), y.Get(i), product)
+		g.Mul(product, z.Get(i), result.Get(i))
+	}
+	rec.end(nil, result)
+	return nil
+}
@@ -0,0 +1,352 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"crypto/sha256"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"io"
+)
+
+// dleq.go contains batched Chaum-Pedersen proofs that log_g(a) = log_h(b).
+// The protocol itself is the same for every build. It's built from the
+// expBatch and mulBatch helpers, which run on the exp and mul2 kernels in
+// dleq_gpu.go and on cryptops in dleq_cpu.go, and from ReduceProductChunk.
+//
+// For each slot with secret x, the prover publishes a = g**x and b = h**x,
+// picks a random k, and commits to t1 = g**k and t2 = h**k. The challenge c
+// is a hash of the statement and commitments, and the response is
+// s = k - c*x mod p-1. The verifier checks g**s * a**c = t1 and
+// h**s * b**c = t2.
+//
+// The verifiers take the order q of the subgroup that g and h generate, and
+// fail any slot where h, a, b, t1 or t2 isn't in it. Otherwise a prover
+// could flip the sign of b, which multiplies by an element of order 2 that
+// the checks can't see: batch verification would accept it half of the time,
+// and a prover could retry with new nonces until it was accepted.
+
+// DLEQProofs holds a proof for every slot of a buffer
+type DLEQProofs struct {
+	// Commitments to the nonce, g**k and h**k
+	T1, T2 *cyclic.IntBuffer
+	// Responses, k - c*x mod p-1
+	S *cyclic.IntBuffer
+}
+
+// NewDLEQProofs allocates proofs for numSlots slots
+func NewDLEQProofs(g *cyclic.Group, numSlots uint32) *DLEQProofs {
+	return &DLEQProofs{
+		T1: g.NewIntBuffer(numSlots, g.NewInt(1)),
+		T2: g.NewIntBuffer(numSlots, g.NewInt(1)),
+		S:  g.NewIntBuffer(numSlots, g.NewInt(1)),
+	}
+}
+
+// Len returns the number of slots the proofs cover
+func (d *DLEQProofs) Len() int {
+	return d.S.Len()
+}
+
+// ProveDLEQChunkPrototype computes a = g**x and b = h**x for every slot of x,
+// along with a proof that both share the same exponent
+// The nonces are read from rng, so the proofs are deterministic if rng is
+type ProveDLEQChunkPrototype func(p *StreamPool, g *cyclic.Group, h *cyclic.Int,
+	x, a, b Operands, proofs *DLEQProofs, rng io.Reader) error
+
+// VerifyDLEQChunkPrototype checks every proof separately and returns the
+// indices of the slots whose proofs didn't verify
+// q is the order of the subgroup that g and h generate.
+type VerifyDLEQChunkPrototype func(p *StreamPool, g *cyclic.Group, q *large.Int,
+	h *cyclic.Int, a, b Operands, proofs *DLEQProofs) ([]uint32, error)
+
+// BatchVerifyDLEQChunkPrototype checks all proofs at once with a random
+// linear combination. It's much cheaper than checking them separately, but
+// if it fails it can't say which slot was at fault.
+// q is the order of the subgroup that g and h generate.
+type BatchVerifyDLEQChunkPrototype func(p *StreamPool, g *cyclic.Group,
+	q *large.Int, h *cyclic.Int, a, b Operands, proofs *DLEQProofs,
+	rng io.Reader) (bool, error)
+
+// GetInputSize is how big chunk sizes should be to run the proof operation
+func (ProveDLEQChunkPrototype) GetInputSize() uint32 {
+	return 64
+}
+
+// GetName returns the name of the ProveDLEQChunk operation
+func (ProveDLEQChunkPrototype) GetName() string {
+	return "ProveDLEQChunk"
+}
+
+// GetInputSize is how big chunk sizes should be to run the verify operation
+func (VerifyDLEQChunkPrototype) GetInputSize() uint32 {
+	return 64
+}
+
+// GetName returns the name of the VerifyDLEQChunk operation
+func (VerifyDLEQChunkPrototype) GetName() string {
+	return "VerifyDLEQChunk"
+}
+
+// GetInputSize is how big chunk sizes should be to run the batch verify operation
+func (BatchVerifyDLEQChunkPrototype) GetInputSize() uint32 {
+	return 256
+}
+
+// GetName returns the name of the BatchVerifyDLEQChunk operation
+func (BatchVerifyDLEQChunkPrototype) GetName() string {
+	return "BatchVerifyDLEQChunk"
+}
+
+// Length in bytes of the batch verification coefficients
+const dleqCoefficientLen = 16
+
+// Domain separation for the challenge hash
+const dleqHashDomain = "gpumaths DLEQ proof"
+
+// ProveDLEQChunk generates proofs for every slot of x
+var ProveDLEQChunk ProveDLEQChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	h *cyclic.Int, x, a, b Operands, proofs *DLEQProofs, rng io.Reader) error {
+	numSlots := uint32(x.Len())
+	if a.Len() != x.Len() || b.Len() != x.Len() || proofs.Len() != x.Len() {
+		return errors.New("ProveDLEQChunk: all buffers must have the same length")
+	}
+
+	nonces := g.NewIntBuffer(numSlots, g.NewInt(1))
+	for i := uint32(0); i < numSlots; i++ {
+		k, err := randomExponent(g, rng, len(g.GetPBytes())+8)
+		if err != nil {
+			return errors.Wrap(err, "ProveDLEQChunk: couldn't generate nonce")
+		}
+		setExponent(g, nonces.Get(i), k)
+	}
+
+	gBuf := g.NewIntBuffer(numSlots, g.NewIntFromLargeInt(g.GetG()))
+	hBuf := g.NewIntBuffer(numSlots, h)
+	err := expBatch(p, g, gBuf, x, a)
+	if err != nil {
+		return err
+	}
+	err = expBatch(p, g, hBuf, x, b)
+	if err != nil {
+		return err
+	}
+	err = expBatch(p, g, gBuf, nonces, proofs.T1)
+	if err != nil {
+		return err
+	}
+	err = expBatch(p, g, hBuf, nonces, proofs.T2)
+	if err != nil {
+		return err
+	}
+
+	pSub1 := g.GetPSub1()
+	for i := uint32(0); i < numSlots; i++ {
+		c := DLEQChallenge(g, h, a.Get(i), b.Get(i), proofs.T1.Get(i), proofs.T2.Get(i))
+		// s = k - c*x mod p-1
+		s := large.NewInt(0).Mul(c, x.Get(i).GetLargeInt())
+		s.Sub(nonces.Get(i).GetLargeInt(), s)
+		s.Mod(s, pSub1)
+		setExponent(g, proofs.S.Get(i), s)
+	}
+
+	return nil
+}
+
+// VerifyDLEQChunk checks each proof separately
+var VerifyDLEQChunk VerifyDLEQChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	q *large.Int, h *cyclic.Int, a, b Operands, proofs *DLEQProofs) ([]uint32, error) {
+	numSlots := uint32(a.Len())
+	if b.Len() != a.Len() || proofs.Len() != a.Len() {
+		return nil, errors.New("VerifyDLEQChunk: all buffers must have the same length")
+	}
+	failed, err := checkDLEQSubgroup(p, g, q, h, a, b, proofs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "VerifyDLEQChunk")
+	}
+
+	pSub1 := g.GetPSub1()
+	challenges := g.NewIntBuffer(numSlots, g.NewInt(1))
+	for i := uint32(0); i < numSlots; i++ {
+		c := DLEQChallenge(g, h, a.Get(i), b.Get(i), proofs.T1.Get(i),
+			proofs.T2.Get(i))
+		setExponent(g, challenges.Get(i), c.Mod(c, pSub1))
+	}
+
+	// Recompute each commitment as base**s * public**c and compare it with
+	// the one in the proof
+	recompute := func(base *cyclic.Int, public, commitments Operands) error {
+		baseBuf := g.NewIntBuffer(numSlots, base)
+		lhs := g.NewIntBuffer(numSlots, g.NewInt(1))
+		rhs := g.NewIntBuffer(numSlots, g.NewInt(1))
+		err := expBatch(p, g, baseBuf, proofs.S, lhs)
+		if err != nil {
+			return err
+		}
+		err = expBatch(p, g, public, challenges, rhs)
+		if err != nil {
+			return err
+		}
+		err = mulBatch(p, g, lhs, rhs, lhs)
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < numSlots; i++ {
+			if lhs.Get(i).Cmp(commitments.Get(i)) != 0 {
+				failed[i] = true
+			}
+		}
+		return nil
+	}
+	err = recompute(g.NewIntFromLargeInt(g.GetG()), a, proofs.T1)
+	if err != nil {
+		return nil, err
+	}
+	err = recompute(h, b, proofs.T2)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []uint32
+	for i := uint32(0); i < numSlots; i++ {
+		if failed[i] {
+			result = append(result, i)
+		}
+	}
+	return result, nil
+}
+
+// BatchVerifyDLEQChunk checks that for random coefficients r,
+// g**sum(r*s) * prod(a**(r*c)) = prod(t1**r), and the same for h, b and t2
+var BatchVerifyDLEQChunk BatchVerifyDLEQChunkPrototype = func(p *StreamPool,
+	g *cyclic.Group, q *large.Int, h *cyclic.Int, a, b Operands,
+	proofs *DLEQProofs, rng io.Reader) (bool, error) {
+	numSlots := uint32(a.Len())
+	if b.Len() != a.Len() || proofs.Len() != a.Len() {
+		return false, errors.New("BatchVerifyDLEQChunk: all buffers must have the same length")
+	}
+	if numSlots == 0 {
+		return true, nil
+	}
+	failed, err := checkDLEQSubgroup(p, g, q, h, a, b, proofs)
+	if err != nil {
+		return false, errors.WithMessage(err, "BatchVerifyDLEQChunk")
+	}
+	if len(failed) != 0 {
+		return false, nil
+	}
+
+	pSub1 := g.GetPSub1()
+	coefficients := g.NewIntBuffer(numSlots, g.NewInt(1))
+	weightedChallenges := g.NewIntBuffer(numSlots, g.NewInt(1))
+	sumResponses := large.NewInt(0)
+	for i := uint32(0); i < numSlots; i++ {
+		r, err := randomExponent(g, rng, dleqCoefficientLen)
+		if err != nil {
+			return false, errors.Wrap(err, "BatchVerifyDLEQChunk: couldn't generate coefficient")
+		}
+		setExponent(g, coefficients.Get(i), r)
+		c := DLEQChallenge(g, h, a.Get(i), b.Get(i), proofs.T1.Get(i), proofs.T2.Get(i))
+		rc := large.NewInt(0).Mul(r, c)
+		setExponent(g, weightedChallenges.Get(i), rc.Mod(rc, pSub1))
+		rs := large.NewInt(0).Mul(r, proofs.S.Get(i).GetLargeInt())
+		sumResponses.Add(sumResponses, rs)
+	}
+	sumResponses.Mod(sumResponses, pSub1)
+	exponent := g.NewInt(1)
+	setExponent(g, exponent, sumResponses)
+
+	check := func(base *cyclic.Int, public, commitments Operands) (bool, error) {
+		lhs := g.NewIntBuffer(numSlots, g.NewInt(1))
+		rhs := g.NewIntBuffer(numSlots, g.NewInt(1))
+		err := expBatch(p, g, public, weightedChallenges, lhs)
+		if err != nil {
+			return false, err
+		}
+		err = expBatch(p, g, commitments, coefficients, rhs)
+		if err != nil {
+			return false, err
+		}
+		lhsProduct, err := ReduceProductChunk(p, g, lhs)
+		if err != nil {
+			return false, err
+		}
+		rhsProduct, err := ReduceProductChunk(p, g, rhs)
+		if err != nil {
+			return false, err
+		}
+		g.Mul(lhsProduct, g.Exp(base, exponent, g.NewInt(1)), lhsProduct)
+		return lhsProduct.Cmp(rhsProduct) == 0, nil
+	}
+	ok, err := check(g.NewIntFromLargeInt(g.GetG()), a, proofs.T1)
+	if err != nil || !ok {
+		return false, err
+	}
+	return check(h, b, proofs.T2)
+}
+
+// checkDLEQSubgroup returns the slots where a, b, t1 or t2 isn't in the
+// subgroup of order q, or an error if h isn't in it
+func checkDLEQSubgroup(p *StreamPool, g *cyclic.Group, q *large.Int,
+	h *cyclic.Int, a, b Operands, proofs *DLEQProofs) (map[uint32]bool, error) {
+	if q == nil {
+		return nil, errors.New("the subgroup order q is required")
+	}
+	hValue := h.GetLargeInt()
+	if !g.Inside(hValue) ||
+		large.NewInt(0).Exp(hValue, q, g.GetP()).Cmp(large.NewInt(1)) != 0 {
+		return nil, errors.New("h isn't in the subgroup of order q")
+	}
+	failed := make(map[uint32]bool)
+	for _, x := range []Operands{a, b, proofs.T1, proofs.T2} {
+		slots, err := ValidateChunk(p, g, q, x)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range slots {
+			failed[i] = true
+		}
+	}
+	return failed, nil
+}
+
+// setExponent writes v, which has been reduced mod p-1, to x
+// SetLargeInt can't be used for exponents, because it leaves x unchanged
+// when v is 0, and GetLargeInt returns a copy, so x is set from v's bytes.
+func setExponent(g *cyclic.Group, x *cyclic.Int, v *large.Int) {
+	g.SetBytes(x, v.Bytes())
+}
+
+// DLEQChallenge hashes the statement and commitments for one slot into the
+// challenge c. Every value is padded to the length of the prime so the
+// encoding is unambiguous. It's exported so proofs can be checked without
+// the ops, e.g. by gpumathstest's reference implementation.
+func DLEQChallenge(g *cyclic.Group, h, a, b, t1, t2 *cyclic.Int) *large.Int {
+	byteLen := uint64(len(g.GetPBytes()))
+	hash := sha256.New()
+	hash.Write([]byte(dleqHashDomain))
+	hash.Write(g.GetP().LeftpadBytes(byteLen))
+	hash.Write(g.GetG().LeftpadBytes(byteLen))
+	for _, v := range []*cyclic.Int{h, a, b, t1, t2} {
+		hash.Write(v.LeftpadBytes(byteLen))
+	}
+	return large.NewIntFromBytes(hash.Sum(nil))
+}
+
+// randomExponent reads numBytes from rng and reduces them mod p-1
+// Reading more bytes than the prime's length keeps the bias negligible
+func randomExponent(g *cyclic.Group, rng io.Reader, numBytes int) (*large.Int, error) {
+	buf := make([]byte, numBytes)
+	_, err := io.ReadFull(rng, buf)
+	if err != nil {
+		return nil, err
+	}
+	result := large.NewIntFromBytes(buf)
+	return result.Mod(result, g.GetPSub1()), nil
+}
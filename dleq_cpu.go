@@ -0,0 +1,34 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+)
+
+// dleq_cpu.go backs the DLEQ proofs in dleq.go with cryptops, so proofs can
+// be made and checked on nodes without a GPU
+
+// expBatch computes z = x**y for every slot
+func expBatch(p *StreamPool, g *cyclic.Group, x, y, z Operands) error {
+	for i := uint32(0); i < uint32(z.Len()); i++ {
+		cryptops.Exp(g, x.Get(i), y.Get(i), z.Get(i))
+	}
+	return nil
+}
+
+// mulBatch computes z = x*y for every slot
+func mulBatch(p *StreamPool, g *cyclic.Group, x, y, z Operands) error {
+	for i := uint32(0); i < uint32(z.Len()); i++ {
+		g.Mul(x.Get(i), y.Get(i), z.Get(i))
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import "testing"
+
+// Shared test cases are located in dleq_vectors_test.go
+
+func TestDLEQVectors(t *testing.T) {
+	testDLEQVectors(t, nil)
+}
+
+func TestDLEQ(t *testing.T) {
+	testDLEQ(t, nil)
+}
+
+func TestDLEQZeroResponse(t *testing.T) {
+	testDLEQZeroResponse(t, nil)
+}
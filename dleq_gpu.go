@@ -0,0 +1,25 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import "gitlab.com/elixxir/crypto/cyclic"
+
+// dleq_gpu.go backs the DLEQ proofs in dleq.go with the GPU kernels
+
+// expBatch computes z = x**y for every slot on the exp kernel
+func expBatch(p *StreamPool, g *cyclic.Group, x, y, z Operands) error {
+	_, err := ExpChunk(p, g, x, y, z)
+	return err
+}
+
+// mulBatch computes z = x*y for every slot on the mul2 kernel
+func mulBatch(p *StreamPool, g *cyclic.Group, x, y, z Operands) error {
+	return Mul2Chunk(p, g, x, y, z)
+}
@@ -0,0 +1,50 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import "testing"
+
+// Shared test cases are located in dleq_vectors_test.go
+
+func TestDLEQVectors(t *testing.T) {
+	streamPool, err := NewStreamPool(1, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDLEQVectors(t, streamPool)
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDLEQ(t *testing.T) {
+	streamPool, err := NewStreamPool(1, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDLEQ(t, streamPool)
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDLEQZeroResponse(t *testing.T) {
+	streamPool, err := NewStreamPool(1, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDLEQZeroResponse(t, streamPool)
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
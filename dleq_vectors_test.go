@@ -0,0 +1,221 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+// dleq_vectors_test.go has the DLEQ test cases shared by the GPU and CPU
+// builds, so both backends are held to the same vectors
+
+import (
+	"bytes"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// A tiny safe prime group (467 = 2*233 + 1) keeps the vectors readable
+func makeDLEQVectorGroup() *cyclic.Group {
+	return cyclic.NewGroup(large.NewInt(467), large.NewInt(4))
+}
+
+var dleqVectorSecrets = []int64{5, 100, 232}
+
+// Expected outputs for the secrets above with h = 9 and nonces from a
+// math/rand source seeded with 42
+var dleqVectorExpected = struct {
+	a, b, t1, t2, s []int64
+}{
+	a:  []int64{90, 229, 117},
+	b:  []int64{207, 209, 52},
+	t1: []int64{69, 436, 266},
+	t2: []int64{109, 411, 387},
+	s:  []int64{49, 22, 133},
+}
+
+func intBufferFromInt64s(g *cyclic.Group, values []int64) *cyclic.IntBuffer {
+	buf := g.NewIntBuffer(uint32(len(values)), g.NewInt(1))
+	for i, v := range values {
+		g.SetLargeInt(buf.Get(uint32(i)), large.NewInt(v))
+	}
+	return buf
+}
+
+func intBufferToInt64s(buf *cyclic.IntBuffer) []int64 {
+	values := make([]int64, buf.Len())
+	for i := range values {
+		values[i] = buf.Get(uint32(i)).GetLargeInt().Int64()
+	}
+	return values
+}
+
+// testDLEQVectors checks the proofs against fixed vectors
+func testDLEQVectors(t *testing.T, p *StreamPool) {
+	g := makeDLEQVectorGroup()
+	h := g.NewInt(9)
+	numSlots := uint32(len(dleqVectorSecrets))
+	x := intBufferFromInt64s(g, dleqVectorSecrets)
+	a := g.NewIntBuffer(numSlots, g.NewInt(1))
+	b := g.NewIntBuffer(numSlots, g.NewInt(1))
+	proofs := NewDLEQProofs(g, numSlots)
+
+	err := ProveDLEQChunk(p, g, h, x, a, b, proofs, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := struct {
+		a, b, t1, t2, s []int64
+	}{intBufferToInt64s(a), intBufferToInt64s(b), intBufferToInt64s(proofs.T1),
+		intBufferToInt64s(proofs.T2), intBufferToInt64s(proofs.S)}
+	if !reflect.DeepEqual(got, dleqVectorExpected) {
+		t.Errorf("Proofs didn't match test vectors.\nGot:      %+v\nExpected: %+v", got, dleqVectorExpected)
+	}
+
+	failed, err := VerifyDLEQChunk(p, g, large.NewInt(233), h, a, b, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("Test vector proofs failed in slots %v", failed)
+	}
+}
+
+// testDLEQ makes proofs for random secrets in a realistic group, and checks
+// that honest proofs pass and tampered proofs fail
+func testDLEQ(t *testing.T, p *StreamPool) {
+	const numSlots = 8
+	g := makeTestGroup4096()
+	q := large.NewInt(0).RightShift(g.GetPSub1(), 1)
+	rng := rand.New(rand.NewSource(42))
+	// Squares are in the subgroup of order q
+	h := g.NewInt(9)
+	x := g.NewIntBuffer(numSlots, g.NewInt(1))
+	for i := uint32(0); i < numSlots; i++ {
+		secret, err := randomExponent(g, rng, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setExponent(g, x.Get(i), secret)
+	}
+	a := g.NewIntBuffer(numSlots, g.NewInt(1))
+	b := g.NewIntBuffer(numSlots, g.NewInt(1))
+	proofs := NewDLEQProofs(g, numSlots)
+
+	err := ProveDLEQChunk(p, g, h, x, a, b, proofs, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < numSlots; i++ {
+		expectedA := g.Exp(g.NewIntFromLargeInt(g.GetG()), x.Get(i), g.NewInt(1))
+		if expectedA.Cmp(a.Get(i)) != 0 {
+			t.Errorf("a wasn't g**x in slot %v", i)
+		}
+		expectedB := g.Exp(h, x.Get(i), g.NewInt(1))
+		if expectedB.Cmp(b.Get(i)) != 0 {
+			t.Errorf("b wasn't h**x in slot %v", i)
+		}
+	}
+
+	failed, err := VerifyDLEQChunk(p, g, q, h, a, b, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("Honest proofs failed in slots %v", failed)
+	}
+	ok, err := BatchVerifyDLEQChunk(p, g, q, h, a, b, proofs, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("Honest proofs failed batch verification")
+	}
+
+	// Claim a different exponent for b in one slot
+	g.Mul(b.Get(3), h, b.Get(3))
+	failed, err = VerifyDLEQChunk(p, g, q, h, a, b, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failed, []uint32{3}) {
+		t.Errorf("Only the tampered slot should fail, but got %v", failed)
+	}
+	ok, err = BatchVerifyDLEQChunk(p, g, q, h, a, b, proofs, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("Tampered proofs passed batch verification")
+	}
+
+	// Flipping the sign of b multiplies it by an element of order 2, which
+	// the checks can't see, so it has to be caught by the subgroup check
+	g.Mul(b.Get(3), g.GetPSub1Cyclic(), b.Get(3))
+	g.Mul(b.Get(5), g.GetPSub1Cyclic(), b.Get(5))
+	failed, err = VerifyDLEQChunk(p, g, q, h, a, b, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failed, []uint32{3, 5}) {
+		t.Errorf("Slots with b outside the subgroup should fail, but got %v", failed)
+	}
+	for i := 0; i < 8; i++ {
+		ok, err = BatchVerifyDLEQChunk(p, g, q, h, a, b, proofs, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Fatal("Proofs with b outside the subgroup passed batch verification")
+		}
+	}
+
+	_, err = VerifyDLEQChunk(p, g, q, g.GetPSub1Cyclic(), a, b, proofs)
+	if err == nil {
+		t.Error("h outside the subgroup should be an error")
+	}
+	_, err = BatchVerifyDLEQChunk(p, g, nil, h, a, b, proofs, rng)
+	if err == nil {
+		t.Error("Batch verification without q should be an error")
+	}
+}
+
+// A proof with a response of 0 should still verify
+func testDLEQZeroResponse(t *testing.T, p *StreamPool) {
+	g := makeDLEQVectorGroup()
+	q := large.NewInt(233)
+	h := g.NewInt(9)
+	// With x = 0, s = k - c*x is the nonce, and a nonce of 0 makes it 0
+	x := g.NewIntBuffer(1, g.NewInt(1))
+	setExponent(g, x.Get(0), large.NewInt(0))
+	a := g.NewIntBuffer(1, g.NewInt(1))
+	b := g.NewIntBuffer(1, g.NewInt(1))
+	proofs := NewDLEQProofs(g, 1)
+	err := ProveDLEQChunk(p, g, h, x, a, b, proofs, bytes.NewReader(
+		make([]byte, len(g.GetPBytes())+8+dleqCoefficientLen)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proofs.S.Get(0).GetLargeInt().BitLen() != 0 {
+		t.Fatalf("Response was %v, expected 0", proofs.S.Get(0).Text(10))
+	}
+	failed, err := VerifyDLEQChunk(p, g, q, h, a, b, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("Proof with a zero response failed")
+	}
+	ok, err := BatchVerifyDLEQChunk(p, g, q, h, a, b, proofs,
+		rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("Proof with a zero response failed batch verification")
+	}
+}
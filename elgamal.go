@@ -14,8 +14,8 @@ import "gitlab.com/elixxir/crypto/cyclic"
 // and is marked to require `-tags cuda` in your build.
 // ElGamalChunkPrototyp is the type necessary to implement cryptop interface
 type ElGamalChunkPrototype func(p *StreamPool, g *cyclic.Group,
-	key, privateKey *cyclic.IntBuffer, publicCypherKey *cyclic.Int,
-	ecrKey, cypher *cyclic.IntBuffer) error
+	key, privateKey Operands, publicCypherKey *cyclic.Int,
+	ecrKey, cypher Operands) error
 
 // GetInputSize returns the chunk size for the op
 func (ElGamalChunkPrototype) GetInputSize() uint32 {
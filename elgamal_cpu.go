@@ -9,12 +9,5 @@
 
 package gpumaths
 
-import (
-	"errors"
-	"gitlab.com/elixxir/crypto/cyclic"
-)
-
-// ElGamalChunk is stubbed unless GPU is present.
-var ElGamalChunk ElGamalChunkPrototype = func(p *StreamPool, g *cyclic.Group, key, privateKey *cyclic.IntBuffer, publicCypherKey *cyclic.Int, ecrKey, cypher *cyclic.IntBuffer) error {
-	return errors.New(NoGpuErrStr)
-}
+// ElGamalChunk runs ElGamal for every slot on the CPU
+var ElGamalChunk ElGamalChunkPrototype = cpuElGamalChunk
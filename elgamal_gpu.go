@@ -31,15 +31,18 @@ const (
 // Precondition: All int buffers must have the same length
 // Perform the ElGamal operation on two int buffers
 var ElGamalChunk ElGamalChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	key, privateKey *cyclic.IntBuffer, publicCypherKey *cyclic.Int,
-	ecrKey, cypher *cyclic.IntBuffer) error {
+	key, privateKey Operands, publicCypherKey *cyclic.Int,
+	ecrKey, cypher Operands) error {
 	// Populate ElGamal inputs
 	numSlots := uint32(ecrKey.Len())
 
 	env := chooseEnv(g)
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
+	stream, err := p.TakeStreamFitting(env.streamSizeContaining(int(numSlots), kernelElgamal))
+	if err != nil {
+		return err
+	}
 	defer p.ReturnStream(stream)
 	maxSlotsElGamal := uint32(env.maxSlots(len(stream.cpuData), kernelElgamal))
 	if numSlots > maxSlotsElGamal {
@@ -53,14 +56,19 @@ var ElGamalChunk ElGamalChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 		} else {
 			sliceEnd = numSlots
 		}
-		err := <-elGamal(g, key.GetSubBuffer(i, sliceEnd), privateKey.GetSubBuffer(i, sliceEnd),
-			publicCypherKey, ecrKey.GetSubBuffer(i, sliceEnd), cypher.GetSubBuffer(i, sliceEnd), env, stream)
+		err := <-elGamal(g, SubRange(key, i, sliceEnd), SubRange(privateKey, i, sliceEnd),
+			publicCypherKey, SubRange(ecrKey, i, sliceEnd), SubRange(cypher, i, sliceEnd), env, stream)
 		if err != nil {
+			p.markFailed(stream, err)
 			return err
 		}
 	}
 
-	return nil
+	// Wipe the private keys from the device as well. Unlike the other ops'
+	// inputs, the private keys are secret whatever the caller uses them for,
+	// so this doesn't wait for a view with secret inputs.
+	return p.wipeInputs(env, g, stream, kernelElgamal, int(numSlots),
+		g.GetG().Bits(), g.GetP().Bits(), publicCypherKey.Bits())
 }
 
 // ElGamal runs the op on the GPU
@@ -70,8 +78,8 @@ var ElGamalChunk ElGamalChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 // equal to the length of the template-instantiated BN on the GPU.
 // bnLength is a length in bits
 // TODO validate BN length in code (i.e. pick kernel variants based on bn length)
-func elGamal(g *cyclic.Group, key, privateKey *cyclic.IntBuffer, publicCypherKey *cyclic.Int,
-	ecrKey, cypher *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
+func elGamal(g *cyclic.Group, key, privateKey Operands, publicCypherKey *cyclic.Int,
+	ecrKey, cypher Operands, env gpumathsEnv, stream Stream) chan error {
 	// Return the result later, when the GPU job finishes
 	resultChan := make(chan error, 1)
 	go func() {
@@ -81,38 +89,18 @@ func elGamal(g *cyclic.Group, key, privateKey *cyclic.IntBuffer, publicCypherKey
 		// TODO clean this up by implementing the
 		// arrangement/dearrangement with reader/writer interfaces
 		//  or smth
-		constants := stream.getCpuConstantsWords(env, kernelElgamal)
-		offset := 0
+		stream.putConstants(env, kernelElgamal, g.GetG().Bits(),
+			g.GetP().Bits(), publicCypherKey.Bits())
 		bnLengthWords := env.getWordLen()
-		putBits(constants[offset:offset+bnLengthWords], g.GetG().Bits(),
-			bnLengthWords)
-		offset += bnLengthWords
-		putBits(constants[offset:offset+bnLengthWords],
-			g.GetP().Bits(), bnLengthWords)
-		offset += bnLengthWords
-		putBits(constants[offset:offset+bnLengthWords],
-			publicCypherKey.Bits(), bnLengthWords)
 
 		inputs := stream.getCpuInputsWords(env, kernelElgamal, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			putBits(inputs[offset:offset+bnLengthWords],
-				privateKey.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			putBits(inputs[offset:offset+bnLengthWords],
-				key.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			putBits(inputs[offset:offset+bnLengthWords],
-				ecrKey.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			putBits(inputs[offset:offset+bnLengthWords],
-				cypher.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
+		packInputs(inputs, bnLengthWords, numSlots, privateKey, key, ecrKey,
+			cypher)
 
 		// Upload, run, wait for download
 		err := env.enqueue(stream, kernelElgamal, int(numSlots))
 		if err != nil {
+			stream.zeroCpuInputsWords(env, kernelElgamal, int(numSlots))
 			resultChan <- err
 			return
 		}
@@ -121,21 +109,15 @@ func elGamal(g *cyclic.Group, key, privateKey *cyclic.IntBuffer, publicCypherKey
 
 		// Wait on things to finish with Cuda
 		err = get(stream)
+		// The private keys shouldn't outlive the upload
+		stream.zeroCpuInputsWords(env, kernelElgamal, int(numSlots))
 		if err != nil {
 			resultChan <- err
 			return
 		}
 
 		// Everything is OK, so let's go ahead and import the results
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			end := offset + bnLengthWords
-			g.OverwriteBits(ecrKey.Get(i), results[offset:end])
-			offset += bnLengthWords
-			end = offset + bnLengthWords
-			g.OverwriteBits(cypher.Get(i), results[offset:end])
-			offset += bnLengthWords
-		}
+		unpackOutputs(g, results, bnLengthWords, numSlots, ecrKey, cypher)
 
 		resultChan <- nil
 	}()
@@ -10,6 +10,7 @@
 package gpumaths
 
 import (
+	"context"
 	"gitlab.com/elixxir/crypto/cryptops"
 	"gitlab.com/elixxir/crypto/cyclic"
 	"testing"
@@ -203,7 +204,10 @@ func BenchmarkElGamalCUDA4096_256_streams(b *testing.B) {
 		ecrKey := initRandomIntBuffer(g, uint32(numItemsToUpload), 43, xByteLen)
 		cypher := initRandomIntBuffer(g, uint32(numItemsToUpload), 44, xByteLen)
 		privateKey := initRandomIntBuffer(g, uint32(numItemsToUpload), 45, yByteLen)
-		stream := streamPool.TakeStream()
+		stream, err := streamPool.TakeStream()
+		if err != nil {
+			b.Fatal(err)
+		}
 		resultChan := elGamal(g, key, privateKey, PublicCypherKey, ecrKey, cypher, env, stream)
 		go func() {
 			err := <-resultChan
@@ -213,11 +217,10 @@ func BenchmarkElGamalCUDA4096_256_streams(b *testing.B) {
 			}
 		}()
 	}
-	// Empty the pool to make sure results have all been downloaded
-	streamPool.TakeStream()
-	streamPool.TakeStream()
+	// Wait for the streams to come back to make sure results have all been
+	// downloaded
+	err = streamPool.Close(context.Background())
 	b.StopTimer()
-	err = streamPool.Destroy()
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -14,8 +14,12 @@ import "gitlab.com/elixxir/crypto/cyclic"
 // and is marked to require `-tags cuda` in your build.
 
 // ExpChunkPrototype Implement cryptop interface for ExpChunk
+// The operands can be any Operands, but the result is still returned as an
+// IntBuffer so callers written before the operands were generalized keep
+// working. It's z when z is an *cyclic.IntBuffer, and nil for other views,
+// whose results are only written through z.
 type ExpChunkPrototype func(p *StreamPool, g *cyclic.Group,
-	x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error)
+	x, y, z Operands) (*cyclic.IntBuffer, error)
 
 // GetName returns name of op (ExpChunk)
 func (ExpChunkPrototype) GetName() string {
@@ -26,3 +30,9 @@ func (ExpChunkPrototype) GetName() string {
 func (ExpChunkPrototype) GetInputSize() uint32 {
 	return 64
 }
+
+// expResult is what ExpChunk returns for z
+func expResult(z Operands) *cyclic.IntBuffer {
+	buf, _ := z.(*cyclic.IntBuffer)
+	return buf
+}
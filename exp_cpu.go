@@ -9,13 +9,5 @@
 
 package gpumaths
 
-import (
-	"errors"
-	"gitlab.com/elixxir/crypto/cyclic"
-)
-
-// ExpChunk is stubbed unless GPU is present.
-var ExpChunk ExpChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error) {
-	return z, errors.New(NoGpuErrStr)
-}
+// ExpChunk computes z = x**y for every slot on the CPU
+var ExpChunk ExpChunkPrototype = cpuExpChunk
@@ -32,15 +32,18 @@ const (
 // Using this function doesn't allow you to do other things while waiting
 // on the kernel to finish
 var ExpChunk ExpChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	x, y, z *cyclic.IntBuffer) (*cyclic.IntBuffer, error) {
+	x, y, z Operands) (*cyclic.IntBuffer, error) {
 	// Populate exp inputs
 	numSlots := uint32(z.Len())
 
 	// Run kernel on the inputs, simply using smaller chunks if passed
 	// chunk size exceeds buffer space in stream
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
 	env := chooseEnv(g)
+	stream, err := p.TakeStreamFitting(env.streamSizeContaining(int(numSlots), kernelPowmOdd))
+	if err != nil {
+		return nil, err
+	}
+	defer p.ReturnStream(stream)
 	maxSlotsExp := uint32(env.maxSlots(len(stream.cpuData), kernelPowmOdd))
 	if numSlots > maxSlotsExp {
 		jww.WARN.Printf("Running multiple kernels for ExpChunk. Performance may be degraded")
@@ -53,17 +56,26 @@ var ExpChunk ExpChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 		} else {
 			sliceEnd = numSlots
 		}
-		err := <-exp(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), z.GetSubBuffer(i, sliceEnd), env, stream)
+		err := <-exp(g, SubRange(x, i, sliceEnd), SubRange(y, i, sliceEnd), SubRange(z, i, sliceEnd), env, stream)
+		if err != nil {
+			p.markFailed(stream, err)
+			return nil, err
+		}
+	}
+
+	if p.SecretInputs() {
+		err = p.wipeInputs(env, g, stream, kernelPowmOdd, int(numSlots),
+			g.GetP().Bits())
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// If there were no errors, we return z
-	return z, nil
+	return expResult(z), nil
 }
 
-func exp(g *cyclic.Group, x, y, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
+func exp(g *cyclic.Group, x, y, result Operands, env gpumathsEnv, stream Stream) chan error {
 	// Return the result later, when the GPU job finishes
 	resultChan := make(chan error, 1)
 	go func() {
@@ -73,23 +85,16 @@ func exp(g *cyclic.Group, x, y, result *cyclic.IntBuffer, env gpumathsEnv, strea
 		// TODO clean this up by implementing the
 		// arrangement/dearrangement with reader/writer interfaces
 		// or smth
-		constants := stream.getCpuConstantsWords(env, kernelPowmOdd)
-		offset := 0
+		stream.putConstants(env, kernelPowmOdd, g.GetP().Bits())
 		bnLengthWords := env.getWordLen()
-		putBits(constants, g.GetP().Bits(), bnLengthWords)
 
 		inputs := stream.getCpuInputsWords(env, kernelPowmOdd, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			putBits(inputs[offset:offset+bnLengthWords], x.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			putBits(inputs[offset:offset+bnLengthWords], y.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
+		packInputs(inputs, bnLengthWords, numSlots, x, y)
 
 		// Upload, run, wait for download
 		err := env.enqueue(stream, kernelPowmOdd, int(numSlots))
 		if err != nil {
+			stream.zeroCpuInputsWords(env, kernelPowmOdd, int(numSlots))
 			resultChan <- err
 			return
 		}
@@ -100,18 +105,15 @@ func exp(g *cyclic.Group, x, y, result *cyclic.IntBuffer, env gpumathsEnv, strea
 
 		// Wait on things to finish with Cuda
 		err = get(stream)
+		// Exponents are often secret, so they shouldn't outlive the upload
+		stream.zeroCpuInputsWords(env, kernelPowmOdd, int(numSlots))
 		if err != nil {
 			resultChan <- err
 			return
 		}
 
 		// Everything is OK, so let's go ahead and import the results
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			end := offset + bnLengthWords
-			g.OverwriteBits(result.Get(i), results[offset:end])
-			offset += bnLengthWords
-		}
+		unpackOutputs(g, results, bnLengthWords, numSlots, result)
 
 		resultChan <- nil
 	}()
@@ -10,6 +10,7 @@
 package gpumaths
 
 import (
+	"context"
 	"gitlab.com/elixxir/crypto/cryptops"
 	"gitlab.com/elixxir/crypto/cyclic"
 	"testing"
@@ -235,7 +236,10 @@ func BenchmarkPowmCUDA2048_256_streams(b *testing.B) {
 		base := initRandomIntBuffer(g, uint32(numItemsToUpload), 42, 0)
 		exponent := initRandomIntBuffer(g, uint32(numItemsToUpload), 42, yByteLen)
 		results := g.NewIntBuffer(uint32(numItemsToUpload), g.NewInt(1))
-		stream := streamPool.TakeStream()
+		stream, err := streamPool.TakeStream()
+		if err != nil {
+			b.Fatal(err)
+		}
 		errChan := exp(g, base, exponent, results, env, stream)
 		go func() {
 			err := <-errChan
@@ -245,11 +249,10 @@ func BenchmarkPowmCUDA2048_256_streams(b *testing.B) {
 			}
 		}()
 	}
-	// Empty the pool to make sure results have all been downloaded
-	streamPool.TakeStream()
-	streamPool.TakeStream()
+	// Wait for the streams to come back to make sure results have all been
+	// downloaded
+	err = streamPool.Close(context.Background())
 	b.StopTimer()
-	err = streamPool.Destroy()
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -289,7 +292,10 @@ func BenchmarkPowmCUDA4096_256_streams(b *testing.B) {
 		base := initRandomIntBuffer(g, uint32(numItemsToUpload), 42, 0)
 		exponent := initRandomIntBuffer(g, uint32(numItemsToUpload), 42, yByteLen)
 		results := g.NewIntBuffer(uint32(numItemsToUpload), g.NewInt(1))
-		stream := streamPool.TakeStream()
+		stream, err := streamPool.TakeStream()
+		if err != nil {
+			b.Fatal(err)
+		}
 		errChan := exp(g, base, exponent, results, env, stream)
 		go func() {
 			err := <-errChan
@@ -299,11 +305,10 @@ func BenchmarkPowmCUDA4096_256_streams(b *testing.B) {
 			}
 		}()
 	}
-	// Empty the pool to make sure results have all been downloaded
-	streamPool.TakeStream()
-	streamPool.TakeStream()
+	// Wait for the streams to come back to make sure results have all been
+	// downloaded
+	err = streamPool.Close(context.Background())
 	b.StopTimer()
-	err = streamPool.Destroy()
 	if err != nil {
 		b.Fatal(err)
 	}
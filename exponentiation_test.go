@@ -30,7 +30,10 @@ func TestPowm4096(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	stream := streamPool.TakeStream()
+	stream, err := streamPool.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
 	errors := exp(g, Base, Exponent, Result, env, stream)
 	err = <-errors
 	if err != nil {
@@ -79,7 +82,10 @@ func TestElgamal4096(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	stream := streamPool.TakeStream()
+	stream, err := streamPool.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
 	// I think I want to actually pass a stream to ElGamal...
 	// Is that too explicit/weird?
 	resultChan := elGamal(g, Key, PrivateKey, PublicCypherKey, EcrKey, Cypher, env, stream)
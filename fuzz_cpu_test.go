@@ -0,0 +1,22 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"testing"
+)
+
+// newFuzzStreamPool returns nil, because the CPU ops don't use streams
+// The GPU ops' packing is covered by emulateKernel instead.
+func newFuzzStreamPool(t *testing.T, g *cyclic.Group, op string,
+	streamSlots int) *StreamPool {
+	return nil
+}
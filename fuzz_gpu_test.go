@@ -0,0 +1,42 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"testing"
+)
+
+// newFuzzStreamPool makes a pool with one stream that fits exactly
+// streamSlots slots of the op in one kernel launch, so bigger cases are
+// split into sub-chunks
+// Ops that build on the kernels pass the kernel op that they launch most.
+func newFuzzStreamPool(t *testing.T, g *cyclic.Group, op string,
+	streamSlots int) *StreamPool {
+	env := chooseEnv(g)
+	kernel := opKernels[op]
+	memSize := env.streamSizeContaining(streamSlots, int(kernel))
+	if maxSlots := env.maxSlots(memSize, kernel); maxSlots != streamSlots {
+		t.Fatalf("Stream for %v slots of %v fits %v", streamSlots, op,
+			maxSlots)
+	}
+	streams, err := createStreams(1, memSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newStreamPool(streams)
+	t.Cleanup(func() {
+		err := p.Destroy()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	return p
+}
@@ -0,0 +1,630 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+// fuzz_test.go compares every op with the elixxir/crypto implementations on
+// random groups, slot counts and stream sizes. The GPU build runs the ops
+// on streams from fuzz_gpu_test.go, which are sized so that slot counts
+// land on and around the sub-chunk boundaries, and the CPU build runs them
+// without a pool.
+// The kernel ops also run through emulateKernel in both builds, which packs
+// and unpacks their operands the way the GPU ops do without CUDA, so the
+// packing's zero padding and sub-chunk boundaries are covered by the CPU
+// build too.
+// Run a target with e.g. go test -run XXX -fuzz FuzzExpChunk
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Most slots and slots per stream that a fuzz case can have
+const maxFuzzSlots = 64
+
+var fuzzGroupsOnce sync.Once
+var fuzzGroups []*cyclic.Group
+
+// getFuzzGroups returns groups for every environment, with primes that
+// don't fill their environment as well as ones that do
+func getFuzzGroups() []*cyclic.Group {
+	fuzzGroupsOnce.Do(func() {
+		// Mersenne primes, so there's nothing to generate
+		for _, bits := range []uint{127, 521, 1279, 2203, 2281, 3217} {
+			p := large.NewInt(1).LeftShift(large.NewInt(1), bits)
+			p.Sub(p, large.NewInt(1))
+			fuzzGroups = append(fuzzGroups, cyclic.NewGroup(p,
+				large.NewInt(3)))
+		}
+		fuzzGroups = append(fuzzGroups, makeTestGroup2048(),
+			makeTestGroup4096())
+	})
+	return fuzzGroups
+}
+
+// fuzzCase is the group, sizes and randomness that a fuzz input selects
+type fuzzCase struct {
+	g        *cyclic.Group
+	numSlots uint32
+	// Slots that the stream fits in one kernel launch
+	streamSlots int
+	rng         *rand.Rand
+}
+
+func newFuzzCase(seed int64, group uint8, numSlots,
+	streamSlots uint16) fuzzCase {
+	groups := getFuzzGroups()
+	return fuzzCase{
+		g:           groups[int(group)%len(groups)],
+		numSlots:    uint32(numSlots)%maxFuzzSlots + 1,
+		streamSlots: int(streamSlots)%maxFuzzSlots + 1,
+		rng:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Seeds for every target. The slot counts are one less than the case's,
+// and cover one slot, exactly one, two and three full streams, and a
+// partial last chunk, for primes in every environment.
+func addFuzzSeeds(f *testing.F) {
+	f.Add(int64(42), uint8(0), uint16(11), uint16(11))
+	f.Add(int64(42), uint8(6), uint16(19), uint16(9))
+	f.Add(int64(1), uint8(3), uint16(0), uint16(0))
+	f.Add(int64(2), uint8(1), uint16(20), uint16(6))
+	f.Add(int64(3), uint8(5), uint16(7), uint16(3))
+	f.Add(int64(4), uint8(7), uint16(4), uint16(1))
+}
+
+// operand makes a buffer of edge cases and random values. Zero isn't in the
+// group, so it's only used if allowZero is set, e.g. for exponents.
+func (c fuzzCase) operand(allowZero bool) *cyclic.IntBuffer {
+	g := c.g
+	result := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+	width := len(g.GetPBytes())
+	pMinus1 := large.NewInt(0).Sub(g.GetP(), large.NewInt(1))
+	for i := uint32(0); i < c.numSlots; i++ {
+		var b []byte
+		switch c.rng.Intn(6) {
+		case 0:
+			g.SetLargeInt(result.Get(i), pMinus1)
+			continue
+		case 1:
+			g.SetLargeInt(result.Get(i), large.NewInt(1))
+			continue
+		case 2:
+			// Fits in one word, so the rest of the words are zero
+			b = make([]byte, 1+c.rng.Intn(8))
+		case 3:
+			// Any number of leading zero bytes
+			b = make([]byte, 1+c.rng.Intn(width))
+		case 4:
+			if allowZero {
+				g.SetUint64(result.Get(i), 0)
+				continue
+			}
+			b = make([]byte, width)
+		default:
+			b = make([]byte, width)
+		}
+		c.rng.Read(b)
+		v := large.NewIntFromBytes(b)
+		v.Mod(v, pMinus1)
+		v.Add(v, large.NewInt(1))
+		g.SetLargeInt(result.Get(i), v)
+	}
+	return result
+}
+
+// coprime returns a random exponent that has a root mod p
+func (c fuzzCase) coprime() *cyclic.Int {
+	for {
+		e := c.g.NewInt(int64(c.rng.Intn(1<<20)) | 1)
+		if checkCoprime(c.g, e, 0) == nil {
+			return e
+		}
+	}
+}
+
+// emulateKernel runs kernel on every slot the way the GPU ops launch one:
+// the slots are split into sub-chunks of the case's stream size, each
+// sub-chunk's inputs are packed into words that still hold the last
+// sub-chunk's, or garbage, and each slot's operands are read back from the
+// words. The results go back through output words and unpackOutputs.
+func (c fuzzCase) emulateKernel(inputs, results []Operands,
+	kernel func(in, out []*cyclic.Int)) {
+	g := c.g
+	wordLen := 0
+	for _, envBits := range []int{2048, 3200, 4096} {
+		if g.GetP().BitLen() <= envBits {
+			wordLen = envBits / bits.UintSize
+			break
+		}
+	}
+	inputWords := make(large.Bits, c.streamSlots*len(inputs)*wordLen)
+	outputWords := make(large.Bits, c.streamSlots*len(results)*wordLen)
+	for i := range inputWords {
+		inputWords[i] = ^big.Word(0)
+	}
+	for i := range outputWords {
+		outputWords[i] = ^big.Word(0)
+	}
+	in := make([]*cyclic.Int, len(inputs))
+	out := make([]*cyclic.Int, len(results))
+	for i := range in {
+		in[i] = g.NewInt(1)
+	}
+	for i := range out {
+		out[i] = g.NewInt(1)
+	}
+
+	for begin := uint32(0); begin < c.numSlots; begin += uint32(c.streamSlots) {
+		end := begin + uint32(c.streamSlots)
+		if end > c.numSlots {
+			end = c.numSlots
+		}
+		chunkInputs := make([]Operands, len(inputs))
+		for i := range inputs {
+			chunkInputs[i] = SubRange(inputs[i], begin, end)
+		}
+		chunkResults := make([]Operands, len(results))
+		for i := range results {
+			chunkResults[i] = SubRange(results[i], begin, end)
+		}
+		packInputs(inputWords, wordLen, end-begin, chunkInputs...)
+		for slot := 0; slot < int(end-begin); slot++ {
+			// Zero exponents are outside the group, so the ints are
+			// overwritten instead of made from the words
+			for i := range in {
+				offset := (slot*len(inputs) + i) * wordLen
+				g.OverwriteBits(in[i], inputWords[offset:offset+wordLen])
+			}
+			kernel(in, out)
+			for i := range out {
+				offset := (slot*len(results) + i) * wordLen
+				putBits(outputWords[offset:offset+wordLen], out[i].Bits(),
+					wordLen)
+			}
+		}
+		unpackOutputs(g, outputWords, wordLen, end-begin, chunkResults...)
+	}
+}
+
+// checkFuzzResult reports every slot where the op's result differs from the
+// expected one
+func checkFuzzResult(t *testing.T, c fuzzCase, name string, expected,
+	actual Operands) {
+	var mismatches []uint32
+	for i := uint32(0); i < c.numSlots; i++ {
+		if expected.Get(i).Cmp(actual.Get(i)) != 0 {
+			mismatches = append(mismatches, i)
+		}
+	}
+	if len(mismatches) > 0 {
+		t.Errorf("%v differs from cryptops in slots %v of %v, with a "+
+			"%v-bit prime and %v slots per stream", name, mismatches,
+			c.numSlots, c.g.GetP().BitLen(), c.streamSlots)
+	}
+}
+
+func FuzzExpChunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		x := c.operand(false)
+		y := c.operand(true)
+		expected := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		for i := uint32(0); i < c.numSlots; i++ {
+			cryptops.Exp(g, x.Get(i), y.Get(i), expected.Get(i))
+		}
+
+		p := newFuzzStreamPool(t, g, ExpChunk.GetName(), c.streamSlots)
+		z := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		_, err := ExpChunk(p, g, x, y, z)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "z", expected, z)
+
+		emulated := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		c.emulateKernel([]Operands{x, y}, []Operands{emulated},
+			func(in, out []*cyclic.Int) {
+				cryptops.Exp(g, in[0], in[1], out[0])
+			})
+		checkFuzzResult(t, c, "emulated z", expected, emulated)
+	})
+}
+
+func FuzzElGamalChunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		key := c.operand(false)
+		privateKey := c.operand(true)
+		publicCypherKey := c.operand(false).Get(0)
+		ecrKey := c.operand(false)
+		cypher := c.operand(false)
+		expectedEcrKey := ecrKey.DeepCopy()
+		expectedCypher := cypher.DeepCopy()
+		for i := uint32(0); i < c.numSlots; i++ {
+			cryptops.ElGamal(g, key.Get(i), privateKey.Get(i),
+				publicCypherKey, expectedEcrKey.Get(i), expectedCypher.Get(i))
+		}
+
+		// The kernel takes the private key first, like ElGamalChunk packs it
+		emulatedEcrKey := ecrKey.DeepCopy()
+		emulatedCypher := cypher.DeepCopy()
+		c.emulateKernel([]Operands{privateKey, key, ecrKey, cypher},
+			[]Operands{emulatedEcrKey, emulatedCypher},
+			func(in, out []*cyclic.Int) {
+				g.Set(out[0], in[2])
+				g.Set(out[1], in[3])
+				cryptops.ElGamal(g, in[1], in[0], publicCypherKey, out[0],
+					out[1])
+			})
+		checkFuzzResult(t, c, "emulated ecrKey", expectedEcrKey,
+			emulatedEcrKey)
+		checkFuzzResult(t, c, "emulated cypher", expectedCypher,
+			emulatedCypher)
+
+		p := newFuzzStreamPool(t, g, ElGamalChunk.GetName(), c.streamSlots)
+		err := ElGamalChunk(p, g, key, privateKey, publicCypherKey, ecrKey,
+			cypher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "ecrKey", expectedEcrKey, ecrKey)
+		checkFuzzResult(t, c, "cypher", expectedCypher, cypher)
+	})
+}
+
+func FuzzRevealChunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		publicCypherKey := c.coprime()
+		cypher := c.operand(false)
+		expected := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		for i := uint32(0); i < c.numSlots; i++ {
+			cryptops.RootCoprime(g, cypher.Get(i), publicCypherKey,
+				expected.Get(i))
+		}
+
+		p := newFuzzStreamPool(t, g, RevealChunk.GetName(), c.streamSlots)
+		result := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		err := RevealChunk(p, g, publicCypherKey, cypher, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "result", expected, result)
+
+		emulated := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		c.emulateKernel([]Operands{cypher}, []Operands{emulated},
+			func(in, out []*cyclic.Int) {
+				cryptops.RootCoprime(g, in[0], publicCypherKey, out[0])
+			})
+		checkFuzzResult(t, c, "emulated result", expected, emulated)
+	})
+}
+
+func FuzzMul2Chunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		x := c.operand(false)
+		y := c.operand(false)
+		expected := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		for i := uint32(0); i < c.numSlots; i++ {
+			g.Set(expected.Get(i), y.Get(i))
+			cryptops.Mul2(g, x.Get(i), expected.Get(i))
+		}
+
+		p := newFuzzStreamPool(t, g, Mul2Chunk.GetName(), c.streamSlots)
+		result := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		err := Mul2Chunk(p, g, x, y, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "result", expected, result)
+
+		emulated := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		c.emulateKernel([]Operands{x, y}, []Operands{emulated},
+			func(in, out []*cyclic.Int) {
+				g.Mul(in[0], in[1], out[0])
+			})
+		checkFuzzResult(t, c, "emulated result", expected, emulated)
+	})
+}
+
+func FuzzMul3Chunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		x := c.operand(false)
+		y := c.operand(false)
+		z := c.operand(false)
+		expected := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		for i := uint32(0); i < c.numSlots; i++ {
+			g.Set(expected.Get(i), z.Get(i))
+			cryptops.Mul3(g, x.Get(i), y.Get(i), expected.Get(i))
+		}
+
+		p := newFuzzStreamPool(t, g, Mul3Chunk.GetName(), c.streamSlots)
+		result := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		err := Mul3Chunk(p, g, x, y, z, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "result", expected, result)
+
+		emulated := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		c.emulateKernel([]Operands{x, y, z}, []Operands{emulated},
+			func(in, out []*cyclic.Int) {
+				g.Set(out[0], in[2])
+				cryptops.Mul3(g, in[0], in[1], out[0])
+			})
+		checkFuzzResult(t, c, "emulated result", expected, emulated)
+	})
+}
+
+func FuzzMul2Slice(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		x := c.operand(false)
+		y := c.operand(false)
+		expected := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		ySlice := make([]*cyclic.Int, c.numSlots)
+		result := make([]*cyclic.Int, c.numSlots)
+		for i := uint32(0); i < c.numSlots; i++ {
+			g.Set(expected.Get(i), y.Get(i))
+			cryptops.Mul2(g, x.Get(i), expected.Get(i))
+			ySlice[i] = y.Get(i)
+			result[i] = g.NewInt(1)
+		}
+
+		p := newFuzzStreamPool(t, g, Mul2Chunk.GetName(), c.streamSlots)
+		err := Mul2Slice(p, g, x, ySlice, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "result", expected, IntSlice(result))
+	})
+}
+
+func FuzzPermuteMul2Chunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		x := c.operand(false)
+		y := c.operand(false)
+		permutation := make([]uint32, c.numSlots)
+		for i, src := range c.rng.Perm(int(c.numSlots)) {
+			permutation[i] = uint32(src)
+		}
+		expected := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		for i := uint32(0); i < c.numSlots; i++ {
+			g.Set(expected.Get(i), y.Get(i))
+			cryptops.Mul2(g, x.Get(permutation[i]), expected.Get(i))
+		}
+
+		p := newFuzzStreamPool(t, g, Mul2Chunk.GetName(), c.streamSlots)
+		results := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		err := PermuteMul2Chunk(p, g, x, y, permutation, results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "results", expected, results)
+	})
+}
+
+func FuzzReduceProductChunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		x := c.operand(false)
+		expected := g.NewInt(1)
+		for i := uint32(0); i < c.numSlots; i++ {
+			cryptops.Mul2(g, x.Get(i), expected)
+		}
+
+		p := newFuzzStreamPool(t, g, Mul2Chunk.GetName(), c.streamSlots)
+		product, err := ReduceProductChunk(p, g, x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if product.Cmp(expected) != 0 {
+			t.Errorf("Product of %v slots differs from cryptops, with a "+
+				"%v-bit prime and %v slots per stream", c.numSlots,
+				g.GetP().BitLen(), c.streamSlots)
+		}
+	})
+}
+
+func FuzzRootChunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		x := c.operand(false)
+		y := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		expected := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		for i := uint32(0); i < c.numSlots; i++ {
+			g.Set(y.Get(i), c.coprime())
+			cryptops.RootCoprime(g, x.Get(i), y.Get(i), expected.Get(i))
+		}
+
+		p := newFuzzStreamPool(t, g, ExpChunk.GetName(), c.streamSlots)
+		result := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		err := RootChunk(p, g, x, y, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "result", expected, result)
+	})
+}
+
+func FuzzRootSharedChunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		y := c.coprime()
+		x := c.operand(false)
+		expected := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		for i := uint32(0); i < c.numSlots; i++ {
+			cryptops.RootCoprime(g, x.Get(i), y, expected.Get(i))
+		}
+
+		p := newFuzzStreamPool(t, g, RevealChunk.GetName(), c.streamSlots)
+		result := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		err := RootSharedChunk(p, g, y, x, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "result", expected, result)
+	})
+}
+
+func FuzzValidateChunk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		// Half of the group's elements are squares, so about half of the
+		// slots pass
+		q := large.NewInt(0).Sub(g.GetP(), large.NewInt(1))
+		q.RightShift(q, 1)
+		x := c.operand(false)
+		// Values that aren't in the group at all
+		for i := uint32(0); i < c.numSlots; i++ {
+			switch c.rng.Intn(8) {
+			case 0:
+				g.SetUint64(x.Get(i), 0)
+			case 1:
+				g.SetBytes(x.Get(i), g.GetP().Bytes())
+			}
+		}
+		var expected []uint32
+		exponent := g.NewIntFromLargeInt(q)
+		power := g.NewInt(1)
+		for i := uint32(0); i < c.numSlots; i++ {
+			if !g.Inside(x.Get(i).GetLargeInt()) {
+				expected = append(expected, i)
+				continue
+			}
+			cryptops.Exp(g, x.Get(i), exponent, power)
+			if power.Cmp(g.NewInt(1)) != 0 {
+				expected = append(expected, i)
+			}
+		}
+
+		p := newFuzzStreamPool(t, g, ExpChunk.GetName(), c.streamSlots)
+		failed, err := ValidateChunk(p, g, q, x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(failed) != len(expected) ||
+			len(failed) > 0 && !reflect.DeepEqual(failed, expected) {
+			t.Errorf("Slots %v failed, but cryptops fails %v, with a "+
+				"%v-bit prime and %v slots per stream", failed, expected,
+				g.GetP().BitLen(), c.streamSlots)
+		}
+	})
+}
+
+// Proofs should verify, and a changed response should fail its slot
+func FuzzDLEQ(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, group uint8, numSlots,
+		streamSlots uint16) {
+		c := newFuzzCase(seed, group, numSlots, streamSlots)
+		g := c.g
+		// Every element's order divides p-1
+		q := large.NewInt(0).Sub(g.GetP(), large.NewInt(1))
+		h := c.operand(false).Get(0)
+		x := c.operand(true)
+		expectedA := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		expectedB := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		for i := uint32(0); i < c.numSlots; i++ {
+			cryptops.Exp(g, g.NewIntFromLargeInt(g.GetG()), x.Get(i),
+				expectedA.Get(i))
+			cryptops.Exp(g, h, x.Get(i), expectedB.Get(i))
+		}
+
+		p := newFuzzStreamPool(t, g, ExpChunk.GetName(), c.streamSlots)
+		a := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		b := g.NewIntBuffer(c.numSlots, g.NewInt(1))
+		proofs := NewDLEQProofs(g, c.numSlots)
+		err := ProveDLEQChunk(p, g, h, x, a, b, proofs, c.rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFuzzResult(t, c, "a", expectedA, a)
+		checkFuzzResult(t, c, "b", expectedB, b)
+
+		failed, err := VerifyDLEQChunk(p, g, q, h, a, b, proofs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(failed) != 0 {
+			t.Errorf("Proofs in slots %v didn't verify", failed)
+		}
+		ok, err := BatchVerifyDLEQChunk(p, g, q, h, a, b, proofs, c.rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Error("Proofs didn't batch verify")
+		}
+
+		changed := uint32(c.rng.Intn(int(c.numSlots)))
+		s := proofs.S.Get(changed).GetLargeInt()
+		s.Add(s, large.NewInt(1))
+		g.SetBytes(proofs.S.Get(changed), s.Mod(s, g.GetPSub1()).Bytes())
+		failed, err = VerifyDLEQChunk(p, g, q, h, a, b, proofs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(failed) != 1 || failed[0] != changed {
+			t.Errorf("Changing slot %v's response failed slots %v",
+				changed, failed)
+		}
+		ok, err = BatchVerifyDLEQChunk(p, g, q, h, a, b, proofs, c.rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Errorf("Changing slot %v's response didn't fail the batch",
+				changed)
+		}
+	})
+}
@@ -204,6 +204,8 @@ func createStreams(numStreams int, capacity int) ([]Stream, error) {
 				s:            createStreamResult.result,
 				cpuData:      toSlice(createStreamResult.cpuBuf, capacity),
 				cpuDataWords: toSliceOfWords(createStreamResult.cpuBuf, int(uintptr(capacity)/unsafe.Sizeof(sizeofOperand[0]))),
+				constants:    &constantsCache{},
+				account:      &streamAccount{},
 			})
 		}
 		// Double free possible here?
@@ -213,8 +215,10 @@ func createStreams(numStreams int, capacity int) ([]Stream, error) {
 	return streams, nil
 }
 
+// Wipes and frees the memory for each of the streams
 func destroyStreams(streams []Stream) error {
 	for i := 0; i < len(streams); i++ {
+		streams[i].zeroCpuData()
 		err := C.destroyStream(streams[i].s)
 		if err != nil {
 			return goError(err)
@@ -235,26 +239,38 @@ func destroyStreams(streams []Stream) error {
 // Could return byte slices of output as well? perhaps?
 func (gpumaths2048) enqueue(stream Stream, whichToRun C.enum_kernel, numSlots int) error {
 	//return errors.New("temporarily disabled due to driver API migration")
+	stream.account.countLaunch(numSlots)
+	stream.beginLaunch(&gpumathsEnv2048, whichToRun, numSlots)
 	uploadError := C.enqueue2048(C.uint(numSlots), stream.s, whichToRun)
 	if uploadError != nil {
-		return goError(uploadError)
+		err := goError(uploadError)
+		stream.endLaunch(err)
+		return err
 	} else {
 		return nil
 	}
 }
 func (gpumaths3200) enqueue(stream Stream, whichToRun C.enum_kernel, numSlots int) error {
 	//return errors.New("temporarily disabled due to driver API migration")
+	stream.account.countLaunch(numSlots)
+	stream.beginLaunch(&gpumathsEnv3200, whichToRun, numSlots)
 	uploadError := C.enqueue3200(C.uint(numSlots), stream.s, whichToRun)
 	if uploadError != nil {
-		return goError(uploadError)
+		err := goError(uploadError)
+		stream.endLaunch(err)
+		return err
 	} else {
 		return nil
 	}
 }
 func (gpumaths4096) enqueue(stream Stream, whichToRun C.enum_kernel, numSlots int) error {
+	stream.account.countLaunch(numSlots)
+	stream.beginLaunch(&gpumathsEnv4096, whichToRun, numSlots)
 	uploadError := C.enqueue4096(C.uint(numSlots), stream.s, whichToRun)
 	if uploadError != nil {
-		return goError(uploadError)
+		err := goError(uploadError)
+		stream.endLaunch(err)
+		return err
 	} else {
 		return nil
 	}
@@ -500,6 +516,7 @@ func (g *gpumaths4096) streamSizeContaining(numItems int, kernel int) int {
 func get(stream Stream) error {
 	cErr := C.getResults(stream.s)
 	err := goError(cErr)
+	stream.endLaunch(err)
 	return err
 }
 
@@ -511,17 +528,12 @@ func get(stream Stream) error {
 //	return err
 //}
 
-// putBits() copies bits from one array to another and right-pads any remaining words with zeroes
-func putBits(dst large.Bits, src large.Bits, n int) {
-	copy(dst, src)
-	for i := len(src); i < len(dst) && i < n; i++ {
-		dst[i] = 0
-	}
-}
-
 func initCuda() error {
 	var err error
 	errString := C.initCuda()
 	err = goError(errString)
+	if err == nil {
+		noteCudaInit()
+	}
 	return err
 }
@@ -0,0 +1,81 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumathstest
+
+import (
+	"fmt"
+	"gitlab.com/elixxir/gpumathsgo"
+	"strings"
+	"testing"
+)
+
+// Most mismatching slots that CheckOperands prints the values of
+const maxReportedSlots = 8
+
+// MismatchedSlots returns the slots where actual differs from expected
+// If one is longer, its extra slots count as mismatches.
+func MismatchedSlots(expected, actual gpumaths.Operands) []uint32 {
+	var result []uint32
+	n := expected.Len()
+	if actual.Len() > n {
+		n = actual.Len()
+	}
+	for i := uint32(0); i < uint32(n); i++ {
+		if int(i) >= expected.Len() || int(i) >= actual.Len() ||
+			expected.Get(i).Cmp(actual.Get(i)) != 0 {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// CheckOperands fails the test if actual differs from expected, listing
+// the mismatching slots and the values of the first few
+func CheckOperands(tb testing.TB, name string, expected,
+	actual gpumaths.Operands) {
+	tb.Helper()
+	mismatches := MismatchedSlots(expected, actual)
+	if len(mismatches) == 0 {
+		return
+	}
+	var report strings.Builder
+	fmt.Fprintf(&report, "%v differs in %v of %v slots: %v", name,
+		len(mismatches), expected.Len(), mismatches)
+	if expected.Len() != actual.Len() {
+		fmt.Fprintf(&report, "\nexpected %v slots, got %v", expected.Len(),
+			actual.Len())
+	}
+	for i, slot := range mismatches {
+		if i == maxReportedSlots {
+			fmt.Fprintf(&report, "\n...")
+			break
+		}
+		if int(slot) >= expected.Len() || int(slot) >= actual.Len() {
+			break
+		}
+		fmt.Fprintf(&report, "\nslot %v: expected %v, got %v", slot,
+			expected.Get(slot).Text(16), actual.Get(slot).Text(16))
+	}
+	tb.Error(report.String())
+}
+
+// CheckSlots fails the test if the slot lists differ, e.g. the failed slots
+// from ValidateChunk and Validate
+func CheckSlots(tb testing.TB, name string, expected, actual []uint32) {
+	tb.Helper()
+	if len(expected) != len(actual) {
+		tb.Errorf("%v: expected slots %v, got %v", name, expected, actual)
+		return
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			tb.Errorf("%v: expected slots %v, got %v", name, expected, actual)
+			return
+		}
+	}
+}
@@ -0,0 +1,66 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// Package gpumathstest has fixtures and reference implementations for
+// testing code that uses gpumaths: standard groups, reproducible random
+// buffers, an implementation of every op that doesn't use gpumaths, helpers
+// that report which slots differ, and stream pools that work in every build.
+package gpumathstest
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// The 4096-bit MODP group from RFC 3526, which servers typically use
+const modp4096 = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+	"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+	"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+	"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9" +
+	"DE2BCBF6955817183995497CEA956AE515D2261898FA0510" +
+	"15728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64" +
+	"ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6B" +
+	"F12FFA06D98A0864D87602733EC86A64521F2B18177B200C" +
+	"BBE117577A615D6C770988C0BAD946E208E24FA074E5AB31" +
+	"43DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+	"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA" +
+	"2583E9CA2AD44CE8DBBBC2DB04DE8EF92E8EFC141FBECAA6" +
+	"287C59474E6BC05D99B2964FA090C3A2233BA186515BE7ED" +
+	"1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+	"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199" +
+	"FFFFFFFFFFFFFFFF"
+
+// A 2048-bit prime that gpumaths has long tested with
+const prime2048 = "F6FAC7E480EE519354C058BF856AEBDC43AD60141BAD5573" +
+	"910476D030A869979A7E23F5FC006B6CE1B1D7CDA849BDE4" +
+	"6A145F80EE97C21AA2154FA3A5CF25C75E225C6F3384D3C0" +
+	"C6BEF5061B87E8D583BEFDF790ECD351F6D2B645E26904DE" +
+	"3F8A9861CC3EAD0AA40BD7C09C1F5F655A9E7BA7986B92B7" +
+	"3FD9A6A69F54EFC92AC7E21D15C9B85A76084D1EEFBC4781" +
+	"B91E231E9CE5F007BC75A8656CBD98E282671C08A5400C4E" +
+	"4D039DE5FD63AA89A618C5668256B12672C66082F0348B62" +
+	"04DD0ADE58532C967D055A5D2C34C43DF9998820B5DFC4C4" +
+	"9C6820191CB3EC81062AA51E23CEEA9A37AB523B24C0E93B" +
+	"440FDC17A50B219AB0D373014C25EE8F"
+
+// Group4096 returns the 4096-bit MODP group with generator 2
+func Group4096() *cyclic.Group {
+	return cyclic.NewGroup(large.NewIntFromString(modp4096, 16),
+		large.NewInt(2))
+}
+
+// Group2048 returns a 2048-bit group with generator 2
+func Group2048() *cyclic.Group {
+	return cyclic.NewGroup(large.NewIntFromString(prime2048, 16),
+		large.NewInt(2))
+}
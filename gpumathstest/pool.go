@@ -0,0 +1,31 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumathstest
+
+import (
+	"gitlab.com/elixxir/gpumathsgo"
+	"testing"
+)
+
+// NewStreamPool makes a pool for a test, and destroys it when the test ends
+// With the gpu tag the pool has real streams. Otherwise it's from
+// gpumaths.NewEmulatedStreamPool, so the same test works in either build.
+func NewStreamPool(tb testing.TB, numStreams, memSize int) *gpumaths.StreamPool {
+	tb.Helper()
+	p, err := newStreamPool(numStreams, memSize)
+	if err != nil {
+		tb.Fatalf("Couldn't make stream pool: %v", err)
+	}
+	tb.Cleanup(func() {
+		err := p.Destroy()
+		if err != nil {
+			tb.Errorf("Couldn't destroy stream pool: %v", err)
+		}
+	})
+	return p
+}
@@ -0,0 +1,16 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumathstest
+
+import "gitlab.com/elixxir/gpumathsgo"
+
+func newStreamPool(numStreams, memSize int) (*gpumaths.StreamPool, error) {
+	return gpumaths.NewEmulatedStreamPool(numStreams, memSize)
+}
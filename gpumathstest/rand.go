@@ -0,0 +1,79 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumathstest
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/csprng"
+	"math/rand"
+)
+
+// Rng is a seeded math/rand source that implements csprng.Source, so tests
+// can pass the same randomness to code that expects a CSPRNG
+// It isn't secure, and must only be used in tests.
+type Rng struct {
+	rand.Rand
+}
+
+// NewRng makes an Rng with the passed seed
+func NewRng(seed int64) *Rng {
+	return &Rng{Rand: *rand.New(rand.NewSource(seed))}
+}
+
+// SetSeed reseeds the Rng from the first 8 bytes of seed
+func (r *Rng) SetSeed(seed []byte) error {
+	numBytes := int64(len(seed))
+	if numBytes > 8 {
+		numBytes = 8
+	}
+	seedVal := int64(0)
+	for i := int64(0); i < numBytes; i++ {
+		seedVal ^= int64(seed[i]) << (8 * i)
+	}
+	r.Seed(seedVal)
+	return nil
+}
+
+var _ csprng.Source = &Rng{}
+
+// RandomBuffer makes a buffer of numSlots random members of the group, the
+// same for the same seed
+// The values are intSize bytes long, or as long as the prime if intSize is 0.
+func RandomBuffer(g *cyclic.Group, numSlots uint32, seed int64,
+	intSize int) *cyclic.IntBuffer {
+	rng := NewRng(seed)
+	buffer := g.NewIntBuffer(numSlots, g.NewInt(1))
+	if intSize == 0 {
+		intSize = len(g.GetPBytes())
+	}
+	for i := uint32(0); i < numSlots; i++ {
+		b, err := csprng.GenerateInGroup(g.GetPBytes(), intSize, rng)
+		if err != nil {
+			panic(err.Error())
+		}
+		g.SetBytes(buffer.Get(i), b)
+	}
+	return buffer
+}
+
+// Keys makes numSlots phase and share keys with cryptops.Generate, the same
+// for the same seed
+func Keys(g *cyclic.Group, numSlots uint32, seed int64) (phaseKeys,
+	shareKeys *cyclic.IntBuffer) {
+	rng := NewRng(seed)
+	phaseKeys = g.NewIntBuffer(numSlots, g.NewInt(1))
+	shareKeys = g.NewIntBuffer(numSlots, g.NewInt(1))
+	for i := uint32(0); i < numSlots; i++ {
+		err := cryptops.Generate(g, phaseKeys.Get(i), shareKeys.Get(i), rng)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+	return phaseKeys, shareKeys
+}
@@ -0,0 +1,28 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumathstest
+
+import "testing"
+
+// Every byte of the seed should change the stream, including the first
+func TestRng_SetSeed(t *testing.T) {
+	seeds := [][]byte{{1}, {2}, {1, 1}, {1, 2}, {1, 0, 0, 0, 0, 0, 0, 1}}
+	first := make(map[int64][]byte)
+	for _, seed := range seeds {
+		r := NewRng(0)
+		err := r.SetSeed(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := r.Int63()
+		if other, ok := first[v]; ok {
+			t.Errorf("Seeds %v and %v gave the same stream", other, seed)
+		}
+		first[v] = seed
+	}
+}
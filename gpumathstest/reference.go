@@ -0,0 +1,233 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumathstest
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/xx_network/crypto/large"
+	"io"
+)
+
+// reference.go has an implementation of every op that only uses cryptops and
+// cyclic, one slot at a time. They take the same arguments as the ops,
+// without the pool, and write their results the same way, so the ops'
+// results can be compared with them.
+
+// Exp computes z = x**y for every slot
+func Exp(g *cyclic.Group, x, y, z gpumaths.Operands) gpumaths.Operands {
+	for i := uint32(0); i < uint32(z.Len()); i++ {
+		cryptops.Exp(g, x.Get(i), y.Get(i), z.Get(i))
+	}
+	return z
+}
+
+// ElGamal runs cryptops.ElGamal for every slot, updating ecrKey and cypher
+func ElGamal(g *cyclic.Group, key, privateKey gpumaths.Operands,
+	publicCypherKey *cyclic.Int, ecrKey, cypher gpumaths.Operands) {
+	for i := uint32(0); i < uint32(ecrKey.Len()); i++ {
+		cryptops.ElGamal(g, key.Get(i), privateKey.Get(i), publicCypherKey,
+			ecrKey.Get(i), cypher.Get(i))
+	}
+}
+
+// Reveal computes result = cypher**(1/publicCypherKey) for every slot
+func Reveal(g *cyclic.Group, publicCypherKey *cyclic.Int, cypher,
+	result gpumaths.Operands) {
+	for i := uint32(0); i < uint32(result.Len()); i++ {
+		cryptops.RootCoprime(g, cypher.Get(i), publicCypherKey, result.Get(i))
+	}
+}
+
+// Mul2 computes result = x*y for every slot. It also stands in for
+// Mul2Slice, with gpumaths.IntSlice for y and result.
+func Mul2(g *cyclic.Group, x, y, result gpumaths.Operands) {
+	for i := uint32(0); i < uint32(result.Len()); i++ {
+		g.Mul(x.Get(i), y.Get(i), result.Get(i))
+	}
+}
+
+// Mul3 computes result = x*y*z for every slot
+func Mul3(g *cyclic.Group, x, y, z, result gpumaths.Operands) {
+	product := g.NewInt(1)
+	for i := uint32(0); i < uint32(result.Len()); i++ {
+		g.Mul(x.Get(i), y.Get(i), product)
+		g.Mul(product, z.Get(i), result.Get(i))
+	}
+}
+
+// PermuteMul2 computes results[i] = x[permutation[i]] * y[i] for every slot
+func PermuteMul2(g *cyclic.Group, x, y gpumaths.Operands,
+	permutation []uint32, results gpumaths.Operands) error {
+	if len(permutation) != x.Len() {
+		return errors.Errorf("permutation has %v entries, but there are %v "+
+			"slots", len(permutation), x.Len())
+	}
+	seen := make([]bool, x.Len())
+	for i, src := range permutation {
+		if int(src) >= x.Len() || seen[src] {
+			return errors.Errorf("permutation entry %v is %v, which is out "+
+				"of range or repeated", i, src)
+		}
+		seen[src] = true
+	}
+	for i := uint32(0); i < uint32(results.Len()); i++ {
+		g.Mul(x.Get(permutation[i]), y.Get(i), results.Get(i))
+	}
+	return nil
+}
+
+// ReduceProduct returns the product of every slot of x, or 1 if x is empty
+func ReduceProduct(g *cyclic.Group, x gpumaths.Operands) *cyclic.Int {
+	result := g.NewInt(1)
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		g.Mul(result, x.Get(i), result)
+	}
+	return result
+}
+
+// Root computes result = x**(1/y) for every slot
+// Like RootChunk, it returns a *gpumaths.NotCoprimeError without writing
+// anything if an exponent isn't coprime to p-1.
+func Root(g *cyclic.Group, x, y, result gpumaths.Operands) error {
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		err := checkCoprime(g, y.Get(i), i)
+		if err != nil {
+			return err
+		}
+	}
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		cryptops.RootCoprime(g, x.Get(i), y.Get(i), result.Get(i))
+	}
+	return nil
+}
+
+// RootShared computes result = x**(1/y) for every slot, with one exponent
+func RootShared(g *cyclic.Group, y *cyclic.Int, x,
+	result gpumaths.Operands) error {
+	err := checkCoprime(g, y, 0)
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		cryptops.RootCoprime(g, x.Get(i), y, result.Get(i))
+	}
+	return nil
+}
+
+// Validate returns the slots of x that aren't in [1, p), or that don't give
+// 1 when raised to q if q isn't nil
+func Validate(g *cyclic.Group, q *large.Int, x gpumaths.Operands) []uint32 {
+	var failed []uint32
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		v := x.Get(i).GetLargeInt()
+		if v.Cmp(large.NewInt(1)) < 0 || v.Cmp(g.GetP()) >= 0 {
+			failed = append(failed, i)
+		} else if q != nil &&
+			large.NewInt(0).Exp(v, q, g.GetP()).Cmp(large.NewInt(1)) != 0 {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// ProveDLEQ computes a = g**x and b = h**x for every slot, and proves that
+// they have the same exponent
+// It reads nonces from rng the same way ProveDLEQChunk does, so both make the
+// same proofs from the same randomness.
+func ProveDLEQ(g *cyclic.Group, h *cyclic.Int, x, a, b gpumaths.Operands,
+	proofs *gpumaths.DLEQProofs, rng io.Reader) error {
+	gen := g.NewIntFromLargeInt(g.GetG())
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		buf := make([]byte, len(g.GetPBytes())+8)
+		_, err := io.ReadFull(rng, buf)
+		if err != nil {
+			return err
+		}
+		k := large.NewIntFromBytes(buf)
+		k.Mod(k, g.GetPSub1())
+		// Exponents can be 0, which SetLargeInt won't write
+		nonce := g.NewInt(1)
+		g.SetBytes(nonce, k.Bytes())
+
+		g.Exp(gen, x.Get(i), a.Get(i))
+		g.Exp(h, x.Get(i), b.Get(i))
+		g.Exp(gen, nonce, proofs.T1.Get(i))
+		g.Exp(h, nonce, proofs.T2.Get(i))
+		c := gpumaths.DLEQChallenge(g, h, a.Get(i), b.Get(i),
+			proofs.T1.Get(i), proofs.T2.Get(i))
+		s := large.NewInt(0).Mul(c, x.Get(i).GetLargeInt())
+		s.Sub(k, s)
+		s.Mod(s, g.GetPSub1())
+		g.SetBytes(proofs.S.Get(i), s.Bytes())
+	}
+	return nil
+}
+
+// VerifyDLEQ returns the slots whose proofs don't verify, or where a, b,
+// t1 or t2 isn't in the subgroup of order q
+// If h isn't in the subgroup, every slot fails.
+func VerifyDLEQ(g *cyclic.Group, q *large.Int, h *cyclic.Int, a,
+	b gpumaths.Operands, proofs *gpumaths.DLEQProofs) []uint32 {
+	outside := make(map[uint32]bool)
+	hOutside := len(Validate(g, q, gpumaths.IntSlice{h})) != 0
+	for _, x := range []gpumaths.Operands{a, b, proofs.T1, proofs.T2} {
+		for _, i := range Validate(g, q, x) {
+			outside[i] = true
+		}
+	}
+	var failed []uint32
+	gen := g.NewIntFromLargeInt(g.GetG())
+	lhs := g.NewInt(1)
+	rhs := g.NewInt(1)
+	c := g.NewInt(1)
+	for i := uint32(0); i < uint32(a.Len()); i++ {
+		challenge := gpumaths.DLEQChallenge(g, h, a.Get(i), b.Get(i),
+			proofs.T1.Get(i), proofs.T2.Get(i))
+		g.SetBytes(c, challenge.Mod(challenge, g.GetPSub1()).Bytes())
+		ok := !hOutside && !outside[i]
+		// g**s * a**c should be t1, and h**s * b**c should be t2
+		for _, check := range []struct {
+			base, public, commitment *cyclic.Int
+		}{
+			{gen, a.Get(i), proofs.T1.Get(i)},
+			{h, b.Get(i), proofs.T2.Get(i)},
+		} {
+			g.Exp(check.base, proofs.S.Get(i), lhs)
+			g.Exp(check.public, c, rhs)
+			g.Mul(lhs, rhs, lhs)
+			ok = ok && lhs.Cmp(check.commitment) == 0
+		}
+		if !ok {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// BatchVerifyDLEQ is true if every proof verifies
+// This never accepts an invalid proof, but BatchVerifyDLEQChunk can. Its
+// chance of accepting one is only negligible if q is prime: with a
+// composite q, like p-1, the subgroup check lets through values with a
+// factor of small order, and e.g. a b with its sign flipped is accepted half
+// of the time. The two only agree on forged proofs if q is prime.
+func BatchVerifyDLEQ(g *cyclic.Group, q *large.Int, h *cyclic.Int, a,
+	b gpumaths.Operands, proofs *gpumaths.DLEQProofs) bool {
+	return len(VerifyDLEQ(g, q, h, a, b, proofs)) == 0
+}
+
+// checkCoprime returns a *gpumaths.NotCoprimeError if y isn't coprime to p-1
+func checkCoprime(g *cyclic.Group, y *cyclic.Int, slot uint32) error {
+	gcd := large.NewInt(0).GCD(nil, nil, y.GetLargeInt(), g.GetPSub1())
+	if gcd.Cmp(large.NewInt(1)) != 0 {
+		return &gpumaths.NotCoprimeError{Slot: slot, Exponent: y.Text(16)}
+	}
+	return nil
+}
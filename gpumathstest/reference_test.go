@@ -0,0 +1,193 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumathstest
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+// The ops should agree with the reference implementations in either build
+func TestReference(t *testing.T) {
+	const n = 16
+	g := Group2048()
+	p := NewStreamPool(t, 1, 65536)
+	x := RandomBuffer(g, n, 1, 0)
+	y := RandomBuffer(g, n, 2, 0)
+	z := RandomBuffer(g, n, 3, 0)
+	newBuffer := func() *cyclic.IntBuffer {
+		return g.NewIntBuffer(n, g.NewInt(1))
+	}
+
+	expected, actual := newBuffer(), newBuffer()
+	Exp(g, x, y, expected)
+	_, err := gpumaths.ExpChunk(p, g, x, y, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "ExpChunk", expected, actual)
+
+	phaseKeys, shareKeys := Keys(g, n, 4)
+	publicCypherKey := g.NewInt(65537)
+	expectedEcrKey, expectedCypher := x.DeepCopy(), y.DeepCopy()
+	ecrKey, cypher := x.DeepCopy(), y.DeepCopy()
+	ElGamal(g, phaseKeys, shareKeys, publicCypherKey, expectedEcrKey,
+		expectedCypher)
+	err = gpumaths.ElGamalChunk(p, g, phaseKeys, shareKeys, publicCypherKey,
+		ecrKey, cypher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "ElGamalChunk ecrKey", expectedEcrKey, ecrKey)
+	CheckOperands(t, "ElGamalChunk cypher", expectedCypher, cypher)
+
+	expected, actual = newBuffer(), newBuffer()
+	Reveal(g, publicCypherKey, x, expected)
+	err = gpumaths.RevealChunk(p, g, publicCypherKey, x, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "RevealChunk", expected, actual)
+
+	expected, actual = newBuffer(), newBuffer()
+	Mul2(g, x, y, expected)
+	err = gpumaths.Mul2Chunk(p, g, x, y, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "Mul2Chunk", expected, actual)
+
+	expected, actual = newBuffer(), newBuffer()
+	Mul3(g, x, y, z, expected)
+	err = gpumaths.Mul3Chunk(p, g, x, y, z, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "Mul3Chunk", expected, actual)
+
+	permutation := make([]uint32, n)
+	for i := range permutation {
+		permutation[i] = uint32(n - 1 - i)
+	}
+	expected, actual = newBuffer(), newBuffer()
+	err = PermuteMul2(g, x, y, permutation, expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gpumaths.PermuteMul2Chunk(p, g, x, y, permutation, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "PermuteMul2Chunk", expected, actual)
+
+	product, err := gpumaths.ReduceProductChunk(p, g, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Cmp(ReduceProduct(g, x)) != 0 {
+		t.Error("ReduceProductChunk differs from the reference")
+	}
+
+	exponents := g.NewIntBuffer(n, publicCypherKey)
+	expected, actual = newBuffer(), newBuffer()
+	err = Root(g, x, exponents, expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gpumaths.RootChunk(p, g, x, exponents, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "RootChunk", expected, actual)
+	actual = newBuffer()
+	err = gpumaths.RootSharedChunk(p, g, publicCypherKey, x, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "RootSharedChunk", expected, actual)
+
+	invalid := x.DeepCopy()
+	// SetLargeInt leaves values outside the group unset, but SetBytes doesn't
+	g.SetBytes(invalid.Get(3), g.GetP().Bytes())
+	q := large.NewInt(0).RightShift(g.GetPSub1(), 1)
+	failed, err := gpumaths.ValidateChunk(p, g, q, invalid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFailed := Validate(g, q, invalid)
+	outside := false
+	for _, i := range expectedFailed {
+		outside = outside || i == 3
+	}
+	if !outside {
+		t.Errorf("Slot 3 isn't in the group, but only %v failed",
+			expectedFailed)
+	}
+	CheckSlots(t, "ValidateChunk", expectedFailed, failed)
+}
+
+// The reference DLEQ proofs should match ProveDLEQChunk's for the same
+// randomness, and the verifiers should agree on which slots fail
+func TestReference_DLEQ(t *testing.T) {
+	const n = 6
+	g := Group2048()
+	p := NewStreamPool(t, 1, 65536)
+	q := large.NewInt(0).RightShift(g.GetPSub1(), 1)
+	// Squares are in the subgroup of order q
+	h := RandomBuffer(g, 1, 1, 0).Get(0)
+	g.Mul(h, h, h)
+	x := RandomBuffer(g, n, 2, 0)
+
+	a, b := g.NewIntBuffer(n, g.NewInt(1)), g.NewIntBuffer(n, g.NewInt(1))
+	proofs := gpumaths.NewDLEQProofs(g, n)
+	err := gpumaths.ProveDLEQChunk(p, g, h, x, a, b, proofs, NewRng(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedA := g.NewIntBuffer(n, g.NewInt(1))
+	expectedB := g.NewIntBuffer(n, g.NewInt(1))
+	expectedProofs := gpumaths.NewDLEQProofs(g, n)
+	err = ProveDLEQ(g, h, x, expectedA, expectedB, expectedProofs, NewRng(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckOperands(t, "a", expectedA, a)
+	CheckOperands(t, "b", expectedB, b)
+	CheckOperands(t, "T1", expectedProofs.T1, proofs.T1)
+	CheckOperands(t, "T2", expectedProofs.T2, proofs.T2)
+	CheckOperands(t, "S", expectedProofs.S, proofs.S)
+
+	g.Mul(proofs.S.Get(4), g.NewInt(2), proofs.S.Get(4))
+	g.Mul(b.Get(1), g.GetPSub1Cyclic(), b.Get(1))
+	failed, err := gpumaths.VerifyDLEQChunk(p, g, q, h, a, b, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckSlots(t, "VerifyDLEQChunk", []uint32{1, 4}, failed)
+	CheckSlots(t, "VerifyDLEQ", []uint32{1, 4},
+		VerifyDLEQ(g, q, h, a, b, proofs))
+	if BatchVerifyDLEQ(g, q, h, a, b, proofs) {
+		t.Error("BatchVerifyDLEQ accepted a bad proof")
+	}
+}
+
+// MismatchedSlots should find changed slots and extra slots
+func TestMismatchedSlots(t *testing.T) {
+	g := Group2048()
+	x := RandomBuffer(g, 5, 1, 0)
+	if len(MismatchedSlots(x, RandomBuffer(g, 5, 1, 0))) != 0 {
+		t.Error("Buffers from the same seed should match")
+	}
+	y := x.DeepCopy()
+	g.SetLargeInt(y.Get(2), large.NewInt(7))
+	CheckSlots(t, "changed", []uint32{2}, MismatchedSlots(x, y))
+	CheckSlots(t, "shorter", []uint32{3, 4},
+		MismatchedSlots(x, x.GetSubBuffer(0, 3)))
+}
@@ -0,0 +1,124 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// info.go describes what the build can run, for debugging nodes. The
+// details come from info_gpu.go or info_cpu.go.
+
+// Info describes the backend that the ops run on
+type Info struct {
+	Backend Backend
+	// Path of the loaded native library, or empty without the GPU backend
+	Library string `json:",omitempty"`
+	// Version from the library's file name, e.g. "1.2" for
+	// libpowmosm75.so.1.2, or empty if the name doesn't have one
+	LibraryVersion string `json:",omitempty"`
+	// Stream size that the environments' MaxSlots are for
+	MemSize int `json:",omitempty"`
+	// Environments that the native library has. The CPU backend works with
+	// any prime, so it doesn't have any.
+	Environments []EnvironmentInfo `json:",omitempty"`
+	// Ops that run on this backend
+	Ops []string
+}
+
+// EnvironmentInfo describes the kernels for one prime length
+type EnvironmentInfo struct {
+	Bits    int
+	Kernels []KernelInfo
+}
+
+// KernelInfo describes one of an environment's kernels
+type KernelInfo struct {
+	Name string
+	// Ops that launch the kernel
+	Ops []string
+	// Bytes of input and output for each slot
+	InputSize  int
+	OutputSize int
+	// Bytes of constants for each launch
+	ConstantsSize int
+	// Slots that one launch can run on a stream of Info.MemSize bytes
+	MaxSlots int
+}
+
+// Names of every op, in the order they're listed
+var opNames = []string{
+	ExpChunk.GetName(),
+	ElGamalChunk.GetName(),
+	RevealChunk.GetName(),
+	Mul2Chunk.GetName(),
+	Mul2Slice.GetName(),
+	Mul3Chunk.GetName(),
+	PermuteMul2Chunk.GetName(),
+	ReduceProductChunk.GetName(),
+	RootChunk.GetName(),
+	RootSharedChunk.GetName(),
+	ValidateChunk.GetName(),
+	ProveDLEQChunk.GetName(),
+	VerifyDLEQChunk.GetName(),
+	BatchVerifyDLEQChunk.GetName(),
+}
+
+// GetInfo describes the backend, with slot counts for streams of memSize
+// bytes
+func GetInfo(memSize int) Info {
+	return getInfo(memSize)
+}
+
+// libraryVersion gets the version from a shared library's file name, after
+// following any symlinks
+func libraryVersion(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	name := filepath.Base(path)
+	i := strings.Index(name, ".so.")
+	if i < 0 {
+		return ""
+	}
+	return name[i+len(".so."):]
+}
+
+// WriteText writes the info in a human-readable format
+func (info Info) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Backend:\t%v\n", info.Backend)
+	if info.Library != "" {
+		version := info.LibraryVersion
+		if version == "" {
+			version = "unknown"
+		}
+		fmt.Fprintf(tw, "Library:\t%v\n", info.Library)
+		fmt.Fprintf(tw, "Library version:\t%v\n", version)
+	}
+	fmt.Fprintf(tw, "Ops:\t%v\n", strings.Join(info.Ops, ", "))
+	if len(info.Environments) == 0 {
+		fmt.Fprintf(tw, "Environments:\tany prime length\n")
+		return tw.Flush()
+	}
+	fmt.Fprintf(tw, "Stream size:\t%v bytes\n", info.MemSize)
+	for _, env := range info.Environments {
+		fmt.Fprintf(tw, "\n%v-bit environment\n", env.Bits)
+		fmt.Fprintf(tw, "Kernel\tInput\tOutput\tConstants\tMax slots\tOps\n")
+		for _, k := range env.Kernels {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", k.Name, k.InputSize,
+				k.OutputSize, k.ConstantsSize, k.MaxSlots,
+				strings.Join(k.Ops, ", "))
+		}
+	}
+	return tw.Flush()
+}
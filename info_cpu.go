@@ -0,0 +1,19 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+// The CPU ops don't use streams or the native library, so there's nothing
+// to report but the ops
+func getInfo(memSize int) Info {
+	return Info{
+		Backend: BackendCPU,
+		Ops:     opNames,
+	}
+}
@@ -0,0 +1,83 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+/*
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#cgo LDFLAGS: -ldl
+#define _GNU_SOURCE
+#include <dlfcn.h>
+#include <powm_odd_export.h>
+
+// Finds the file that the native library was loaded from
+static const char *libraryPath() {
+	Dl_info info;
+	if (dladdr((void *)createStream, &info) == 0) {
+		return NULL;
+	}
+	return info.dli_fname;
+}
+*/
+import "C"
+
+// Names of the native library's kernels. This is separate from kernelInfos
+// so the ops can use it without an initialization cycle.
+var kernelNames = map[C.enum_kernel]string{
+	kernelPowmOdd: "powm_odd",
+	kernelElgamal: "elgamal",
+	kernelReveal:  "reveal",
+	kernelMul2:    "mul2",
+	kernelMul3:    "mul3",
+}
+
+// The native library's kernels, and the ops that launch them
+var kernelInfos = []struct {
+	kernel C.enum_kernel
+	ops    []string
+}{
+	{kernelPowmOdd, []string{ExpChunk.GetName(), ValidateChunk.GetName(),
+		RootChunk.GetName(), ProveDLEQChunk.GetName(),
+		VerifyDLEQChunk.GetName(), BatchVerifyDLEQChunk.GetName()}},
+	{kernelElgamal, []string{ElGamalChunk.GetName()}},
+	{kernelReveal, []string{RevealChunk.GetName(),
+		RootSharedChunk.GetName()}},
+	{kernelMul2, []string{Mul2Chunk.GetName(), Mul2Slice.GetName(),
+		PermuteMul2Chunk.GetName(), ReduceProductChunk.GetName(),
+		VerifyDLEQChunk.GetName(), BatchVerifyDLEQChunk.GetName()}},
+	{kernelMul3, []string{Mul3Chunk.GetName()}},
+}
+
+func getInfo(memSize int) Info {
+	info := Info{
+		Backend: BackendGPU,
+		MemSize: memSize,
+		Ops:     opNames,
+	}
+	if path := C.libraryPath(); path != nil {
+		info.Library = C.GoString(path)
+		info.LibraryVersion = libraryVersion(info.Library)
+	}
+	for _, env := range []gpumathsEnv{&gpumathsEnv2048, &gpumathsEnv3200,
+		&gpumathsEnv4096} {
+		envInfo := EnvironmentInfo{Bits: env.getBitLen()}
+		for _, k := range kernelInfos {
+			envInfo.Kernels = append(envInfo.Kernels, KernelInfo{
+				Name:          kernelNames[k.kernel],
+				Ops:           k.ops,
+				InputSize:     env.getInputSize(k.kernel),
+				OutputSize:    env.getOutputSize(k.kernel),
+				ConstantsSize: env.getConstantsSize(k.kernel),
+				MaxSlots:      env.maxSlots(memSize, k.kernel),
+			})
+		}
+		info.Environments = append(info.Environments, envInfo)
+	}
+	return info
+}
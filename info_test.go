@@ -0,0 +1,98 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The info should describe the backend that's built in
+func TestGetInfo(t *testing.T) {
+	const memSize = 1 << 20
+	info := GetInfo(memSize)
+	if len(info.Ops) != len(opNames) {
+		t.Errorf("info had %v ops, expected %v", len(info.Ops), len(opNames))
+	}
+	if !gpuBuild {
+		if info.Backend != BackendCPU || len(info.Environments) != 0 {
+			t.Errorf("CPU build had info %+v", info)
+		}
+		return
+	}
+	if info.Backend != BackendGPU || info.Library == "" {
+		t.Errorf("GPU build had info %+v", info)
+	}
+	bits := make([]int, 0, len(info.Environments))
+	launched := make(map[string]bool)
+	for _, env := range info.Environments {
+		bits = append(bits, env.Bits)
+		for _, k := range env.Kernels {
+			for _, op := range k.Ops {
+				launched[op] = true
+			}
+			used := k.ConstantsSize + k.MaxSlots*(k.InputSize+k.OutputSize)
+			if k.MaxSlots < 1 || used > memSize ||
+				used+k.InputSize+k.OutputSize <= memSize {
+				t.Errorf("%v-bit %v kernel had the wrong max slots: %+v",
+					env.Bits, k.Name, k)
+			}
+		}
+	}
+	if len(bits) != 3 || bits[0] != 2048 || bits[1] != 3200 ||
+		bits[2] != 4096 {
+		t.Errorf("environments were %v", bits)
+	}
+	// Every op runs on at least one kernel
+	for _, op := range opNames {
+		if !launched[op] {
+			t.Errorf("no kernel listed %v", op)
+		}
+	}
+}
+
+func TestInfo_WriteText(t *testing.T) {
+	var out bytes.Buffer
+	err := GetInfo(1 << 20).WriteText(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Backend:", "Ops:", "ExpChunk"}
+	if gpuBuild {
+		expected = append(expected, "4096-bit environment", "powm_odd")
+	}
+	for _, s := range expected {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("text didn't have %q:\n%v", s, out.String())
+		}
+	}
+}
+
+// The version should come from the file that a library symlink points to
+func TestLibraryVersion(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "libpowmosm75.so.1.2")
+	err := os.WriteFile(lib, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "libpowmosm75.so")
+	err = os.Symlink(lib, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := libraryVersion(link); v != "1.2" {
+		t.Errorf("version was %q, expected 1.2", v)
+	}
+	if v := libraryVersion(filepath.Join(dir, "libother.so")); v != "" {
+		t.Errorf("unversioned library had version %q", v)
+	}
+}
@@ -0,0 +1,272 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// Package wire has the messages, codec and service description that the
+// offload server and client share
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/xx_network/crypto/large"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// Messages are gob encoded, so the service doesn't need generated protobuf
+// code. Calls must set grpc.CallContentSubtype(CodecName) to use the codec.
+const CodecName = "gpumaths"
+
+// ServiceName is the gRPC service that the server registers
+const ServiceName = "gpumaths.Offload"
+
+// Names of the service's methods
+const (
+	MethodExp     = "Exp"
+	MethodElGamal = "ElGamal"
+	MethodReveal  = "Reveal"
+	MethodMul2    = "Mul2"
+	MethodMul3    = "Mul3"
+)
+
+// MaxSlotsPerCall is the most slots that a request can have
+// Clients split bigger operations into several calls.
+const MaxSlotsPerCall = 8192
+
+// MaxPrimeBits is the longest prime that a request's group can have
+// The GPU kernels don't support longer primes, and on the CPU they'd let a
+// request ask for arbitrarily slow exponentiations.
+const MaxPrimeBits = 4096
+
+// Domain is the range that an operand's ints have to be in
+type Domain int
+
+const (
+	// GroupElements are in [1, p)
+	GroupElements Domain = iota
+	// Exponents, such as ElGamal private keys, are in [0, p)
+	Exponents
+)
+
+// Group is a cyclic group's prime and generator, in big-endian bytes
+type Group struct {
+	P []byte
+	G []byte
+}
+
+// OpRequest asks the server to run an operation
+// Each operand is NumSlots ints, each left-padded to the length of P and
+// concatenated. Scalars are single ints that apply to every slot.
+type OpRequest struct {
+	Group    Group
+	NumSlots uint32
+	Operands [][]byte
+	Scalars  [][]byte
+}
+
+// OpResponse has the operation's outputs, packed the same way as the
+// request's operands
+type OpResponse struct {
+	Results [][]byte
+}
+
+// OffloadServer runs the operations that the service offers
+type OffloadServer interface {
+	Exp(context.Context, *OpRequest) (*OpResponse, error)
+	ElGamal(context.Context, *OpRequest) (*OpResponse, error)
+	Reveal(context.Context, *OpRequest) (*OpResponse, error)
+	Mul2(context.Context, *OpRequest) (*OpResponse, error)
+	Mul3(context.Context, *OpRequest) (*OpResponse, error)
+}
+
+// ServiceDesc describes the service to grpc.Server.RegisterService
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*OffloadServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: MethodExp, Handler: handler(MethodExp, OffloadServer.Exp)},
+		{MethodName: MethodElGamal, Handler: handler(MethodElGamal, OffloadServer.ElGamal)},
+		{MethodName: MethodReveal, Handler: handler(MethodReveal, OffloadServer.Reveal)},
+		{MethodName: MethodMul2, Handler: handler(MethodMul2, OffloadServer.Mul2)},
+		{MethodName: MethodMul3, Handler: handler(MethodMul3, OffloadServer.Mul3)},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "gpumaths",
+}
+
+// FullMethod returns the name that clients invoke a method with
+func FullMethod(method string) string {
+	return "/" + ServiceName + "/" + method
+}
+
+// handler decodes a request and passes it to one of the server's methods,
+// through the server's interceptor if it has one
+func handler(method string, call func(OffloadServer, context.Context,
+	*OpRequest) (*OpResponse, error)) func(interface{}, context.Context,
+	func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context,
+		dec func(interface{}) error,
+		interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(OpRequest)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(OffloadServer), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: FullMethod(method),
+		}
+		return interceptor(ctx, req, info,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(OffloadServer), ctx, req.(*OpRequest))
+			})
+	}
+}
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// codec marshals the messages with gob
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (codec) Name() string {
+	return CodecName
+}
+
+// NewGroup serializes g
+func NewGroup(g *cyclic.Group) Group {
+	return Group{P: g.GetPBytes(), G: g.GetG().Bytes()}
+}
+
+// Cyclic makes the group that gr describes
+// The prime isn't checked for primality, but it has to be odd, at least 3
+// and at most MaxPrimeBits long, and the generator has to be in (1, p), so
+// that the group's arithmetic can't fail.
+func (gr Group) Cyclic() (*cyclic.Group, error) {
+	if len(gr.P) == 0 || len(gr.G) == 0 {
+		return nil, errors.New("group is missing its prime or generator")
+	}
+	p := large.NewIntFromBytes(gr.P)
+	g := large.NewIntFromBytes(gr.G)
+	if p.Cmp(large.NewInt(3)) < 0 || p.Bits()[0]&1 == 0 {
+		return nil, errors.New("group's prime must be odd and at least 3")
+	}
+	if p.BitLen() > MaxPrimeBits {
+		return nil, errors.Errorf("group's prime has %v bits, more than the "+
+			"limit of %v", p.BitLen(), MaxPrimeBits)
+	}
+	if g.Cmp(large.NewInt(1)) <= 0 || g.Cmp(p) >= 0 {
+		return nil, errors.New("group's generator must be between 1 and " +
+			"the prime")
+	}
+	return cyclic.NewGroup(p, g), nil
+}
+
+// Key identifies the group, for caching groups made from requests
+func (gr Group) Key() string {
+	return string(gr.P) + "/" + string(gr.G)
+}
+
+// IntLen is the number of bytes that each int is padded to in g
+func IntLen(g *cyclic.Group) int {
+	return len(g.GetPBytes())
+}
+
+// PackOperands serializes n ints of o, starting at begin
+func PackOperands(g *cyclic.Group, o gpumaths.Operands, begin,
+	n uint32) ([]byte, error) {
+	intLen := IntLen(g)
+	result := make([]byte, 0, int(n)*intLen)
+	for i := begin; i < begin+n; i++ {
+		v := o.Get(i)
+		if v.ByteLen() > intLen {
+			return nil, errors.Errorf("int in slot %v is longer than "+
+				"the group's prime", i)
+		}
+		result = append(result, v.LeftpadBytes(uint64(intLen))...)
+	}
+	return result, nil
+}
+
+// UnpackOperands writes n ints from data to o, starting at begin
+// Nothing is written unless every int is in domain.
+func UnpackOperands(g *cyclic.Group, data []byte, o gpumaths.Operands,
+	begin, n uint32, domain Domain) error {
+	err := checkPacked(g, data, n, domain)
+	if err != nil {
+		return err
+	}
+	intLen := IntLen(g)
+	for i := uint32(0); i < n; i++ {
+		start := int(i) * intLen
+		// Exponents can be 0, which isn't in the group, but SetBytes
+		// writes it anyway
+		g.SetBytes(o.Get(begin+i), data[start:start+intLen])
+	}
+	return nil
+}
+
+// NewBuffer makes a buffer of n ints in domain from data
+func NewBuffer(g *cyclic.Group, data []byte, n uint32,
+	domain Domain) (*cyclic.IntBuffer, error) {
+	// Check the data before allocating, so a request can't ask for a huge
+	// buffer that its data doesn't fill
+	err := checkPacked(g, data, n, domain)
+	if err != nil {
+		return nil, err
+	}
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	err = UnpackOperands(g, data, result, 0, n, domain)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkPacked returns an error unless data holds n packed ints in domain
+func checkPacked(g *cyclic.Group, data []byte, n uint32, domain Domain) error {
+	intLen := IntLen(g)
+	if uint64(len(data)) != uint64(n)*uint64(intLen) {
+		return errors.Errorf("packed operand has %v bytes, but %v slots "+
+			"need %v", len(data), n, uint64(n)*uint64(intLen))
+	}
+	for i := 0; i < int(n); i++ {
+		b := data[i*intLen : (i+1)*intLen]
+		switch domain {
+		case Exponents:
+			if large.NewIntFromBytes(b).Cmp(g.GetP()) >= 0 {
+				return errors.Errorf("int in slot %v isn't less than the "+
+					"group's prime", i)
+			}
+		default:
+			if !g.BytesInside(b) {
+				return errors.Errorf("int in slot %v isn't in the group", i)
+			}
+		}
+	}
+	return nil
+}
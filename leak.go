@@ -0,0 +1,151 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"fmt"
+	jww "github.com/spf13/jwalterweatherman"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// leak.go finds streams that were taken from a pool and never returned. It
+// only does anything in builds with the gpumathsdebug tag, because capturing
+// a stack trace on every checkout is too slow for production:
+//  go test -tags gpumathsdebug ./...
+
+// StreamCheckout describes a stream that's been taken from a pool and hasn't
+// been returned yet
+type StreamCheckout struct {
+	// Slot of the stream, as in StreamMetrics
+	Slot int
+	// When the stream was taken
+	Taken time.Time
+	// Stack of the goroutine that took the stream
+	Stack string
+}
+
+// LeakDetection is true in builds that record where streams were taken
+func LeakDetection() bool {
+	return leakDetection
+}
+
+// HeldStreams returns the streams that have been out for longer than
+// threshold, longest held first
+// Always returns nil unless the build has the gpumathsdebug tag.
+func (sm *StreamPool) HeldStreams(threshold time.Duration) []StreamCheckout {
+	if !leakDetection || !sm.isUsable() {
+		return nil
+	}
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+	return sm.heldStreams(threshold)
+}
+
+// DumpHeldStreams writes where each stream that's been out for longer than
+// threshold was taken to w, and returns how many streams it wrote about
+func (sm *StreamPool) DumpHeldStreams(w io.Writer, threshold time.Duration) (int,
+	error) {
+	held := sm.HeldStreams(threshold)
+	for i := range held {
+		_, err := io.WriteString(w, held[i].String())
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(held), nil
+}
+
+// String describes the checkout, including the stack it was taken from
+func (c StreamCheckout) String() string {
+	return fmt.Sprintf("stream in slot %v taken %v ago by:\n%s\n", c.Slot,
+		time.Since(c.Taken).Round(time.Millisecond), c.Stack)
+}
+
+// heldStreams does the work of HeldStreams
+// sm.mux must be held
+func (sm *StreamPool) heldStreams(threshold time.Duration) []StreamCheckout {
+	var result []StreamCheckout
+	for _, checkout := range sm.checkouts {
+		if time.Since(checkout.Taken) >= threshold {
+			result = append(result, *checkout)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Taken.Before(result[j].Taken)
+	})
+	return result
+}
+
+// trackCheckout records where s is being taken
+// sm.mux must be held
+func (sm *StreamPool) trackCheckout(s Stream) {
+	if !leakDetection {
+		return
+	}
+	if sm.checkouts == nil {
+		sm.checkouts = make(map[uintptr]*StreamCheckout)
+	}
+	checkout := &StreamCheckout{
+		Taken: time.Now(),
+		Stack: string(debug.Stack()),
+	}
+	if slot := sm.slots[s.id()]; slot != nil {
+		checkout.Slot = slot.index
+	}
+	sm.checkouts[s.id()] = checkout
+}
+
+// trackReturn forgets where s was taken
+// sm.mux must be held
+func (sm *StreamPool) trackReturn(s Stream) {
+	if !leakDetection {
+		return
+	}
+	delete(sm.checkouts, s.id())
+}
+
+// reportLeaksOnDestroy logs the streams that are still out when the pool is
+// destroyed
+// sm.mux must be held
+func (sm *StreamPool) reportLeaksOnDestroy() {
+	if !leakDetection || len(sm.checkouts) == 0 {
+		return
+	}
+	jww.ERROR.Printf("Stream pool destroyed with %v streams still out:\n%s",
+		len(sm.checkouts), describeCheckouts(sm.heldStreams(0)))
+}
+
+// watchForLeaks complains if the pool is garbage collected without being
+// destroyed, since that leaks the streams' memory
+func (state *streamPoolState) watchForLeaks() {
+	if !leakDetection {
+		return
+	}
+	runtime.SetFinalizer(state, func(state *streamPoolState) {
+		if state.destroyed {
+			return
+		}
+		view := &StreamPool{streamPoolState: state}
+		jww.ERROR.Printf("Stream pool with %v streams was garbage "+
+			"collected without being destroyed. Streams still out:\n%s",
+			len(state.streams), describeCheckouts(view.heldStreams(0)))
+	})
+}
+
+func describeCheckouts(checkouts []StreamCheckout) string {
+	var b strings.Builder
+	for i := range checkouts {
+		b.WriteString(checkouts[i].String())
+	}
+	return b.String()
+}
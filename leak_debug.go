@@ -0,0 +1,13 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build gpumathsdebug
+
+package gpumaths
+
+// Record where every stream is taken, so leaks can be found
+const leakDetection = true
@@ -0,0 +1,13 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !gpumathsdebug
+
+package gpumaths
+
+// Leak detection is too slow for production builds
+const leakDetection = false
@@ -0,0 +1,67 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Only builds with the gpumathsdebug tag should remember where streams were
+// taken
+func TestStreamPool_HeldStreams(t *testing.T) {
+	p := newTestStreamPool(t, 2)
+	leaked, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	returned, err := p.TryTakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ReturnStream(returned)
+
+	held := p.HeldStreams(0)
+	if !LeakDetection() {
+		if held != nil {
+			t.Errorf("Build without leak detection returned %v held streams",
+				len(held))
+		}
+	} else {
+		if len(held) != 1 {
+			t.Fatalf("Got %v held streams, expected 1", len(held))
+		}
+		if !strings.Contains(held[0].Stack, "TestStreamPool_HeldStreams") {
+			t.Errorf("Stack didn't show where the stream was taken:\n%v",
+				held[0].Stack)
+		}
+		if len(p.HeldStreams(time.Hour)) != 0 {
+			t.Error("Stream held briefly was past the threshold")
+		}
+		var b bytes.Buffer
+		n, err := p.DumpHeldStreams(&b, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 || !strings.Contains(b.String(), "TestStreamPool_HeldStreams") {
+			t.Errorf("Unexpected dump of %v streams:\n%v", n, b.String())
+		}
+	}
+
+	p.ReturnStream(leaked)
+	if len(p.HeldStreams(0)) != 0 {
+		t.Error("Returned stream was still held")
+	}
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -16,9 +16,11 @@ import "gitlab.com/elixxir/crypto/cyclic"
 // Mul2ChunkPrototype defines the function type for running the mul2
 // kernel in the GPU.
 type Mul2ChunkPrototype func(p *StreamPool, g *cyclic.Group,
-	x, y, result *cyclic.IntBuffer) error
+	x, y, result Operands) error
 
 // Mul2Slice is similar, but it takes a slice of cyclic ints as input and result instead of an int buffer
+//
+// Deprecated: Mul2Chunk accepts slices directly through IntSlice
 type Mul2SlicePrototype func(p *StreamPool, g *cyclic.Group,
 	x *cyclic.IntBuffer, y, result []*cyclic.Int) error
 
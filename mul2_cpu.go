@@ -9,18 +9,8 @@
 
 package gpumaths
 
-import (
-	"errors"
-	"gitlab.com/elixxir/crypto/cyclic"
-)
+// Mul2Chunk computes result = x*y for every slot on the CPU
+var Mul2Chunk Mul2ChunkPrototype = cpuMul2Chunk
 
-// Mul2Chunk is stubbed unless GPU is present.
-var Mul2Chunk Mul2ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	result *cyclic.IntBuffer, x *cyclic.IntBuffer, y *cyclic.IntBuffer) error {
-	return errors.New(NoGpuErrStr)
-}
-
-var Mul2Slice Mul2SlicePrototype = func(p *StreamPool, g *cyclic.Group,
-	x *cyclic.IntBuffer, y, result []*cyclic.Int) error {
-	return errors.New(NoGpuErrStr)
-}
+// Mul2Slice runs Mul2Chunk with slices for y and result
+var Mul2Slice Mul2SlicePrototype = cpuMul2Slice
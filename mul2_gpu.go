@@ -27,36 +27,21 @@ import (
 
 const kernelMul2 = C.KERNEL_MUL2
 
-// This interface provides compatibility with the underlying mul2 method
-// Int buffers and slices can both be used to implement this interface
-type intGetter interface {
-	Get(index uint32) *cyclic.Int
-	Len() int
-}
-
-type intSlice []*cyclic.Int
-
-// Implement intGetter with cyclic int slice
-func (s intSlice) Get(index uint32) *cyclic.Int {
-	return s[index]
-}
-
-func (s intSlice) Len() int {
-	return len(s)
-}
-
 // Mul2Chunk performs the mul2 operation on the cypher and precomputation
 // payloads
 // Precondition: All int buffers must have the same length
 var Mul2Chunk Mul2ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	x *cyclic.IntBuffer, y *cyclic.IntBuffer, results *cyclic.IntBuffer) error {
+	x, y, results Operands) error {
 	// Populate mul2 inputs
 	numSlots := uint32(x.Len())
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
 	env := chooseEnv(g)
+	stream, err := p.TakeStreamFitting(env.streamSizeContaining(int(numSlots), kernelMul2))
+	if err != nil {
+		return err
+	}
+	defer p.ReturnStream(stream)
 	maxSlotsMul2 := uint32(env.maxSlots(len(stream.cpuData), kernelMul2))
 	if numSlots > maxSlotsMul2 {
 		//panic((numSlots+maxSlotsMul2-1)/maxSlotsMul2)
@@ -71,39 +56,22 @@ var Mul2Chunk Mul2ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 		} else {
 			sliceEnd = numSlots
 		}
-		err := <-mul2(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), results.GetSubBuffer(i, sliceEnd), env, stream)
+		err := <-mul2(g, SubRange(x, i, sliceEnd), SubRange(y, i, sliceEnd), SubRange(results, i, sliceEnd), env, stream)
 		if err != nil {
+			p.markFailed(stream, err)
 			return err
 		}
 	}
 
+	if p.SecretInputs() {
+		return p.wipeInputs(env, g, stream, kernelMul2, int(numSlots))
+	}
 	return nil
 }
 
+// Mul2Slice runs Mul2Chunk with slices for y and result
 var Mul2Slice Mul2SlicePrototype = func(p *StreamPool, g *cyclic.Group, x *cyclic.IntBuffer, y, result []*cyclic.Int) error {
-	// Populate mul2 inputs
-	numSlots := uint32(x.Len())
-
-	// Run kernel on the inputs
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
-	env := chooseEnv(g)
-	maxSlotsMul2 := uint32(env.maxSlots(len(stream.cpuData), kernelMul2))
-	for i := uint32(0); i < numSlots; i += maxSlotsMul2 {
-		sliceEnd := i
-		// Don't slice beyond the end of the input slice
-		if i+maxSlotsMul2 <= numSlots {
-			sliceEnd += maxSlotsMul2
-		} else {
-			sliceEnd = numSlots
-		}
-		err := <-mul2(g, x.GetSubBuffer(i, sliceEnd), intSlice(y[i:sliceEnd]), intSlice(result[i:sliceEnd]), env, stream)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return Mul2Chunk(p, g, x, IntSlice(y), IntSlice(result))
 }
 
 // mul2 runs the mul2 operation on precomputation and cypher payloads inside
@@ -114,7 +82,7 @@ var Mul2Slice Mul2SlicePrototype = func(p *StreamPool, g *cyclic.Group, x *cycli
 // equal to the length of the template-instantiated BN on the GPU.
 // bnLength is a length in bits
 // puts output in results int buffer
-func mul2(g *cyclic.Group, x intGetter, y intGetter, results intGetter, env gpumathsEnv, stream Stream) chan error {
+func mul2(g *cyclic.Group, x, y, results Operands, env gpumathsEnv, stream Stream) chan error {
 	debugPrint := false
 	callId := rand.Intn(9999)
 	start := time.Now()
@@ -131,22 +99,11 @@ func mul2(g *cyclic.Group, x intGetter, y intGetter, results intGetter, env gpum
 		// TODO clean this up by implementing the
 		// arrangement/dearrangement with reader/writer interfaces
 		// or smth
-		constants := stream.getCpuConstantsWords(env, kernelMul2)
+		stream.putConstants(env, kernelMul2, g.GetP().Bits())
 		bnLengthWords := env.getWordLen()
-		putBits(constants, g.GetP().Bits(), bnLengthWords)
-		offset := 0
-		offset += bnLengthWords
 
 		inputs := stream.getCpuInputsWords(env, kernelMul2, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Put the first operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], x.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			// Put the second operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], y.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
+		packInputs(inputs, bnLengthWords, numSlots, x, y)
 		if debugPrint {
 			println("Call", callId, "post input arrangement", time.Since(start))
 			start = time.Now()
@@ -178,13 +135,7 @@ func mul2(g *cyclic.Group, x intGetter, y intGetter, results intGetter, env gpum
 		}
 
 		// Everything is OK, so let's go ahead and import the results
-
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Output the computed result into each slot
-			g.OverwriteBits(results.Get(i), outputs[offset:offset+bnLengthWords])
-			offset += bnLengthWords
-		}
+		unpackOutputs(g, outputs, bnLengthWords, numSlots, results)
 
 		if debugPrint {
 			println("Call", callId, "post output arrangement", time.Since(start))
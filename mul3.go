@@ -9,7 +9,7 @@ package gpumaths
 import "gitlab.com/elixxir/crypto/cyclic"
 
 type Mul3ChunkPrototype func(p *StreamPool, g *cyclic.Group,
-	x, y, z, result *cyclic.IntBuffer) error
+	x, y, z, result Operands) error
 
 // GetInputSize is how big chunk sizes should be to run the mul3 operation
 func (Mul3ChunkPrototype) GetInputSize() uint32 {
@@ -9,13 +9,5 @@
 
 package gpumaths
 
-import (
-	"github.com/pkg/errors"
-	"gitlab.com/elixxir/crypto/cyclic"
-)
-
-// Mul3Chunk is stubbed unless GPU is present.
-var Mul3Chunk Mul3ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.IntBuffer, result *cyclic.IntBuffer) error {
-	return errors.New(NoGpuErrStr)
-}
+// Mul3Chunk computes result = x*y*z for every slot on the CPU
+var Mul3Chunk Mul3ChunkPrototype = cpuMul3Chunk
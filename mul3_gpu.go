@@ -26,14 +26,17 @@ const kernelMul3 = C.KERNEL_MUL3
 // payloads
 // Precondition: All int buffers must have the same length
 var Mul3Chunk Mul3ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.IntBuffer, results *cyclic.IntBuffer) error {
+	x, y, z, results Operands) error {
 	// Populate mul3 inputs
 	numSlots := uint32(x.Len())
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
-	defer p.ReturnStream(stream)
 	env := chooseEnv(g)
+	stream, err := p.TakeStreamFitting(env.streamSizeContaining(int(numSlots), kernelMul3))
+	if err != nil {
+		return err
+	}
+	defer p.ReturnStream(stream)
 	maxSlotsMul3 := uint32(env.maxSlots(len(stream.cpuData), kernelMul3))
 	if numSlots > maxSlotsMul3 {
 		jww.WARN.Printf("Running multiple kernels for Mul3Chunk. Performance may be degraded")
@@ -46,16 +49,21 @@ var Mul3Chunk Mul3ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 		} else {
 			sliceEnd = numSlots
 		}
-		err := <-mul3(g, x.GetSubBuffer(i, sliceEnd), y.GetSubBuffer(i, sliceEnd), z.GetSubBuffer(i, sliceEnd), results.GetSubBuffer(i, sliceEnd), env, stream)
+		err := <-mul3(g, SubRange(x, i, sliceEnd), SubRange(y, i, sliceEnd), SubRange(z, i, sliceEnd), SubRange(results, i, sliceEnd), env, stream)
 		if err != nil {
+			p.markFailed(stream, err)
 			return err
 		}
 	}
 
+	if p.SecretInputs() {
+		return p.wipeInputs(env, g, stream, kernelMul3, int(numSlots),
+			g.GetP().Bits())
+	}
 	return nil
 }
 
-func mul3(g *cyclic.Group, x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.IntBuffer, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
+func mul3(g *cyclic.Group, x, y, z, result Operands, env gpumathsEnv, stream Stream) chan error {
 	debugPrint := false
 	callId := rand.Intn(9999)
 	start := time.Now()
@@ -72,25 +80,11 @@ func mul3(g *cyclic.Group, x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.I
 		// TODO clean this up by implementing the
 		// arrangement/dearrangement with reader/writer interfaces
 		// or smth
-		constants := stream.getCpuConstantsWords(env, kernelMul3)
+		stream.putConstants(env, kernelMul3, g.GetP().Bits())
 		bnLengthWords := env.getWordLen()
-		putBits(constants, g.GetP().Bits(), bnLengthWords)
-		offset := 0
-		offset += bnLengthWords
 
 		inputs := stream.getCpuInputsWords(env, kernelMul3, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Put the first operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], x.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			// Put the second operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], y.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-			// Put the third operand for this slot
-			putBits(inputs[offset:offset+bnLengthWords], z.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
+		packInputs(inputs, bnLengthWords, numSlots, x, y, z)
 		if debugPrint {
 			println("Call", callId, "post input arrangement", time.Since(start))
 			start = time.Now()
@@ -122,13 +116,7 @@ func mul3(g *cyclic.Group, x *cyclic.IntBuffer, y *cyclic.IntBuffer, z *cyclic.I
 		}
 
 		// Everything is OK, so let's go ahead and import the results
-
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Output the computed result into each slot
-			g.OverwriteBits(result.Get(i), outputs[offset:offset+bnLengthWords])
-			offset += bnLengthWords
-		}
+		unpackOutputs(g, outputs, bnLengthWords, numSlots, result)
 
 		if debugPrint {
 			println("Call", callId, "post output arrangement", time.Since(start))
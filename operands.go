@@ -0,0 +1,140 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "gitlab.com/elixxir/crypto/cyclic"
+
+// operands.go contains the types that operations read their inputs from and
+// write their results to. Any of them can be passed wherever an operation
+// takes Operands, and the chunking loops split all of them into sub-ranges
+// the same way.
+
+// Operands is a source or sink of cyclic ints for an operation
+// *cyclic.IntBuffer implements it, as do IntSlice and the strided and
+// indexed views below. Results are written through the ints returned by Get,
+// so they must point at the caller's storage rather than copies.
+type Operands interface {
+	Get(index uint32) *cyclic.Int
+	Len() int
+}
+
+// Operands that can return a view of one of their own sub-ranges should
+// implement this. Other operands get wrapped in a generic range view.
+type subRanger interface {
+	SubRange(begin, end uint32) Operands
+}
+
+// SubRange returns a view of elements [begin, end) of o
+func SubRange(o Operands, begin, end uint32) Operands {
+	switch v := o.(type) {
+	case *cyclic.IntBuffer:
+		return v.GetSubBuffer(begin, end)
+	case subRanger:
+		return v.SubRange(begin, end)
+	default:
+		return rangeOperands{src: o, start: begin, n: int(end - begin)}
+	}
+}
+
+// IntSlice implements Operands with a slice of cyclic ints
+type IntSlice []*cyclic.Int
+
+// Get returns the int at index
+func (s IntSlice) Get(index uint32) *cyclic.Int {
+	return s[index]
+}
+
+// Len returns the number of ints in the slice
+func (s IntSlice) Len() int {
+	return len(s)
+}
+
+// SubRange reslices the slice
+func (s IntSlice) SubRange(begin, end uint32) Operands {
+	return s[begin:end]
+}
+
+// StridedOperands views every Stride-th element of Src, starting at Start
+type StridedOperands struct {
+	Src    Operands
+	Start  uint32
+	Stride uint32
+	N      int
+}
+
+// Strided returns a view of n elements of src, starting at start and
+// stepping by stride
+func Strided(src Operands, start, stride uint32, n int) StridedOperands {
+	return StridedOperands{Src: src, Start: start, Stride: stride, N: n}
+}
+
+// Get returns element Start+index*Stride of Src
+func (s StridedOperands) Get(index uint32) *cyclic.Int {
+	return s.Src.Get(s.Start + index*s.Stride)
+}
+
+// Len returns the number of elements in the view
+func (s StridedOperands) Len() int {
+	return s.N
+}
+
+// SubRange returns a strided view of the elements [begin, end) of this view
+func (s StridedOperands) SubRange(begin, end uint32) Operands {
+	return StridedOperands{
+		Src:    s.Src,
+		Start:  s.Start + begin*s.Stride,
+		Stride: s.Stride,
+		N:      int(end - begin),
+	}
+}
+
+// IndexedOperands views the elements of Src at Indices, in that order
+type IndexedOperands struct {
+	Src     Operands
+	Indices []uint32
+}
+
+// Indexed returns a view of the elements of src at indices
+func Indexed(src Operands, indices []uint32) IndexedOperands {
+	return IndexedOperands{Src: src, Indices: indices}
+}
+
+// Get returns element Indices[index] of Src
+func (s IndexedOperands) Get(index uint32) *cyclic.Int {
+	return s.Src.Get(s.Indices[index])
+}
+
+// Len returns the number of indices
+func (s IndexedOperands) Len() int {
+	return len(s.Indices)
+}
+
+// SubRange slices the indices, which can still point anywhere in Src
+func (s IndexedOperands) SubRange(begin, end uint32) Operands {
+	return IndexedOperands{Src: s.Src, Indices: s.Indices[begin:end]}
+}
+
+// rangeOperands is the fallback sub-range view for operands that don't
+// implement subRanger
+type rangeOperands struct {
+	src   Operands
+	start uint32
+	n     int
+}
+
+func (r rangeOperands) Get(index uint32) *cyclic.Int {
+	return r.src.Get(r.start + index)
+}
+
+func (r rangeOperands) Len() int {
+	return r.n
+}
+
+func (r rangeOperands) SubRange(begin, end uint32) Operands {
+	return rangeOperands{src: r.src, start: r.start + begin, n: int(end - begin)}
+}
@@ -0,0 +1,100 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"testing"
+)
+
+// Helper functions shared by tests are located in gpu_test.go
+
+// toIntSlice makes a slice that points into buf
+func toIntSlice(buf *cyclic.IntBuffer) IntSlice {
+	result := make(IntSlice, buf.Len())
+	for i := range result {
+		result[i] = buf.Get(uint32(i))
+	}
+	return result
+}
+
+// Runs operations with a mix of operand types, with a stream that's too small
+// to hold all the slots so the views get split into sub-ranges
+func TestMixedOperands(t *testing.T) {
+	const batchSize = 30
+	grp := initTestGroup()
+	env := chooseEnv(grp)
+	streamPool, err := NewStreamPool(1, env.streamSizeContaining(batchSize, kernelElgamal)/4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := toIntSlice(initRandomIntBuffer(grp, batchSize, 42, 0))
+	// Exponents are every other element of a buffer twice as long
+	y := Strided(initRandomIntBuffer(grp, batchSize*2, 43, 0), 1, 2, batchSize)
+	// Results are written in reverse order
+	zBuf := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	reversed := make([]uint32, batchSize)
+	for i := range reversed {
+		reversed[i] = uint32(batchSize - 1 - i)
+	}
+	z := Indexed(zBuf, reversed)
+
+	_, err = ExpChunk(streamPool, grp, x, y, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < batchSize; i++ {
+		expected := cryptops.Exp(grp, x.Get(i), y.Get(i), grp.NewInt(1))
+		if zBuf.Get(batchSize-1-i).Cmp(expected) != 0 {
+			t.Errorf("exp mismatch on index %v", i)
+		}
+	}
+
+	mul3Result := toIntSlice(grp.NewIntBuffer(batchSize, grp.NewInt(1)))
+	err = Mul3Chunk(streamPool, grp, x, y, z, mul3Result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < batchSize; i++ {
+		expected := cryptops.Mul3(grp, x.Get(i), y.Get(i), z.Get(i).DeepCopy())
+		if mul3Result.Get(i).Cmp(expected) != 0 {
+			t.Errorf("mul3 mismatch on index %v", i)
+		}
+	}
+
+	publicCypherKey := grp.NewInt(1)
+	grp.Random(publicCypherKey)
+	key := toIntSlice(initRandomIntBuffer(grp, batchSize, 44, 0))
+	ecrKey := toIntSlice(initRandomIntBuffer(grp, batchSize, 45, 0))
+	cypher := toIntSlice(initRandomIntBuffer(grp, batchSize, 46, 0))
+	expectedEcrKey := make([]*cyclic.Int, batchSize)
+	expectedCypher := make([]*cyclic.Int, batchSize)
+	for i := uint32(0); i < batchSize; i++ {
+		expectedEcrKey[i] = ecrKey[i].DeepCopy()
+		expectedCypher[i] = cypher[i].DeepCopy()
+		cryptops.ElGamal(grp, key[i], y.Get(i), publicCypherKey, expectedEcrKey[i], expectedCypher[i])
+	}
+	err = ElGamalChunk(streamPool, grp, key, y, publicCypherKey, ecrKey, cypher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < batchSize; i++ {
+		if ecrKey[i].Cmp(expectedEcrKey[i]) != 0 || cypher[i].Cmp(expectedCypher[i]) != 0 {
+			t.Errorf("elgamal mismatch on index %v", i)
+		}
+	}
+
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+// Checks that every element of o is the expected small value
+func checkOperands(t *testing.T, name string, o Operands, expected []int64) {
+	t.Helper()
+	if o.Len() != len(expected) {
+		t.Fatalf("%v: length %v, expected %v", name, o.Len(), len(expected))
+	}
+	for i, e := range expected {
+		if got := o.Get(uint32(i)).GetLargeInt().Int64(); got != e {
+			t.Errorf("%v: element %v was %v, expected %v", name, i, got, e)
+		}
+	}
+}
+
+func TestOperandViews(t *testing.T) {
+	grp := makeTestGroup2048()
+	buf := grp.NewIntBuffer(10, grp.NewInt(1))
+	slice := make(IntSlice, 10)
+	for i := uint32(0); i < 10; i++ {
+		// SetLargeInt won't write 0, because it's outside the group
+		grp.SetUint64(buf.Get(i), uint64(i))
+		slice[i] = buf.Get(i)
+	}
+
+	checkOperands(t, "buffer sub-range", SubRange(buf, 2, 5), []int64{2, 3, 4})
+	checkOperands(t, "slice sub-range", SubRange(slice, 7, 10), []int64{7, 8, 9})
+
+	strided := Strided(buf, 1, 3, 3)
+	checkOperands(t, "strided", strided, []int64{1, 4, 7})
+	checkOperands(t, "strided sub-range", SubRange(strided, 1, 3), []int64{4, 7})
+
+	indexed := Indexed(slice, []uint32{9, 0, 5, 5})
+	checkOperands(t, "indexed", indexed, []int64{9, 0, 5, 5})
+	checkOperands(t, "indexed sub-range", SubRange(indexed, 1, 3), []int64{0, 5})
+
+	// Views of views that don't implement SubRange themselves
+	generic := SubRange(rangeOperands{src: strided, start: 0, n: 3}, 1, 2)
+	checkOperands(t, "generic sub-range", generic, []int64{4})
+	nested := SubRange(rangeOperands{src: buf, start: 3, n: 6}, 2, 5)
+	checkOperands(t, "nested generic sub-range", SubRange(nested, 1, 3), []int64{6, 7})
+
+	// Writes through a view should land in the underlying buffer
+	grp.SetLargeInt(SubRange(strided, 2, 3).Get(0), large.NewInt(70))
+	if buf.Get(7).GetLargeInt().Int64() != 70 {
+		t.Error("Write through strided view didn't reach the buffer")
+	}
+}
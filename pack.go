@@ -0,0 +1,60 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// pack.go arranges operands in a stream's inputs and imports results from
+// its outputs. Large batches are split across goroutines, since converting
+// thousands of 4096-bit ints takes a noticeable fraction of the kernel time.
+// Nothing here needs CUDA, so the fuzz tests run it in both builds.
+
+// packInputs puts each slot's operands in inputs, one bignum per operand, in
+// the order that they're passed
+func packInputs(inputs large.Bits, bnLengthWords int, numSlots uint32,
+	operands ...Operands) {
+	slotWords := bnLengthWords * len(operands)
+	forEachSlotRange(numSlots, func(begin, end uint32) {
+		offset := int(begin) * slotWords
+		for i := begin; i < end; i++ {
+			for _, o := range operands {
+				putBits(inputs[offset:offset+bnLengthWords], o.Get(i).Bits(),
+					bnLengthWords)
+				offset += bnLengthWords
+			}
+		}
+	})
+}
+
+// unpackOutputs overwrites each slot of the results with the kernel's
+// outputs, one bignum per result, in the order that they're passed
+// Every slot of the results has to be a different int.
+func unpackOutputs(g *cyclic.Group, outputs large.Bits, bnLengthWords int,
+	numSlots uint32, results ...Operands) {
+	slotWords := bnLengthWords * len(results)
+	forEachSlotRange(numSlots, func(begin, end uint32) {
+		offset := int(begin) * slotWords
+		for i := begin; i < end; i++ {
+			for _, r := range results {
+				g.OverwriteBits(r.Get(i), outputs[offset:offset+bnLengthWords])
+				offset += bnLengthWords
+			}
+		}
+	})
+}
+
+// putBits() copies bits from one array to another and right-pads any remaining words with zeroes
+func putBits(dst large.Bits, src large.Bits, n int) {
+	copy(dst, src)
+	for i := len(src); i < len(dst) && i < n; i++ {
+		dst[i] = 0
+	}
+}
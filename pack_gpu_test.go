@@ -0,0 +1,81 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+// Packing then unpacking should give back the same operands
+func TestPackUnpack(t *testing.T) {
+	const numSlots = 1000
+	g := makeTestGroup4096()
+	bnLengthWords := chooseEnv(g).getWordLen()
+	x := initRandomIntBuffer(g, numSlots, 42, 0)
+	y := initRandomIntBuffer(g, numSlots, 43, 32)
+	words := make(large.Bits, 2*numSlots*bnLengthWords)
+	packInputs(words, bnLengthWords, numSlots, x, y)
+
+	xOut := g.NewIntBuffer(numSlots, g.NewInt(1))
+	yOut := g.NewIntBuffer(numSlots, g.NewInt(1))
+	unpackOutputs(g, words, bnLengthWords, numSlots, xOut, yOut)
+	for i := uint32(0); i < numSlots; i++ {
+		if x.Get(i).Cmp(xOut.Get(i)) != 0 || y.Get(i).Cmp(yOut.Get(i)) != 0 {
+			t.Fatalf("Slot %v changed when packed and unpacked", i)
+		}
+	}
+}
+
+// Packs two 4096-bit operands per slot, like exp does
+func runPackInputs(b *testing.B, numSlots uint32, threads int) {
+	defer func(old int) { maxPackThreads = old }(maxPackThreads)
+	maxPackThreads = threads
+	g := makeTestGroup4096()
+	bnLengthWords := chooseEnv(g).getWordLen()
+	x := initRandomIntBuffer(g, numSlots, 42, 0)
+	y := initRandomIntBuffer(g, numSlots, 43, 0)
+	inputs := make(large.Bits, 2*int(numSlots)*bnLengthWords)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		packInputs(inputs, bnLengthWords, numSlots, x, y)
+	}
+}
+
+// Imports one 4096-bit result per slot, like exp does
+func runUnpackOutputs(b *testing.B, numSlots uint32, threads int) {
+	defer func(old int) { maxPackThreads = old }(maxPackThreads)
+	maxPackThreads = threads
+	g := makeTestGroup4096()
+	bnLengthWords := chooseEnv(g).getWordLen()
+	x := initRandomIntBuffer(g, numSlots, 42, 0)
+	outputs := make(large.Bits, int(numSlots)*bnLengthWords)
+	packInputs(outputs, bnLengthWords, numSlots, x)
+	results := g.NewIntBuffer(numSlots, g.NewInt(1))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		unpackOutputs(g, outputs, bnLengthWords, numSlots, results)
+	}
+}
+
+func BenchmarkPackInputs4096_32768_Serial(b *testing.B) {
+	runPackInputs(b, 32768, 1)
+}
+func BenchmarkPackInputs4096_32768_Parallel(b *testing.B) {
+	runPackInputs(b, 32768, 0)
+}
+func BenchmarkUnpackOutputs4096_32768_Serial(b *testing.B) {
+	runUnpackOutputs(b, 32768, 1)
+}
+func BenchmarkUnpackOutputs4096_32768_Parallel(b *testing.B) {
+	runUnpackOutputs(b, 32768, 0)
+}
@@ -0,0 +1,51 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Below this many slots per goroutine, packing in parallel isn't worth
+// starting the goroutines
+const minPackSlotsPerThread = 128
+
+// Most goroutines that forEachSlotRange uses, or zero for one per CPU
+// Benchmarks set this to 1 to compare with packing on one goroutine.
+var maxPackThreads = 0
+
+// forEachSlotRange splits [0, numSlots) into disjoint ranges, calls fn on
+// each range in its own goroutine, and waits for them all to finish
+// Small batches are run in the calling goroutine.
+func forEachSlotRange(numSlots uint32, fn func(begin, end uint32)) {
+	numThreads := uint64(runtime.NumCPU())
+	if maxPackThreads > 0 && uint64(maxPackThreads) < numThreads {
+		numThreads = uint64(maxPackThreads)
+	}
+	if maxThreads := uint64(numSlots / minPackSlotsPerThread); maxThreads < numThreads {
+		numThreads = maxThreads
+	}
+	if numThreads <= 1 {
+		fn(0, numSlots)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for t := uint64(0); t < numThreads; t++ {
+		wg.Add(1)
+		// 64 bits so the multiplication can't overflow
+		begin := uint32(uint64(numSlots) * t / numThreads)
+		end := uint32(uint64(numSlots) * (t + 1) / numThreads)
+		go func() {
+			defer wg.Done()
+			fn(begin, end)
+		}()
+	}
+	wg.Wait()
+}
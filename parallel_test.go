@@ -0,0 +1,41 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// Every slot should be visited exactly once, whatever the batch size
+func TestForEachSlotRange(t *testing.T) {
+	defer func(old int) { maxPackThreads = old }(maxPackThreads)
+	for _, threads := range []int{0, 1, 3} {
+		maxPackThreads = threads
+		for _, numSlots := range []uint32{0, 1, minPackSlotsPerThread - 1,
+			minPackSlotsPerThread * 2, 10007} {
+			visits := make([]int32, numSlots)
+			forEachSlotRange(numSlots, func(begin, end uint32) {
+				if begin > end || end > numSlots {
+					t.Errorf("Bad range [%v, %v) of %v slots", begin, end,
+						numSlots)
+					return
+				}
+				for i := begin; i < end; i++ {
+					atomic.AddInt32(&visits[i], 1)
+				}
+			})
+			for i, v := range visits {
+				if v != 1 {
+					t.Fatalf("Slot %v of %v was visited %v times with %v threads",
+						i, numSlots, v, threads)
+				}
+			}
+		}
+	}
+}
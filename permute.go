@@ -0,0 +1,64 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+)
+
+// permute.go contains the types for the permuting mul2 operation used by the
+// cMix permute phase. The GPU call is in permute_gpu.go and the CPU reference
+// implementation is in permute_cpu.go.
+
+// PermuteMul2ChunkPrototype writes x[permutation[i]] * y[i] into results[i]
+// The permutation must be a bijection on [0, len(x)), and results must not
+// share memory with x, because x is read out of order
+type PermuteMul2ChunkPrototype func(p *StreamPool, g *cyclic.Group,
+	x, y Operands, permutation []uint32, results Operands) error
+
+// GetInputSize is how big chunk sizes should be to run the permute operation
+func (PermuteMul2ChunkPrototype) GetInputSize() uint32 {
+	return 256
+}
+
+// GetName returns the name of the PermuteMul2Chunk operation
+func (PermuteMul2ChunkPrototype) GetName() string {
+	return "PermuteMul2Chunk"
+}
+
+// checkPermuteMul2 returns an error unless x, y and results have the same
+// length and permutation is a bijection on their slots
+func checkPermuteMul2(x, y Operands, permutation []uint32, results Operands) error {
+	if y.Len() != x.Len() || results.Len() != x.Len() {
+		return errors.Errorf("x, y and results have %v, %v and %v slots, but "+
+			"they must all be the same length", x.Len(), y.Len(), results.Len())
+	}
+	return validatePermutation(permutation, x.Len())
+}
+
+// validatePermutation returns an error unless permutation maps [0, n) onto
+// itself with no repeats
+func validatePermutation(permutation []uint32, n int) error {
+	if len(permutation) != n {
+		return errors.Errorf("permutation has %v entries, but there are %v slots",
+			len(permutation), n)
+	}
+	seen := make([]bool, n)
+	for i, dst := range permutation {
+		if int(dst) >= n {
+			return errors.Errorf("permutation entry %v is %v, which is out of range for %v slots",
+				i, dst, n)
+		}
+		if seen[dst] {
+			return errors.Errorf("permutation entry %v repeats index %v", i, dst)
+		}
+		seen[dst] = true
+	}
+	return nil
+}
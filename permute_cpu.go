@@ -0,0 +1,25 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import "gitlab.com/elixxir/crypto/cyclic"
+
+// PermuteMul2Chunk is the CPU reference implementation of the permuting mul2
+var PermuteMul2Chunk PermuteMul2ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	x, y Operands, permutation []uint32, results Operands) error {
+	err := checkPermuteMul2(x, y, permutation, results)
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		g.Mul(x.Get(permutation[i]), y.Get(i), results.Get(i))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+func TestPermuteMul2Chunk(t *testing.T) {
+	grp := makeTestGroup2048()
+	x := grp.NewIntBuffer(4, grp.NewInt(1))
+	y := grp.NewIntBuffer(4, grp.NewInt(1))
+	results := grp.NewIntBuffer(4, grp.NewInt(1))
+	for i := uint32(0); i < 4; i++ {
+		grp.SetLargeInt(x.Get(i), large.NewInt(int64(i)+2))
+		grp.SetLargeInt(y.Get(i), large.NewInt(10))
+	}
+
+	err := PermuteMul2Chunk(nil, grp, x, y, []uint32{3, 0, 2, 1}, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []int64{50, 20, 40, 30} {
+		if results.Get(uint32(i)).GetLargeInt().Int64() != expected {
+			t.Errorf("Slot %v was %v, expected %v", i,
+				results.Get(uint32(i)).Text(10), expected)
+		}
+	}
+
+	err = PermuteMul2Chunk(nil, grp, x, y, []uint32{3, 0, 2, 2}, results)
+	if err == nil {
+		t.Error("A permutation with a repeated index should be rejected")
+	}
+}
@@ -0,0 +1,63 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cyclic"
+)
+
+// permute_gpu.go runs the permuting mul2 on the mul2 kernel. The permutation
+// is applied through an indexed view while the inputs are arranged into the
+// stream buffer, so there's no extra pass over the data.
+
+// PermuteMul2Chunk performs the permuting mul2 operation
+// Precondition: All int buffers must have the same length
+var PermuteMul2Chunk PermuteMul2ChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	x, y Operands, permutation []uint32, results Operands) error {
+	err := checkPermuteMul2(x, y, permutation, results)
+	if err != nil {
+		return err
+	}
+	numSlots := uint32(x.Len())
+
+	// Run kernel on the inputs
+	env := chooseEnv(g)
+	stream, err := p.TakeStreamFitting(env.streamSizeContaining(int(numSlots), kernelMul2))
+	if err != nil {
+		return err
+	}
+	defer p.ReturnStream(stream)
+	maxSlotsMul2 := uint32(env.maxSlots(len(stream.cpuData), kernelMul2))
+	if numSlots > maxSlotsMul2 {
+		jww.WARN.Printf("Running multiple kernels for PermuteMul2Chunk. Performance may be degraded")
+	}
+	for i := uint32(0); i < numSlots; i += maxSlotsMul2 {
+		sliceEnd := i
+		// Don't slice beyond the end of the input slice
+		if i+maxSlotsMul2 <= numSlots {
+			sliceEnd += maxSlotsMul2
+		} else {
+			sliceEnd = numSlots
+		}
+		// Only the permutation is sliced, because it can point anywhere in x
+		permuted := Indexed(x, permutation[i:sliceEnd])
+		err := <-mul2(g, permuted, SubRange(y, i, sliceEnd), SubRange(results, i, sliceEnd), env, stream)
+		if err != nil {
+			p.markFailed(stream, err)
+			return err
+		}
+	}
+
+	if p.SecretInputs() {
+		return p.wipeInputs(env, g, stream, kernelMul2, int(numSlots))
+	}
+	return nil
+}
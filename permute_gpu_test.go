@@ -0,0 +1,57 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Helper functions shared by tests are located in gpu_test.go
+
+func TestPermuteMul2Chunk(t *testing.T) {
+	const batchSize = 100
+	grp := initTestGroup()
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+	results := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	permutation := make([]uint32, batchSize)
+	for i, dst := range rand.New(rand.NewSource(44)).Perm(batchSize) {
+		permutation[i] = uint32(dst)
+	}
+
+	// Ensure correct behavior if the stream doesn't have enough memory to process the whole chunk
+	env := chooseEnv(grp)
+	streamPool, err := NewStreamPool(1, env.streamSizeContaining(batchSize, kernelMul2)/3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = PermuteMul2Chunk(streamPool, grp, x, y, permutation, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < batchSize; i++ {
+		expected := grp.Mul(x.Get(permutation[i]), y.Get(i), grp.NewInt(1))
+		if results.Get(i).Cmp(expected) != 0 {
+			t.Errorf("permuted mul2 mismatch on index %v", i)
+		}
+	}
+
+	permutation[0] = permutation[1]
+	err = PermuteMul2Chunk(streamPool, grp, x, y, permutation, results)
+	if err == nil {
+		t.Error("A permutation with a repeated index should be rejected")
+	}
+
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
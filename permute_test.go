@@ -0,0 +1,50 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "testing"
+
+func TestValidatePermutation(t *testing.T) {
+	valid := [][]uint32{{}, {0}, {2, 0, 1}, {3, 2, 1, 0}}
+	for _, permutation := range valid {
+		if err := validatePermutation(permutation, len(permutation)); err != nil {
+			t.Errorf("%v should be a valid permutation: %v", permutation, err)
+		}
+	}
+
+	invalid := []struct {
+		permutation []uint32
+		n           int
+	}{
+		// Wrong length
+		{[]uint32{0, 1}, 3},
+		// Out of range
+		{[]uint32{0, 3, 1}, 3},
+		// Repeated index
+		{[]uint32{1, 1, 0}, 3},
+	}
+	for _, c := range invalid {
+		if err := validatePermutation(c.permutation, c.n); err == nil {
+			t.Errorf("%v should be an invalid permutation of %v slots", c.permutation, c.n)
+		}
+	}
+}
+
+// Buffers of different lengths should be an error, not a panic
+func TestPermuteMul2Chunk_Lengths(t *testing.T) {
+	g := makeTestGroup4096()
+	long := g.NewIntBuffer(4, g.NewInt(1))
+	short := g.NewIntBuffer(3, g.NewInt(1))
+	permutation := []uint32{3, 2, 1, 0}
+	if PermuteMul2Chunk(nil, g, long, short, permutation, long.DeepCopy()) == nil {
+		t.Error("A short y should be an error")
+	}
+	if PermuteMul2Chunk(nil, g, long, long.DeepCopy(), permutation, short) == nil {
+		t.Error("Short results should be an error")
+	}
+}
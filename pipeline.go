@@ -0,0 +1,479 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"runtime"
+	"sync"
+)
+
+// pipeline.go lets a phase declare its ops and the buffers they read and
+// write once, e.g.
+//
+//	plan, err := NewPipeline(g, batchSize).
+//		Input("keys", "privateKeys").
+//		Output("ecrKeys", "cyphers").
+//		ElGamal("keys", "privateKeys", publicCypherKey, "ecrKeys", "cyphers").
+//		Compile()
+//
+// and then run it with a PipelineExecutor. Every op in a pipeline works slot
+// by slot, so the executor splits the slots into a partition per stream, or
+// per CPU, and each partition runs the whole pipeline on its own, overlapping
+// stages with the other partitions. Buffers that aren't inputs or outputs
+// are intermediates, which the executor allocates for each partition,
+// sharing one buffer between intermediates whose lifetimes don't overlap.
+//
+// With the gpu tag, each partition of the stream executor takes one stream
+// for the whole run and launches its stages' kernels on it in order (see
+// pipeline_gpu.go). Intermediates stay in the kernels' word layout, so they
+// aren't converted to cyclic ints and back between stages. The native
+// library uploads every kernel's inputs and downloads its outputs, so they
+// do still go through host memory. The CPU executor runs CPUOps, with the
+// stages that don't depend on each other at the same time.
+
+// Pipeline is a list of ops on named buffers that all have numSlots slots
+// Declaration errors are returned by Compile.
+type Pipeline struct {
+	g        *cyclic.Group
+	numSlots uint32
+	inputs   map[string]bool
+	outputs  map[string]bool
+	stages   []*pipelineStage
+	err      error
+}
+
+// pipelineStage is one op in a pipeline
+type pipelineStage struct {
+	op string
+	// Buffers in the op's argument order
+	args []string
+	// Indexes of the args that the op writes
+	writes []int
+	// Whether the op reads the args that it writes, too
+	inPlace         bool
+	publicCypherKey *cyclic.Int
+	// Runs the op from ops on the stream pool
+	run func(ops Ops, p *StreamPool, g *cyclic.Group, k *cyclic.Int,
+		a []Operands) error
+}
+
+// NewPipeline starts a pipeline on buffers of numSlots slots in g
+func NewPipeline(g *cyclic.Group, numSlots uint32) *Pipeline {
+	return &Pipeline{
+		g:        g,
+		numSlots: numSlots,
+		inputs:   make(map[string]bool),
+		outputs:  make(map[string]bool),
+	}
+}
+
+// Input declares buffers that the caller passes in, and that aren't written
+func (pl *Pipeline) Input(names ...string) *Pipeline {
+	for _, name := range names {
+		if pl.outputs[name] {
+			pl.fail(errors.Errorf("%v is already an output", name))
+		}
+		pl.inputs[name] = true
+	}
+	return pl
+}
+
+// Output declares buffers that the caller passes in to get results
+// Outputs can also be read, e.g. for ops that update a buffer in place.
+func (pl *Pipeline) Output(names ...string) *Pipeline {
+	for _, name := range names {
+		if pl.inputs[name] {
+			pl.fail(errors.Errorf("%v is already an input", name))
+		}
+		pl.outputs[name] = true
+	}
+	return pl
+}
+
+// ElGamal adds an ElGamalChunk stage, which updates ecrKey and cypher
+func (pl *Pipeline) ElGamal(key, privateKey string,
+	publicCypherKey *cyclic.Int, ecrKey, cypher string) *Pipeline {
+	return pl.add(&pipelineStage{
+		op:              ElGamalChunk.GetName(),
+		args:            []string{key, privateKey, ecrKey, cypher},
+		writes:          []int{2, 3},
+		inPlace:         true,
+		publicCypherKey: publicCypherKey,
+		run: func(ops Ops, p *StreamPool, g *cyclic.Group, k *cyclic.Int,
+			a []Operands) error {
+			return ops.ElGamalChunk(p, g, a[0], a[1], k, a[2], a[3])
+		},
+	})
+}
+
+// Exp adds an ExpChunk stage, which computes z = x**y
+func (pl *Pipeline) Exp(x, y, z string) *Pipeline {
+	return pl.add(&pipelineStage{
+		op:     ExpChunk.GetName(),
+		args:   []string{x, y, z},
+		writes: []int{2},
+		run: func(ops Ops, p *StreamPool, g *cyclic.Group, k *cyclic.Int,
+			a []Operands) error {
+			_, err := ops.ExpChunk(p, g, a[0], a[1], a[2])
+			return err
+		},
+	})
+}
+
+// Mul2 adds a Mul2Chunk stage, which computes result = x*y
+func (pl *Pipeline) Mul2(x, y, result string) *Pipeline {
+	return pl.add(&pipelineStage{
+		op:     Mul2Chunk.GetName(),
+		args:   []string{x, y, result},
+		writes: []int{2},
+		run: func(ops Ops, p *StreamPool, g *cyclic.Group, k *cyclic.Int,
+			a []Operands) error {
+			return ops.Mul2Chunk(p, g, a[0], a[1], a[2])
+		},
+	})
+}
+
+// Mul3 adds a Mul3Chunk stage, which computes result = x*y*z
+func (pl *Pipeline) Mul3(x, y, z, result string) *Pipeline {
+	return pl.add(&pipelineStage{
+		op:     Mul3Chunk.GetName(),
+		args:   []string{x, y, z, result},
+		writes: []int{3},
+		run: func(ops Ops, p *StreamPool, g *cyclic.Group, k *cyclic.Int,
+			a []Operands) error {
+			return ops.Mul3Chunk(p, g, a[0], a[1], a[2], a[3])
+		},
+	})
+}
+
+// Reveal adds a RevealChunk stage, which takes the publicCypherKey'th root
+// of cypher
+func (pl *Pipeline) Reveal(publicCypherKey *cyclic.Int, cypher,
+	result string) *Pipeline {
+	return pl.add(&pipelineStage{
+		op:              RevealChunk.GetName(),
+		args:            []string{cypher, result},
+		writes:          []int{1},
+		publicCypherKey: publicCypherKey,
+		run: func(ops Ops, p *StreamPool, g *cyclic.Group, k *cyclic.Int,
+			a []Operands) error {
+			return ops.RevealChunk(p, g, k, a[0], a[1])
+		},
+	})
+}
+
+func (pl *Pipeline) add(s *pipelineStage) *Pipeline {
+	for _, name := range s.args {
+		if name == "" {
+			pl.fail(errors.Errorf("%v stage %v has an unnamed buffer", s.op,
+				len(pl.stages)))
+		}
+	}
+	pl.stages = append(pl.stages, s)
+	return pl
+}
+
+// fail remembers the first declaration error
+func (pl *Pipeline) fail(err error) {
+	if pl.err == nil {
+		pl.err = err
+	}
+}
+
+// PipelinePlan is a checked pipeline, with its stages grouped into levels
+// that only depend on earlier levels, and its intermediates assigned to
+// shared buffers
+type PipelinePlan struct {
+	pl     *Pipeline
+	levels [][]*pipelineStage
+	// Shared buffer for each intermediate
+	temps    map[string]int
+	numTemps int
+}
+
+// Compile checks the pipeline and plans how to run it
+// Inputs can't be written, and intermediates have to be written before
+// they're read.
+func (pl *Pipeline) Compile() (*PipelinePlan, error) {
+	if pl.err != nil {
+		return nil, pl.err
+	}
+	plan := &PipelinePlan{pl: pl, temps: make(map[string]int)}
+
+	// Levels of the last stages that wrote and read each buffer
+	lastWrite := make(map[string]int)
+	lastRead := make(map[string]int)
+	// First and last levels that use each intermediate
+	first := make(map[string]int)
+	last := make(map[string]int)
+	var intermediates []string
+	for i, s := range pl.stages {
+		level := 0
+		for j, name := range s.args {
+			if w, ok := lastWrite[name]; ok && w+1 > level {
+				level = w + 1
+			}
+			if r, ok := lastRead[name]; ok && isWrite(s, j) && r+1 > level {
+				level = r + 1
+			}
+		}
+		for j, name := range s.args {
+			if isWrite(s, j) && pl.inputs[name] {
+				return nil, errors.Errorf("%v stage %v writes input %v",
+					s.op, i, name)
+			}
+			_, written := lastWrite[name]
+			if isRead(s, j) && !written && !pl.inputs[name] &&
+				!pl.outputs[name] {
+				return nil, errors.Errorf("%v stage %v reads %v, which "+
+					"isn't an input, output or earlier result", s.op, i, name)
+			}
+		}
+		for j, name := range s.args {
+			if isRead(s, j) {
+				if r, ok := lastRead[name]; !ok || level > r {
+					lastRead[name] = level
+				}
+			}
+			if isWrite(s, j) {
+				lastWrite[name] = level
+			}
+			if pl.inputs[name] || pl.outputs[name] {
+				continue
+			}
+			if _, ok := first[name]; !ok {
+				first[name] = level
+				intermediates = append(intermediates, name)
+			}
+			if level > last[name] {
+				last[name] = level
+			}
+		}
+		for len(plan.levels) <= level {
+			plan.levels = append(plan.levels, nil)
+		}
+		plan.levels[level] = append(plan.levels[level], s)
+	}
+
+	// Intermediates that aren't used by the same levels share a buffer. A
+	// buffer is free once every level that used it has passed.
+	var free []int
+	owner := make(map[int]string)
+	for level := range plan.levels {
+		for buffer, name := range owner {
+			if last[name] < level {
+				free = append(free, buffer)
+				delete(owner, buffer)
+			}
+		}
+		for _, name := range intermediates {
+			if first[name] != level {
+				continue
+			}
+			var buffer int
+			if len(free) > 0 {
+				buffer = free[len(free)-1]
+				free = free[:len(free)-1]
+			} else {
+				buffer = plan.numTemps
+				plan.numTemps++
+			}
+			plan.temps[name] = buffer
+			owner[buffer] = name
+		}
+	}
+	return plan, nil
+}
+
+// isWrite is true if the stage writes its j'th argument
+func isWrite(s *pipelineStage, j int) bool {
+	for _, w := range s.writes {
+		if w == j {
+			return true
+		}
+	}
+	return false
+}
+
+// isRead is true if the stage reads its j'th argument
+func isRead(s *pipelineStage, j int) bool {
+	return s.inPlace || !isWrite(s, j)
+}
+
+// NumLevels returns how many levels of stages have to run one after another
+func (plan *PipelinePlan) NumLevels() int {
+	return len(plan.levels)
+}
+
+// NumIntermediateBuffers returns how many buffers the executor allocates
+// for intermediates
+func (plan *PipelinePlan) NumIntermediateBuffers() int {
+	return plan.numTemps
+}
+
+// PipelineExecutor runs pipeline plans, on a stream pool or on the CPU
+type PipelineExecutor struct {
+	p   *StreamPool
+	cpu bool
+}
+
+// NewPipelineExecutor makes an executor that runs the ops on p's streams,
+// with a partition of the slots for each stream
+func NewPipelineExecutor(p *StreamPool) *PipelineExecutor {
+	return &PipelineExecutor{p: p}
+}
+
+// NewCPUPipelineExecutor makes an executor that runs CPUOps, with a
+// partition of the slots for each CPU
+// It gives the same results as the stream executor, in either build.
+func NewCPUPipelineExecutor() *PipelineExecutor {
+	return &PipelineExecutor{cpu: true}
+}
+
+// numPartitions returns how many partitions to split numSlots slots into
+func (e *PipelineExecutor) numPartitions(numSlots uint32) uint32 {
+	n := uint32(1)
+	if e.cpu {
+		n = uint32(runtime.NumCPU())
+	} else if streams := e.p.NumStreams(); streams > 1 {
+		n = uint32(streams)
+	}
+	if n > numSlots {
+		n = numSlots
+	}
+	return n
+}
+
+// Run runs the plan on buffers, which has every input and output by name
+// If a stage fails, no later levels are started, and its error is returned.
+func (e *PipelineExecutor) Run(plan *PipelinePlan,
+	buffers map[string]Operands) error {
+	pl := plan.pl
+	for name := range buffers {
+		if !pl.inputs[name] && !pl.outputs[name] {
+			return errors.Errorf("%v isn't an input or output", name)
+		}
+	}
+	for _, names := range []map[string]bool{pl.inputs, pl.outputs} {
+		for name := range names {
+			b, ok := buffers[name]
+			if !ok {
+				return errors.Errorf("%v is missing", name)
+			}
+			if b.Len() != int(pl.numSlots) {
+				return errors.Errorf("%v has %v slots, but the pipeline "+
+					"has %v", name, b.Len(), pl.numSlots)
+			}
+		}
+	}
+	var mux sync.Mutex
+	var firstErr error
+	failed := func() bool {
+		mux.Lock()
+		defer mux.Unlock()
+		return firstErr != nil
+	}
+
+	var wg sync.WaitGroup
+	numPartitions := uint64(e.numPartitions(pl.numSlots))
+	for t := uint64(0); t < numPartitions; t++ {
+		// 64 bits so the multiplication can't overflow
+		part := pipelinePartition{
+			plan:    plan,
+			buffers: buffers,
+			begin:   uint32(uint64(pl.numSlots) * t / numPartitions),
+			end:     uint32(uint64(pl.numSlots) * (t + 1) / numPartitions),
+			failed:  failed,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if e.cpu {
+				err = part.runOps(CPUOps, nil)
+			} else {
+				err = e.runStreamPartition(part)
+			}
+			if err != nil {
+				mux.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mux.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// pipelinePartition is the slots [begin, end) of a run
+type pipelinePartition struct {
+	plan       *PipelinePlan
+	buffers    map[string]Operands
+	begin, end uint32
+	// True once any partition has failed, so the rest can stop
+	failed func() bool
+}
+
+// operands returns the partition's slots of a buffer that was passed to Run,
+// or nil for intermediates
+func (part pipelinePartition) operands(name string) Operands {
+	b, ok := part.buffers[name]
+	if !ok {
+		return nil
+	}
+	return SubRange(b, part.begin, part.end)
+}
+
+// stageError says which stage failed on which slots
+func (part pipelinePartition) stageError(s *pipelineStage, err error) error {
+	return errors.Wrapf(err, "%v stage failed on slots [%v, %v)", s.op,
+		part.begin, part.end)
+}
+
+// runOps runs the partition with ops, keeping intermediates as cyclic ints
+// Stages in the same level run at the same time.
+func (part pipelinePartition) runOps(ops Ops, p *StreamPool) error {
+	g := part.plan.pl.g
+	numSlots := part.end - part.begin
+	temps := make([]Operands, part.plan.numTemps)
+	for i := range temps {
+		temps[i] = g.NewIntBuffer(numSlots, g.NewInt(1))
+	}
+	for _, level := range part.plan.levels {
+		if part.failed() {
+			return nil
+		}
+		errs := make([]error, len(level))
+		var wg sync.WaitGroup
+		for i, s := range level {
+			args := make([]Operands, len(s.args))
+			for j, name := range s.args {
+				args[j] = part.operands(name)
+				if args[j] == nil {
+					args[j] = temps[part.plan.temps[name]]
+				}
+			}
+			wg.Add(1)
+			go func(i int, s *pipelineStage) {
+				defer wg.Done()
+				errs[i] = s.run(ops, p, g, s.publicCypherKey, args)
+			}(i, s)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				return part.stageError(level[i], err)
+			}
+		}
+	}
+	return nil
+}
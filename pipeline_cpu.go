@@ -0,0 +1,16 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+// runStreamPartition runs the partition with the op variables, which run on
+// the CPU in this build
+func (e *PipelineExecutor) runStreamPartition(part pipelinePartition) error {
+	return part.runOps(InstalledOps(), e.p)
+}
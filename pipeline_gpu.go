@@ -0,0 +1,218 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+/*
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#include <powm_odd_export.h>
+*/
+import "C"
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// pipeline_gpu.go runs a partition of a pipeline on one stream. Each stage
+// launches its kernel directly, instead of calling its op, so the stream is
+// only taken once, and intermediates are copied between the kernels' outputs
+// and inputs as words.
+
+// pipelineKernel describes how a stage's arguments map to its kernel's
+// inputs and outputs
+type pipelineKernel struct {
+	kernel C.enum_kernel
+	// Indexes of the stage's args in the order that the kernel takes them
+	inputs  []int
+	outputs []int
+	// Exponents and private keys are often secret, so they're zeroed in the
+	// stream's pinned memory after each launch
+	secret bool
+	// Private keys are secret whatever they're used for, so they're wiped
+	// from device memory once the partition is done, as ElGamalChunk does.
+	// Other kernels' inputs are wiped if the pool has secret inputs.
+	alwaysWipe bool
+}
+
+var pipelineKernels = map[string]pipelineKernel{
+	ExpChunk.GetName(): {kernel: kernelPowmOdd, inputs: []int{0, 1},
+		outputs: []int{2}, secret: true},
+	// The kernel takes the private key first
+	ElGamalChunk.GetName(): {kernel: kernelElgamal,
+		inputs: []int{1, 0, 2, 3}, outputs: []int{2, 3}, secret: true,
+		alwaysWipe: true},
+	RevealChunk.GetName(): {kernel: kernelReveal, inputs: []int{0},
+		outputs: []int{1}},
+	Mul2Chunk.GetName(): {kernel: kernelMul2, inputs: []int{0, 1},
+		outputs: []int{2}},
+	Mul3Chunk.GetName(): {kernel: kernelMul3, inputs: []int{0, 1, 2},
+		outputs: []int{3}},
+}
+
+// constants returns the kernel's constants, in the order that it takes them
+func (pk pipelineKernel) constants(g *cyclic.Group,
+	publicCypherKey *cyclic.Int) []large.Bits {
+	switch pk.kernel {
+	case kernelElgamal:
+		return []large.Bits{g.GetG().Bits(), g.GetP().Bits(),
+			publicCypherKey.Bits()}
+	case kernelReveal:
+		return []large.Bits{g.GetP().Bits(), publicCypherKey.Bits()}
+	default:
+		return []large.Bits{g.GetP().Bits()}
+	}
+}
+
+// runStreamPartition runs the partition's stages on one stream, with the
+// intermediates kept as words
+func (e *PipelineExecutor) runStreamPartition(part pipelinePartition) error {
+	g := part.plan.pl.g
+	env := chooseEnv(g)
+	numSlots := int(part.end - part.begin)
+	memSize := 0
+	for _, level := range part.plan.levels {
+		for _, s := range level {
+			size := env.streamSizeContaining(numSlots,
+				int(pipelineKernels[s.op].kernel))
+			if size > memSize {
+				memSize = size
+			}
+		}
+	}
+	stream, err := e.p.TakeStreamFitting(memSize)
+	if err != nil {
+		return err
+	}
+	defer e.p.ReturnStream(stream)
+
+	temps := make([]large.Bits, part.plan.numTemps)
+	for i := range temps {
+		temps[i] = make(large.Bits, numSlots*env.getWordLen())
+	}
+	// Intermediates can be derived from secrets
+	defer func() {
+		for _, words := range temps {
+			for i := range words {
+				words[i] = 0
+			}
+		}
+	}()
+
+	// Constants of the last stage that ran each kernel to wipe, in case the
+	// wipe launches it again
+	wipeKernels := make(map[C.enum_kernel][]large.Bits)
+	err = func() error {
+		for _, level := range part.plan.levels {
+			if part.failed() {
+				return nil
+			}
+			for _, s := range level {
+				pk := pipelineKernels[s.op]
+				if pk.alwaysWipe || e.p.SecretInputs() {
+					wipeKernels[pk.kernel] = pk.constants(g,
+						s.publicCypherKey)
+				}
+				err := runStreamStage(&stream, env, part, temps, s, pk)
+				if err != nil {
+					e.p.markFailed(stream, err)
+					return part.stageError(s, err)
+				}
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+	for kernel, constants := range wipeKernels {
+		err = e.p.wipeInputs(env, g, stream, kernel, numSlots, constants...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStreamStage launches a stage's kernel on as many slots of the
+// partition at a time as the stream fits
+// Every input of a launch is copied to the stream before its outputs are
+// copied back, so a stage can write the buffers that it reads.
+func runStreamStage(stream *Stream, env gpumathsEnv, part pipelinePartition,
+	temps []large.Bits, s *pipelineStage, pk pipelineKernel) error {
+	g := part.plan.pl.g
+	wordLen := env.getWordLen()
+	numSlots := part.end - part.begin
+	maxSlots := uint32(env.maxSlots(len(stream.cpuData), pk.kernel))
+	if maxSlots == 0 {
+		return errors.Errorf("a stream of %v bytes can't fit one slot",
+			len(stream.cpuData))
+	}
+	// Each arg is either the caller's operands or an intermediate's words
+	operands := make([]Operands, len(s.args))
+	words := make([]large.Bits, len(s.args))
+	for j, name := range s.args {
+		operands[j] = part.operands(name)
+		if operands[j] == nil {
+			words[j] = temps[part.plan.temps[name]]
+		}
+	}
+	slotWords := func(j int, slot uint32) large.Bits {
+		return words[j][int(slot)*wordLen : int(slot+1)*wordLen]
+	}
+
+	for begin := uint32(0); begin < numSlots; begin += maxSlots {
+		end := begin + maxSlots
+		if end > numSlots {
+			end = numSlots
+		}
+		n := int(end - begin)
+		stream.putConstants(env, pk.kernel,
+			pk.constants(g, s.publicCypherKey)...)
+		inputs := stream.getCpuInputsWords(env, pk.kernel, n)
+		offset := 0
+		for slot := begin; slot < end; slot++ {
+			for _, j := range pk.inputs {
+				dst := inputs[offset : offset+wordLen]
+				if words[j] != nil {
+					copy(dst, slotWords(j, slot))
+				} else {
+					putBits(dst, operands[j].Get(slot).Bits(), wordLen)
+				}
+				offset += wordLen
+			}
+		}
+
+		err := env.enqueue(*stream, pk.kernel, n)
+		if err == nil {
+			err = get(*stream)
+		}
+		if pk.secret {
+			stream.zeroCpuInputsWords(env, pk.kernel, n)
+		}
+		if err != nil {
+			return err
+		}
+
+		outputs := stream.getCpuOutputsWords(env, pk.kernel, n)
+		offset = 0
+		for slot := begin; slot < end; slot++ {
+			for _, j := range pk.outputs {
+				src := outputs[offset : offset+wordLen]
+				if words[j] != nil {
+					copy(slotWords(j, slot), src)
+				} else {
+					g.OverwriteBits(operands[j].Get(slot), src)
+				}
+				offset += wordLen
+			}
+		}
+	}
+	return nil
+}
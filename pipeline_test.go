@@ -0,0 +1,179 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"testing"
+)
+
+// Declares a pipeline with every kind of stage, where the Mul2 and Reveal
+// stages don't depend on each other
+func makeTestPipeline(g *cyclic.Group, n uint32) *Pipeline {
+	key := g.NewInt(65537)
+	return NewPipeline(g, n).
+		Input("keys", "privateKeys", "x", "y").
+		Output("ecrKeys", "cyphers", "result", "revealed").
+		ElGamal("keys", "privateKeys", key, "ecrKeys", "cyphers").
+		Mul2("ecrKeys", "x", "product").
+		Reveal(key, "cyphers", "revealed").
+		Exp("product", "y", "power").
+		Mul3("power", "x", "y", "result")
+}
+
+// Makes the pipeline's buffers, with the same contents for the same seed
+func makeTestPipelineBuffers(g *cyclic.Group, n uint32) map[string]Operands {
+	return map[string]Operands{
+		"keys":        randomTestBuffer(g, n, 1),
+		"privateKeys": randomTestBuffer(g, n, 2),
+		"x":           randomTestBuffer(g, n, 3),
+		"y":           randomTestBuffer(g, n, 4),
+		"ecrKeys":     randomTestBuffer(g, n, 5),
+		"cyphers":     randomTestBuffer(g, n, 6),
+		"result":      g.NewIntBuffer(n, g.NewInt(1)),
+		"revealed":    g.NewIntBuffer(n, g.NewInt(1)),
+	}
+}
+
+// The stream and CPU executors should give the same results as running the
+// ops one at a time
+func TestPipelineExecutor_Run(t *testing.T) {
+	const n = 100
+	g := makeTestGroup2048()
+	streams, err := createStreams(2, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newStreamPool(streams)
+	defer p.Destroy()
+
+	plan, err := makeTestPipeline(g, n).Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.NumLevels() != 4 {
+		t.Errorf("Expected 4 levels, got %v", plan.NumLevels())
+	}
+
+	expected := makeTestPipelineBuffers(g, n)
+	key := g.NewInt(65537)
+	product := g.NewIntBuffer(n, g.NewInt(1))
+	err = ElGamalChunk(p, g, expected["keys"], expected["privateKeys"], key,
+		expected["ecrKeys"], expected["cyphers"])
+	if err == nil {
+		err = Mul2Chunk(p, g, expected["ecrKeys"], expected["x"], product)
+	}
+	if err == nil {
+		err = RevealChunk(p, g, key, expected["cyphers"],
+			expected["revealed"])
+	}
+	if err == nil {
+		_, err = ExpChunk(p, g, product.DeepCopy(), expected["y"], product)
+	}
+	if err == nil {
+		err = Mul3Chunk(p, g, product, expected["x"], expected["y"],
+			expected["result"])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executors := map[string]*PipelineExecutor{
+		"stream": NewPipelineExecutor(p),
+		"cpu":    NewCPUPipelineExecutor(),
+	}
+	for name, e := range executors {
+		buffers := makeTestPipelineBuffers(g, n)
+		err = e.Run(plan, buffers)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		for buffer, values := range expected {
+			for i := uint32(0); i < n; i++ {
+				if values.Get(i).Cmp(buffers[buffer].Get(i)) != 0 {
+					t.Errorf("%v: %v differs in slot %v", name, buffer, i)
+					break
+				}
+			}
+		}
+	}
+}
+
+// Intermediates whose lifetimes don't overlap should share buffers
+func TestPipeline_Compile_SharedIntermediates(t *testing.T) {
+	g := makeTestGroup2048()
+	plan, err := NewPipeline(g, 4).
+		Input("x", "y").
+		Output("result").
+		Mul2("x", "y", "a").
+		Mul2("a", "y", "b").
+		Mul2("b", "y", "c").
+		Mul2("c", "y", "result").
+		Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.NumLevels() != 4 {
+		t.Errorf("Expected 4 levels, got %v", plan.NumLevels())
+	}
+	if plan.NumIntermediateBuffers() != 2 {
+		t.Errorf("Expected 2 intermediate buffers, got %v",
+			plan.NumIntermediateBuffers())
+	}
+}
+
+// Pipelines that write inputs or read unwritten intermediates shouldn't
+// compile
+func TestPipeline_Compile_Errors(t *testing.T) {
+	g := makeTestGroup2048()
+	pipelines := map[string]*Pipeline{
+		"writes an input": NewPipeline(g, 4).Input("x").
+			Mul2("x", "x", "x"),
+		"reads an unwritten intermediate": NewPipeline(g, 4).Input("x").
+			Output("result").Mul2("x", "t", "result"),
+		"updates an unwritten intermediate": NewPipeline(g, 4).
+			Input("keys").ElGamal("keys", "keys", g.NewInt(3), "e", "c"),
+		"has an input that's an output": NewPipeline(g, 4).Input("x").
+			Output("x"),
+		"has an unnamed buffer": NewPipeline(g, 4).Input("x").
+			Mul2("x", "", "x"),
+	}
+	for name, pl := range pipelines {
+		_, err := pl.Compile()
+		if err == nil {
+			t.Errorf("Pipeline that %v compiled", name)
+		}
+	}
+}
+
+// Run should only take the plan's inputs and outputs, at its length
+func TestPipelineExecutor_Run_Errors(t *testing.T) {
+	g := makeTestGroup2048()
+	plan, err := NewPipeline(g, 4).
+		Input("x").
+		Output("result").
+		Mul2("x", "x", "result").
+		Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewCPUPipelineExecutor()
+	runs := map[string]map[string]Operands{
+		"missing output": {"x": randomTestBuffer(g, 4, 1)},
+		"short input": {"x": randomTestBuffer(g, 3, 1),
+			"result": randomTestBuffer(g, 4, 1)},
+		"unknown buffer": {"x": randomTestBuffer(g, 4, 1),
+			"result": randomTestBuffer(g, 4, 1),
+			"y":      randomTestBuffer(g, 4, 1)},
+	}
+	for name, buffers := range runs {
+		if e.Run(plan, buffers) == nil {
+			t.Errorf("Run with a %v should fail", name)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"bufio"
+	"encoding/gob"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// record.go captures the ops' kernel invocations, so a wrong result can be
+// reproduced with the exact inputs that went into it. With the gpu tag,
+// every kernel launch on a stream taken from a recording pool is recorded
+// (see record_gpu.go). The CPU ops record each call instead. See replay.go
+// for running recordings again.
+
+// Identifies recording files, and changes if the format does
+const recordingHeader = "gpumaths recording v1"
+
+// Invocation is one recorded kernel launch, or one call of a CPU op
+// Operands are packed as NumSlots big-endian ints, each padded to the
+// length of P.
+type Invocation struct {
+	// Position in the recording, starting at 0
+	Index int
+	// Op that does the same thing as the kernel, e.g. "ExpChunk" for
+	// powm_odd, which replays it
+	Op string
+	// Kernel name and environment size, or empty and 0 on the CPU
+	Kernel string
+	Bits   int
+	// The group, in big-endian bytes. Kernel launches only have G if the
+	// kernel takes the generator, which only elgamal does.
+	P []byte
+	G []byte
+	// Values that apply to every slot, like ElGamal's publicCypherKey. With
+	// the group, these are the kernel's constants.
+	Scalars  [][]byte
+	NumSlots uint32
+	// Inputs and outputs in the op's order. See OperandNames.
+	Inputs  [][]byte
+	Outputs [][]byte
+	// Inputs that were left out of the recording. Their entries in Inputs
+	// are nil.
+	Redacted []string
+	Start    time.Time
+	Duration time.Duration
+	// The error that the invocation failed with. Failed invocations don't
+	// have outputs.
+	Err string
+}
+
+// OpOperands names an op's operands, in the order they're recorded
+// Exponents names the inputs that are exponents, which can be 0 as well as
+// any value in the group.
+type OpOperands struct {
+	Inputs    []string
+	Exponents []string
+	Scalars   []string
+	Outputs   []string
+}
+
+// isExponent returns true if the named input is an exponent
+func (o OpOperands) isExponent(name string) bool {
+	for _, e := range o.Exponents {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OperandNames has the operand names of every op that can be recorded
+// The CPU ops pass their names to beginInvocation, so this uses the names
+// instead of the ops' GetName, which would be an initialization cycle.
+var OperandNames = map[string]OpOperands{
+	"ExpChunk": {
+		Inputs:    []string{"x", "y"},
+		Exponents: []string{"y"},
+		Outputs:   []string{"z"},
+	},
+	"ElGamalChunk": {
+		Inputs:    []string{"key", "privateKey", "ecrKey", "cypher"},
+		Exponents: []string{"privateKey"},
+		Scalars:   []string{"publicCypherKey"},
+		Outputs:   []string{"ecrKey", "cypher"},
+	},
+	"RevealChunk": {
+		Inputs:  []string{"cypher"},
+		Scalars: []string{"publicCypherKey"},
+		Outputs: []string{"result"},
+	},
+	"Mul2Chunk": {
+		Inputs:  []string{"x", "y"},
+		Outputs: []string{"result"},
+	},
+	"Mul3Chunk": {
+		Inputs:  []string{"x", "y", "z"},
+		Outputs: []string{"result"},
+	},
+}
+
+// RecordOptions controls what a Recorder writes
+// ElGamal's private keys are left out unless IncludePrivateKeys is set.
+type RecordOptions struct {
+	// Called for each input of each invocation. Inputs that it returns true
+	// for are left out of the recording, and invocations without all of
+	// their inputs can't be replayed.
+	Redact func(op, input string) bool
+	// Records ElGamal's private keys too, so its invocations can be
+	// replayed. Only set this for recordings that stay on the node.
+	IncludePrivateKeys bool
+}
+
+// RedactElGamalPrivateKeys leaves ElGamal's private keys out of recordings
+func RedactElGamalPrivateKeys(op, input string) bool {
+	return op == "ElGamalChunk" && input == "privateKey"
+}
+
+// Recorder writes the invocations of the ops that use a pool
+// Set it with StreamPool.SetRecorder. Recording copies every input and
+// output, so it slows the ops down.
+type Recorder struct {
+	mux     sync.Mutex
+	w       *bufio.Writer
+	enc     *gob.Encoder
+	closer  io.Closer
+	options RecordOptions
+	count   int
+	// The first write error. Nothing more is written after one.
+	err    error
+	closed bool
+}
+
+// NewRecorder makes a recorder that writes to w
+func NewRecorder(w io.Writer, options RecordOptions) (*Recorder, error) {
+	bw := bufio.NewWriter(w)
+	r := &Recorder{
+		w:       bw,
+		enc:     gob.NewEncoder(bw),
+		options: options,
+	}
+	err := r.enc.Encode(recordingHeader)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't start recording")
+	}
+	return r, nil
+}
+
+// CreateRecording makes a recorder that writes to a new file at path
+func CreateRecording(path string, options RecordOptions) (*Recorder,
+	error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create recording")
+	}
+	r, err := NewRecorder(file, options)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.closer = file
+	return r, nil
+}
+
+// Close flushes the recording, and closes its file if CreateRecording made
+// it. It returns the first error that happened while recording.
+func (r *Recorder) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.closed {
+		return r.err
+	}
+	r.closed = true
+	err := r.w.Flush()
+	if r.err == nil {
+		r.err = err
+	}
+	if r.closer != nil {
+		err = r.closer.Close()
+		if r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
+
+// Count returns the number of invocations that have been recorded
+func (r *Recorder) Count() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.count
+}
+
+// Write adds an invocation to the recording, leaving out the inputs that
+// the recorder redacts
+// The ops' invocations are written automatically. This is for copying
+// invocations from other recordings.
+func (r *Recorder) Write(inv *Invocation) error {
+	names := OperandNames[inv.Op]
+	copied := *inv
+	copied.Inputs = append([][]byte(nil), inv.Inputs...)
+	copied.Redacted = append([]string(nil), inv.Redacted...)
+	for i := range copied.Inputs {
+		if i < len(names.Inputs) && copied.Inputs[i] != nil &&
+			r.redacts(inv.Op, names.Inputs[i]) {
+			copied.Inputs[i] = nil
+			copied.Redacted = append(copied.Redacted, names.Inputs[i])
+		}
+	}
+	return r.write(&copied)
+}
+
+func (r *Recorder) redacts(op, input string) bool {
+	if !r.options.IncludePrivateKeys && RedactElGamalPrivateKeys(op, input) {
+		return true
+	}
+	return r.options.Redact != nil && r.options.Redact(op, input)
+}
+
+func (r *Recorder) write(inv *Invocation) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.closed {
+		return errors.New("recording is closed")
+	}
+	if r.err != nil {
+		return r.err
+	}
+	inv.Index = r.count
+	r.err = r.enc.Encode(inv)
+	if r.err != nil {
+		jww.ERROR.Printf("Recording stopped: %v", r.err)
+		return r.err
+	}
+	r.count++
+	return nil
+}
+
+// SetRecorder starts recording the invocations of ops that use the pool,
+// or stops if r is nil. The pool doesn't close the recorder.
+func (sm *StreamPool) SetRecorder(r *Recorder) {
+	if !sm.isUsable() {
+		return
+	}
+	sm.mux.Lock()
+	sm.recorder = r
+	sm.mux.Unlock()
+}
+
+// pendingInvocation is an invocation that's being recorded
+type pendingInvocation struct {
+	recorder *Recorder
+	inv      *Invocation
+	g        *cyclic.Group
+}
+
+// beginInvocation copies a CPU op call's inputs, if the pool is recording
+// The inputs have to be copied before the op runs, because the outputs
+// can overwrite them. It's safe to call on a nil pool.
+func (sm *StreamPool) beginInvocation(op, kernel string, bits int,
+	g *cyclic.Group, scalars []*cyclic.Int,
+	inputs ...Operands) *pendingInvocation {
+	if sm == nil || sm.streamPoolState == nil {
+		return nil
+	}
+	sm.mux.Lock()
+	r := sm.recorder
+	sm.mux.Unlock()
+	if r == nil {
+		return nil
+	}
+
+	names := OperandNames[op]
+	inv := &Invocation{
+		Op:       op,
+		Kernel:   kernel,
+		Bits:     bits,
+		P:        g.GetPBytes(),
+		G:        g.GetG().Bytes(),
+		Scalars:  make([][]byte, len(scalars)),
+		NumSlots: uint32(inputs[0].Len()),
+		Inputs:   make([][]byte, len(inputs)),
+	}
+	for i := range scalars {
+		inv.Scalars[i] = scalars[i].Bytes()
+	}
+	for i := range inputs {
+		if r.redacts(op, names.Inputs[i]) {
+			inv.Redacted = append(inv.Redacted, names.Inputs[i])
+			continue
+		}
+		inv.Inputs[i] = packInts(g, inputs[i])
+	}
+	inv.Start = time.Now()
+	return &pendingInvocation{recorder: r, inv: inv, g: g}
+}
+
+// end records the invocation's outputs and writes it. It's safe to call
+// on nil.
+func (pi *pendingInvocation) end(err error, outputs ...Operands) {
+	if pi == nil {
+		return
+	}
+	pi.inv.Duration = time.Since(pi.inv.Start)
+	if err != nil {
+		pi.inv.Err = err.Error()
+	} else {
+		pi.inv.Outputs = make([][]byte, len(outputs))
+		for i := range outputs {
+			pi.inv.Outputs[i] = packInts(pi.g, outputs[i])
+		}
+	}
+	// Recording errors don't fail the op. Close returns them.
+	pi.recorder.write(pi.inv)
+}
+
+// RecordingReader reads the invocations in a recording
+type RecordingReader struct {
+	dec *gob.Decoder
+}
+
+// NewRecordingReader checks that r has a recording, and gets ready to read
+// its invocations
+func NewRecordingReader(r io.Reader) (*RecordingReader, error) {
+	dec := gob.NewDecoder(bufio.NewReader(r))
+	var header string
+	err := dec.Decode(&header)
+	if err != nil || header != recordingHeader {
+		return nil, errors.New("not a gpumaths recording, or one from an " +
+			"incompatible version")
+	}
+	return &RecordingReader{dec: dec}, nil
+}
+
+// Next returns the next invocation, or io.EOF at the end of the recording
+func (rr *RecordingReader) Next() (*Invocation, error) {
+	inv := new(Invocation)
+	err := rr.dec.Decode(inv)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(err, "couldn't read invocation")
+	}
+	return inv, nil
+}
+
+// packInts concatenates o's ints, padded to the length of g's prime
+func packInts(g *cyclic.Group, o Operands) []byte {
+	width := len(g.GetPBytes())
+	result := make([]byte, 0, o.Len()*width)
+	for i := uint32(0); i < uint32(o.Len()); i++ {
+		result = append(result, o.Get(i).LeftpadBytes(uint64(width))...)
+	}
+	return result
+}
+
+// unpackInts makes a buffer from ints packed by packInts
+// Exponents can also be 0, so they only have to be less than the prime.
+func unpackInts(g *cyclic.Group, data []byte, numSlots uint32,
+	exponent bool) (*cyclic.IntBuffer, error) {
+	width := len(g.GetPBytes())
+	if len(data) != int(numSlots)*width {
+		return nil, errors.Errorf("packed operand has %v bytes, expected %v",
+			len(data), int(numSlots)*width)
+	}
+	result := g.NewIntBuffer(numSlots, g.NewInt(1))
+	for i := uint32(0); i < numSlots; i++ {
+		start := int(i) * width
+		b := data[start : start+width]
+		if exponent {
+			if large.NewIntFromBytes(b).Cmp(g.GetP()) >= 0 {
+				return nil, errors.Errorf("slot %v isn't less than the "+
+					"prime", i)
+			}
+		} else if !g.BytesInside(b) {
+			return nil, errors.Errorf("slot %v is outside the group", i)
+		}
+		g.SetBytes(result.Get(i), b)
+	}
+	return result, nil
+}
@@ -0,0 +1,155 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+/*
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#include <powm_odd_export.h>
+*/
+import "C"
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"time"
+)
+
+// record_gpu.go records kernel launches as they're enqueued, so every op
+// that launches a kernel is recorded, including the ones that build on other
+// kernels, like PermuteMul2Chunk and ReduceProductChunk. Each launch is
+// recorded as the op that does the same thing as the kernel, so it can be
+// replayed with that op.
+
+// kernelRecording describes how a kernel's buffers map to its op's operands
+type kernelRecording struct {
+	op string
+	// Index of the prime in the kernel's constants, and of the generator,
+	// or -1 if the kernel doesn't take it
+	p, g int
+	// Indexes of the op's scalars in the kernel's constants
+	scalars []int
+	// Index of each of the op's inputs in the kernel's per-slot inputs
+	inputs []int
+}
+
+var kernelRecordings = map[C.enum_kernel]kernelRecording{
+	kernelPowmOdd: {op: "ExpChunk", g: -1, inputs: []int{0, 1}},
+	// The kernel takes the private key first
+	kernelElgamal: {op: "ElGamalChunk", p: 1, g: 0, scalars: []int{2},
+		inputs: []int{1, 0, 2, 3}},
+	kernelReveal: {op: "RevealChunk", g: -1, scalars: []int{1},
+		inputs: []int{0}},
+	kernelMul2: {op: "Mul2Chunk", g: -1, inputs: []int{0, 1}},
+	kernelMul3: {op: "Mul3Chunk", g: -1, inputs: []int{0, 1, 2}},
+}
+
+// kernelLaunch is a launch that's being recorded, until its results are
+// downloaded
+type kernelLaunch struct {
+	recorder *Recorder
+	inv      *Invocation
+	env      gpumathsEnv
+	kernel   C.enum_kernel
+	width    int
+}
+
+// beginLaunch copies a launch's constants and inputs from the stream, if
+// the pool that it was taken from is recording
+// Launches that wipe secret inputs aren't recorded.
+func (s Stream) beginLaunch(env gpumathsEnv, kernel C.enum_kernel,
+	numSlots int) {
+	a := s.account
+	if a == nil || a.recorder == nil || a.wiping {
+		return
+	}
+	kr := kernelRecordings[kernel]
+	wordLen := env.getWordLen()
+	constants := splitWords(s.getCpuConstantsWords(env, kernel), wordLen)
+	p := large.NewIntFromBits(constants[kr.p])
+	width := (p.BitLen() + 7) / 8
+	inv := &Invocation{
+		Op:       kr.op,
+		Kernel:   kernelNames[kernel],
+		Bits:     env.getBitLen(),
+		P:        p.LeftpadBytes(uint64(width)),
+		Scalars:  make([][]byte, len(kr.scalars)),
+		NumSlots: uint32(numSlots),
+		Inputs:   make([][]byte, len(kr.inputs)),
+	}
+	if kr.g >= 0 {
+		inv.G = large.NewIntFromBits(constants[kr.g]).Bytes()
+	}
+	for i, c := range kr.scalars {
+		inv.Scalars[i] = large.NewIntFromBits(constants[c]).Bytes()
+	}
+	names := OperandNames[kr.op]
+	inputs := s.getCpuInputsWords(env, kernel, numSlots)
+	for i, index := range kr.inputs {
+		if a.recorder.redacts(kr.op, names.Inputs[i]) {
+			inv.Redacted = append(inv.Redacted, names.Inputs[i])
+			continue
+		}
+		inv.Inputs[i] = packWords(inputs, wordLen, len(kr.inputs), index,
+			numSlots, width)
+	}
+	inv.Start = time.Now()
+	a.launch = &kernelLaunch{
+		recorder: a.recorder,
+		inv:      inv,
+		env:      env,
+		kernel:   kernel,
+		width:    width,
+	}
+}
+
+// endLaunch copies the recorded launch's outputs from the stream and writes
+// it, or writes its error
+func (s Stream) endLaunch(err error) {
+	if s.account == nil || s.account.launch == nil {
+		return
+	}
+	l := s.account.launch
+	s.account.launch = nil
+	l.inv.Duration = time.Since(l.inv.Start)
+	if err != nil {
+		l.inv.Err = err.Error()
+	} else {
+		numSlots := int(l.inv.NumSlots)
+		numOutputs := len(OperandNames[l.inv.Op].Outputs)
+		outputs := s.getCpuOutputsWords(l.env, l.kernel, numSlots)
+		l.inv.Outputs = make([][]byte, numOutputs)
+		for i := range l.inv.Outputs {
+			l.inv.Outputs[i] = packWords(outputs, l.env.getWordLen(),
+				numOutputs, i, numSlots, l.width)
+		}
+	}
+	// Recording errors don't fail the launch. Close returns them.
+	l.recorder.write(l.inv)
+}
+
+// splitWords splits words into bignums of wordLen words
+func splitWords(words large.Bits, wordLen int) []large.Bits {
+	result := make([]large.Bits, 0, len(words)/wordLen)
+	for i := 0; i+wordLen <= len(words); i += wordLen {
+		result = append(result, words[i:i+wordLen])
+	}
+	return result
+}
+
+// packWords packs operand index of every slot in words, where each slot has
+// perSlot bignums of wordLen words, as big-endian ints of width bytes
+func packWords(words large.Bits, wordLen, perSlot, index, numSlots,
+	width int) []byte {
+	result := make([]byte, 0, numSlots*width)
+	for i := 0; i < numSlots; i++ {
+		start := (i*perSlot + index) * wordLen
+		v := large.NewIntFromBits(words[start : start+wordLen])
+		result = append(result, v.LeftpadBytes(uint64(width))...)
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Ops that build on other kernels should have their launches recorded as the
+// kernels' ops, and the launches should replay
+func TestRecorder_KernelLaunches(t *testing.T) {
+	const batchSize = 20
+	grp := initTestGroup()
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+	results := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+	permutation := make([]uint32, batchSize)
+	for i := range permutation {
+		permutation[i] = uint32(batchSize - 1 - i)
+	}
+
+	env := chooseEnv(grp)
+	streamPool, err := NewStreamPool(1,
+		env.streamSizeContaining(batchSize, kernelMul2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer streamPool.Destroy()
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, RecordOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamPool.SetRecorder(r)
+	err = PermuteMul2Chunk(streamPool, grp, x, y, permutation, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReduceProductChunk(streamPool, grp, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamPool.SetRecorder(nil)
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invocations := readRecording(t, buf.Bytes())
+	// The permuted product and at least one round of the reduction
+	if len(invocations) < 2 {
+		t.Fatalf("Only %v launches were recorded", len(invocations))
+	}
+	for _, inv := range invocations {
+		if inv.Op != "Mul2Chunk" || inv.Kernel != "mul2" {
+			t.Errorf("Launch was recorded as %v on %v", inv.Op, inv.Kernel)
+		}
+		result := Replay(streamPool, inv)
+		if !result.Matched() {
+			t.Errorf("Replay didn't match: %v", result)
+		}
+	}
+}
+
+// Launches that wipe secret inputs off the device shouldn't be recorded
+func TestRecorder_Wipe(t *testing.T) {
+	const batchSize = 8
+	grp := initTestGroup()
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	y := initRandomIntBuffer(grp, batchSize, 43, 0)
+	z := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+
+	env := chooseEnv(grp)
+	streamPool, err := NewStreamPool(1,
+		env.streamSizeContaining(batchSize, kernelPowmOdd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer streamPool.Destroy()
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, RecordOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamPool.SetRecorder(r)
+	_, err = ExpChunk(streamPool.WithSecretInputs(), grp, x, y, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamPool.SetRecorder(nil)
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invocations := readRecording(t, buf.Bytes())
+	if len(invocations) != 1 || invocations[0].Op != "ExpChunk" {
+		t.Errorf("Expected the exponentiation's launch alone, got %v "+
+			"launches", len(invocations))
+	}
+}
@@ -0,0 +1,296 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// Makes a pool with one stream that's big enough for the recording tests
+func newTestPool(t *testing.T) *StreamPool {
+	streams, err := createStreams(1, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newStreamPool(streams)
+	t.Cleanup(func() {
+		p.Destroy()
+	})
+	return p
+}
+
+// Runs every recordable op once
+func runRecordedOps(t *testing.T, p *StreamPool) {
+	const n = 10
+	g := makeTestGroup2048()
+	x := randomTestBuffer(g, n, 1)
+	y := randomTestBuffer(g, n, 2)
+	z := randomTestBuffer(g, n, 3)
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	key := g.NewInt(65537)
+
+	_, err := ExpChunk(p, g, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ElGamalChunk(p, g, x, y, key, z, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RevealChunk(p, g, key, x, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Mul2Chunk(p, g, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Mul3Chunk(p, g, x, y, z, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Reads every invocation in a recording
+func readRecording(t *testing.T, data []byte) []*Invocation {
+	rr, err := NewRecordingReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []*Invocation
+	for {
+		inv, err := rr.Next()
+		if err == io.EOF {
+			return result
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, inv)
+	}
+}
+
+// Replaying a recording should give the recorded outputs, and a changed
+// output should be reported for the slot it's in
+func TestRecorder_Replay(t *testing.T) {
+	p := newTestPool(t)
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, RecordOptions{IncludePrivateKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetRecorder(r)
+	runRecordedOps(t, p)
+	p.SetRecorder(nil)
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invocations := readRecording(t, buf.Bytes())
+	if len(invocations) != r.Count() {
+		t.Fatalf("Read %v invocations, but %v were recorded",
+			len(invocations), r.Count())
+	}
+	// Each op runs at least one kernel
+	seen := make(map[string]bool)
+	for i, inv := range invocations {
+		if inv.Index != i {
+			t.Errorf("Invocation %v has index %v", i, inv.Index)
+		}
+		seen[inv.Op] = true
+		result := Replay(p, inv)
+		if !result.Matched() {
+			t.Errorf("Replay didn't match: %v", result)
+		}
+	}
+	for op := range OperandNames {
+		if !seen[op] {
+			t.Errorf("%v wasn't recorded", op)
+		}
+	}
+
+	inv := invocations[len(invocations)-1]
+	inv.Outputs[0][3*len(inv.P)] ^= 1
+	result := Replay(p, inv)
+	slots := result.Mismatches["result"]
+	if len(result.Mismatches) != 1 || len(slots) != 1 || slots[0] != 3 {
+		t.Errorf("Expected a mismatch in slot 3 of result, got %v", result)
+	}
+}
+
+// Exponents and private keys of 0 should replay, but ones that aren't less
+// than the prime shouldn't
+func TestRecorder_ReplayZeroExponents(t *testing.T) {
+	const n = 4
+	p := newTestPool(t)
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, RecordOptions{IncludePrivateKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetRecorder(r)
+	g := makeTestGroup2048()
+	x := randomTestBuffer(g, n, 1)
+	y := randomTestBuffer(g, n, 2)
+	g.SetUint64(y.Get(1), 0)
+	z := randomTestBuffer(g, n, 3)
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	_, err = ExpChunk(p, g, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ElGamalChunk(p, g, x, y, g.NewInt(65537), z, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetRecorder(nil)
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for _, inv := range readRecording(t, buf.Bytes()) {
+		seen[inv.Op] = true
+		result := Replay(p, inv)
+		if !result.Matched() {
+			t.Errorf("Replay with a zero exponent didn't match: %v", result)
+		}
+		names := OperandNames[inv.Op]
+		for i, name := range names.Inputs {
+			if !names.isExponent(name) {
+				continue
+			}
+			tooBig := append([]byte{}, inv.Inputs[i]...)
+			copy(tooBig[len(inv.P):], inv.P)
+			inv.Inputs[i] = tooBig
+			if Replay(p, inv).Err == nil {
+				t.Errorf("%v: %v of p should fail to replay", inv.Op, name)
+			}
+		}
+	}
+	if !seen["ExpChunk"] || !seen["ElGamalChunk"] {
+		t.Errorf("Expected ExpChunk and ElGamalChunk, got %v", seen)
+	}
+}
+
+// Private keys shouldn't be in the recording unless they're asked for, and
+// the invocations without them should be skipped
+func TestRecorder_Redact(t *testing.T) {
+	p := newTestPool(t)
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, RecordOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetRecorder(r)
+	runRecordedOps(t, p)
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, inv := range readRecording(t, buf.Bytes()) {
+		result := Replay(p, inv)
+		if inv.Op != "ElGamalChunk" {
+			if !result.Matched() {
+				t.Errorf("Replay didn't match: %v", result)
+			}
+			continue
+		}
+		if inv.Inputs[1] != nil || len(inv.Redacted) != 1 ||
+			inv.Redacted[0] != "privateKey" {
+			t.Errorf("ElGamal private keys weren't redacted: %+v",
+				inv.Redacted)
+		}
+		if result.Skipped == "" {
+			t.Error("Invocation with redacted inputs wasn't skipped")
+		}
+	}
+}
+
+// Write should redact invocations copied from another recording
+func TestRecorder_Write(t *testing.T) {
+	g := makeTestGroup2048()
+	packed := packInts(g, randomTestBuffer(g, 2, 1))
+	inv := &Invocation{
+		Op:       "ElGamalChunk",
+		P:        g.GetPBytes(),
+		G:        g.GetG().Bytes(),
+		NumSlots: 2,
+		Inputs:   [][]byte{packed, packed, packed, packed},
+	}
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, RecordOptions{
+		Redact: RedactElGamalPrivateKeys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Write(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Inputs[1] == nil {
+		t.Error("Write changed the passed invocation")
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Write(inv)
+	if err == nil {
+		t.Error("Write should fail after Close")
+	}
+
+	invocations := readRecording(t, buf.Bytes())
+	if len(invocations) != 1 || invocations[0].Inputs[1] != nil {
+		t.Error("Copied invocation wasn't redacted")
+	}
+}
+
+// Only recordings should be read
+func TestNewRecordingReader_NotRecording(t *testing.T) {
+	_, err := NewRecordingReader(bytes.NewReader([]byte("not a recording")))
+	if err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+// Crafted recordings should fail to replay instead of panicking
+func TestReplay_OutsideGroup(t *testing.T) {
+	g := makeTestGroup2048()
+	packed := packInts(g, randomTestBuffer(g, 2, 1))
+	outside := append(packInts(g, randomTestBuffer(g, 1, 2)), g.GetPBytes()...)
+	valid := func() *Invocation {
+		return &Invocation{
+			Op:       "RevealChunk",
+			P:        g.GetPBytes(),
+			Scalars:  [][]byte{g.NewInt(65537).Bytes()},
+			NumSlots: 2,
+			Inputs:   [][]byte{packed},
+			Outputs:  [][]byte{packed},
+		}
+	}
+	tests := map[string]func(inv *Invocation){
+		"scalar":    func(inv *Invocation) { inv.Scalars[0] = g.GetPBytes() },
+		"input":     func(inv *Invocation) { inv.Inputs[0] = outside },
+		"even p":    func(inv *Invocation) { inv.P = []byte{4} },
+		"generator": func(inv *Invocation) { inv.G = g.GetPBytes() },
+	}
+	for name, change := range tests {
+		inv := valid()
+		change(inv)
+		result := Replay(nil, inv)
+		if result.Err == nil {
+			t.Errorf("%v: expected an error, got %v", name, result)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import "gitlab.com/elixxir/crypto/cyclic"
+
+// reduce.go contains the types for multiplying every element of a buffer
+// together. The GPU version in reduce_gpu.go does a tree reduction on the
+// mul2 kernel inside one stream's buffer, and the CPU version in
+// reduce_cpu.go splits the buffer between goroutines.
+
+// ReduceProductChunkPrototype returns the product of every slot of x mod p
+// The product of an empty buffer is 1
+type ReduceProductChunkPrototype func(p *StreamPool, g *cyclic.Group,
+	x Operands) (*cyclic.Int, error)
+
+// GetInputSize is how big chunk sizes should be to run the reduce operation
+func (ReduceProductChunkPrototype) GetInputSize() uint32 {
+	return 256
+}
+
+// GetName returns the name of the ReduceProductChunk operation
+func (ReduceProductChunkPrototype) GetName() string {
+	return "ReduceProductChunk"
+}
@@ -0,0 +1,34 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"sync"
+)
+
+// ReduceProductChunk multiplies all slots of x together on the CPU
+// Each goroutine folds a contiguous range, and the partial products are then
+// folded together
+var ReduceProductChunk ReduceProductChunkPrototype = func(p *StreamPool,
+	g *cyclic.Group, x Operands) (*cyclic.Int, error) {
+	result := g.NewInt(1)
+	var mux sync.Mutex
+	forEachSlotRange(uint32(x.Len()), func(begin, end uint32) {
+		partial := g.NewInt(1)
+		for i := begin; i < end; i++ {
+			g.Mul(partial, x.Get(i), partial)
+		}
+		mux.Lock()
+		g.Mul(result, partial, result)
+		mux.Unlock()
+	})
+	return result, nil
+}
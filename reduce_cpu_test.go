@@ -0,0 +1,34 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+func TestReduceProductChunk(t *testing.T) {
+	grp := makeTestGroup2048()
+	for _, batchSize := range []uint32{0, 1, 2, 7, 1000} {
+		x := grp.NewIntBuffer(batchSize, grp.NewInt(1))
+		expected := grp.NewInt(1)
+		for i := uint32(0); i < batchSize; i++ {
+			grp.SetLargeInt(x.Get(i), large.NewInt(int64(i)+2))
+			grp.Mul(expected, x.Get(i), expected)
+		}
+		result, err := ReduceProductChunk(nil, grp, x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Cmp(expected) != 0 {
+			t.Errorf("Product of %v slots didn't match serial result", batchSize)
+		}
+	}
+}
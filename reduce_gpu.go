@@ -0,0 +1,115 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// reduce_gpu.go runs the product reduction on the mul2 kernel, inside a
+// single stream's buffer. The values are packed into the stream's inputs
+// once. Mul2's inputs are slot-major, so neighbouring values are already the
+// two inputs of one slot, and each round multiplies every pair and moves the
+// products back to the front of the inputs for the next round. Only the
+// final product is converted back to a cyclic int.
+//
+// The native library uploads a kernel's inputs and downloads its outputs on
+// every launch, so each round still copies the remaining values to and from
+// the device. What's skipped is the packing and unpacking of every round's
+// values.
+
+// ReduceProductChunk multiplies all slots of x together on the GPU
+var ReduceProductChunk ReduceProductChunkPrototype = func(p *StreamPool,
+	g *cyclic.Group, x Operands) (*cyclic.Int, error) {
+	numSlots := x.Len()
+	if numSlots == 0 {
+		return g.NewInt(1), nil
+	}
+
+	env := chooseEnv(g)
+	stream, err := p.TakeStreamFitting(env.streamSizeContaining((numSlots+1)/2, kernelMul2))
+	if err != nil {
+		return nil, err
+	}
+	defer p.ReturnStream(stream)
+	wordLen := env.getWordLen()
+	// Values that don't fit in the stream at once are reduced a block at a
+	// time, and then the blocks' products are reduced
+	blockLen := 2 * env.maxSlots(len(stream.cpuData), kernelMul2)
+	stream.putConstants(env, kernelMul2, g.GetP().Bits())
+
+	load := func(inputs large.Bits, begin, end int) {
+		for i := begin; i < end; i++ {
+			offset := (i - begin) * wordLen
+			putBits(inputs[offset:offset+wordLen], x.Get(uint32(i)).Bits(), wordLen)
+		}
+	}
+	for count := numSlots; ; {
+		var products large.Bits
+		for begin := 0; begin < count; begin += blockLen {
+			end := begin + blockLen
+			if end > count {
+				end = count
+			}
+			inputs := stream.getCpuInputsWords(env, kernelMul2, (end-begin+1)/2)
+			load(inputs, begin, end)
+			err = reduceInStream(env, stream, end-begin)
+			if err != nil {
+				p.markFailed(stream, err)
+				return nil, err
+			}
+			products = append(products, inputs[:wordLen]...)
+		}
+		count = len(products) / wordLen
+		if count == 1 {
+			result := g.NewInt(1)
+			g.OverwriteBits(result, products)
+			if p.SecretInputs() {
+				err = p.wipeInputs(env, g, stream, kernelMul2, (numSlots+1)/2)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		}
+		load = func(inputs large.Bits, begin, end int) {
+			copy(inputs, products[begin*wordLen:end*wordLen])
+		}
+	}
+}
+
+// reduceInStream multiplies the count values at the start of the stream's
+// mul2 inputs, leaving their product in the first one
+func reduceInStream(env gpumathsEnv, stream Stream, count int) error {
+	wordLen := env.getWordLen()
+	for count > 1 {
+		numPairs := count / 2
+		inputs := stream.getCpuInputsWords(env, kernelMul2, numPairs+count%2)
+		// The odd value out lies where the kernel will write its outputs, so
+		// it's kept aside and carried over to the next round unchanged
+		var odd large.Bits
+		if count%2 == 1 {
+			odd = append(odd, inputs[(count-1)*wordLen:count*wordLen]...)
+		}
+		err := env.enqueue(stream, kernelMul2, numPairs)
+		if err != nil {
+			return err
+		}
+		err = get(stream)
+		if err != nil {
+			return err
+		}
+		copy(inputs, stream.getCpuOutputsWords(env, kernelMul2, numPairs))
+		copy(inputs[numPairs*wordLen:], odd)
+		count = numPairs + count%2
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"testing"
+)
+
+// Helper functions shared by tests are located in gpu_test.go
+
+func reduceCPU(grp *cyclic.Group, x *cyclic.IntBuffer) *cyclic.Int {
+	result := grp.NewInt(1)
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		grp.Mul(result, x.Get(i), result)
+	}
+	return result
+}
+
+func TestReduceProductChunk(t *testing.T) {
+	grp := initTestGroup()
+	env := chooseEnv(grp)
+
+	// A stream that only holds a few pairs forces the values to be reduced in
+	// blocks, and the blocks' products to be reduced again
+	streamPool, err := NewStreamPool(1, env.streamSizeContaining(5, kernelMul2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, batchSize := range []uint32{0, 1, 2, 7, 64, 129} {
+		x := initRandomIntBuffer(grp, batchSize, 42, 0)
+		expected := reduceCPU(grp, x)
+		result, err := ReduceProductChunk(streamPool, grp, x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Cmp(expected) != 0 {
+			t.Errorf("Product of %v slots didn't match CPU result", batchSize)
+		}
+	}
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,182 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"strings"
+	"time"
+)
+
+// replay.go runs recorded invocations again and compares the results with
+// the recorded outputs. The invocations run through the op variables, so
+// they can be replayed on the GPU, the CPU, or a server that a client has
+// been installed for.
+
+// ReplayResult describes how one invocation's replay went
+type ReplayResult struct {
+	Index int
+	Op    string
+	// Why the invocation wasn't replayed, or empty if it was
+	Skipped string
+	// Slots whose replayed output differs from the recording, by output name
+	Mismatches map[string][]uint32
+	// Error from the replayed op, or from reading the invocation
+	Err      error
+	Duration time.Duration
+}
+
+// Matched is true if the invocation was replayed and every output matched
+func (rr ReplayResult) Matched() bool {
+	return rr.Skipped == "" && rr.Err == nil && len(rr.Mismatches) == 0
+}
+
+// Replay runs a recorded invocation with the passed pool and compares its
+// outputs with the recorded ones
+// Invocations that failed when they were recorded, or that have redacted
+// inputs, are skipped.
+func Replay(p *StreamPool, inv *Invocation) ReplayResult {
+	result := ReplayResult{Index: inv.Index, Op: inv.Op}
+	if inv.Err != "" {
+		result.Skipped = "the recorded invocation failed: " + inv.Err
+		return result
+	}
+	if len(inv.Redacted) > 0 {
+		result.Skipped = "redacted inputs: " + strings.Join(inv.Redacted, ", ")
+		return result
+	}
+	names, ok := OperandNames[inv.Op]
+	if !ok {
+		result.Err = errors.Errorf("can't replay unknown op %q", inv.Op)
+		return result
+	}
+	if len(inv.Inputs) != len(names.Inputs) ||
+		len(inv.Scalars) != len(names.Scalars) ||
+		len(inv.Outputs) != len(names.Outputs) {
+		result.Err = errors.Errorf("invocation doesn't have the operands "+
+			"that %v takes", inv.Op)
+		return result
+	}
+	prime := large.NewIntFromBytes(inv.P)
+	if prime.BitLen() < 2 || inv.P[len(inv.P)-1]&1 == 0 {
+		result.Err = errors.New("invocation's prime isn't an odd prime")
+		return result
+	}
+	// Only ElGamalChunk uses the generator, so the kernels that don't take
+	// one are recorded without it
+	generator := large.NewInt(2)
+	if len(inv.G) > 0 {
+		generator = large.NewIntFromBytes(inv.G)
+	} else if inv.Op == "ElGamalChunk" {
+		result.Err = errors.New("invocation is missing its generator")
+		return result
+	}
+	if generator.BitLen() == 0 || generator.Cmp(prime) >= 0 {
+		result.Err = errors.New("invocation's generator is outside the group")
+		return result
+	}
+
+	g := cyclic.NewGroup(prime, generator)
+	in := make([]*cyclic.IntBuffer, len(inv.Inputs))
+	for i := range inv.Inputs {
+		var err error
+		in[i], err = unpackInts(g, inv.Inputs[i], inv.NumSlots,
+			names.isExponent(names.Inputs[i]))
+		if err != nil {
+			result.Err = errors.Wrapf(err, "input %v", names.Inputs[i])
+			return result
+		}
+	}
+	scalars := make([]*cyclic.Int, len(inv.Scalars))
+	for i := range inv.Scalars {
+		if !g.BytesInside(inv.Scalars[i]) {
+			result.Err = errors.Errorf("scalar %v is outside the group",
+				names.Scalars[i])
+			return result
+		}
+		scalars[i] = g.NewIntFromBytes(inv.Scalars[i])
+	}
+
+	var out []Operands
+	start := time.Now()
+	switch inv.Op {
+	case "ExpChunk":
+		z := g.NewIntBuffer(inv.NumSlots, g.NewInt(1))
+		_, result.Err = ExpChunk(p, g, in[0], in[1], z)
+		out = []Operands{z}
+	case "ElGamalChunk":
+		result.Err = ElGamalChunk(p, g, in[0], in[1], scalars[0], in[2],
+			in[3])
+		out = []Operands{in[2], in[3]}
+	case "RevealChunk":
+		res := g.NewIntBuffer(inv.NumSlots, g.NewInt(1))
+		result.Err = RevealChunk(p, g, scalars[0], in[0], res)
+		out = []Operands{res}
+	case "Mul2Chunk":
+		res := g.NewIntBuffer(inv.NumSlots, g.NewInt(1))
+		result.Err = Mul2Chunk(p, g, in[0], in[1], res)
+		out = []Operands{res}
+	case "Mul3Chunk":
+		res := g.NewIntBuffer(inv.NumSlots, g.NewInt(1))
+		result.Err = Mul3Chunk(p, g, in[0], in[1], in[2], res)
+		out = []Operands{res}
+	}
+	result.Duration = time.Since(start)
+	if result.Err != nil {
+		return result
+	}
+
+	width := len(inv.P)
+	for i := range out {
+		replayed := packInts(g, out[i])
+		if len(inv.Outputs[i]) != len(replayed) {
+			result.Err = errors.Errorf("recorded output %v has %v bytes, "+
+				"expected %v", names.Outputs[i], len(inv.Outputs[i]),
+				len(replayed))
+			return result
+		}
+		for slot := uint32(0); slot < inv.NumSlots; slot++ {
+			begin := int(slot) * width
+			if !bytes.Equal(replayed[begin:begin+width],
+				inv.Outputs[i][begin:begin+width]) {
+				if result.Mismatches == nil {
+					result.Mismatches = make(map[string][]uint32)
+				}
+				result.Mismatches[names.Outputs[i]] = append(
+					result.Mismatches[names.Outputs[i]], slot)
+			}
+		}
+	}
+	return result
+}
+
+// String summarizes the result on one line
+func (rr ReplayResult) String() string {
+	prefix := fmt.Sprintf("#%v %v", rr.Index, rr.Op)
+	switch {
+	case rr.Skipped != "":
+		return fmt.Sprintf("%v: skipped, %v", prefix, rr.Skipped)
+	case rr.Err != nil:
+		return fmt.Sprintf("%v: error: %v", prefix, rr.Err)
+	case len(rr.Mismatches) > 0:
+		var parts []string
+		for _, name := range OperandNames[rr.Op].Outputs {
+			if slots, ok := rr.Mismatches[name]; ok {
+				parts = append(parts, fmt.Sprintf("%v slots %v", name, slots))
+			}
+		}
+		return fmt.Sprintf("%v: mismatch in %v", prefix,
+			strings.Join(parts, ", "))
+	default:
+		return fmt.Sprintf("%v: ok in %v", prefix, rr.Duration)
+	}
+}
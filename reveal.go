@@ -16,7 +16,7 @@ import "gitlab.com/elixxir/crypto/cyclic"
 // RevealChunkPrototype defines the function type for running the reveal
 // kernel in the GPU.
 type RevealChunkPrototype func(p *StreamPool, g *cyclic.Group,
-	publicCypherKey *cyclic.Int, cypher *cyclic.IntBuffer, result *cyclic.IntBuffer) error
+	publicCypherKey *cyclic.Int, cypher, result Operands) error
 
 // GetInputSize is how big chunk sizes should be to run the reveal operation
 func (RevealChunkPrototype) GetInputSize() uint32 {
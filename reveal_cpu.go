@@ -9,13 +9,6 @@
 
 package gpumaths
 
-import (
-	"errors"
-	"gitlab.com/elixxir/crypto/cyclic"
-)
-
-// RevealChunk is stubbed unless GPU is present.
-var RevealChunk RevealChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	publicCypherKey *cyclic.Int, cypher *cyclic.IntBuffer, result *cyclic.IntBuffer) error {
-	return errors.New(NoGpuErrStr)
-}
+// RevealChunk takes the publicCypherKey'th root of every slot of cypher on
+// the CPU
+var RevealChunk RevealChunkPrototype = cpuRevealChunk
@@ -28,14 +28,17 @@ const kernelReveal = C.KERNEL_REVEAL
 // RevealChunk performs the reveal operation on the cypher payloads
 // Precondition: All int buffers must have the same length
 var RevealChunk RevealChunkPrototype = func(p *StreamPool, g *cyclic.Group,
-	publicCypherKey *cyclic.Int, cypher *cyclic.IntBuffer, result *cyclic.IntBuffer) error {
+	publicCypherKey *cyclic.Int, cypher, result Operands) error {
 	// Populate reveal inputs
 	numSlots := uint32(cypher.Len())
 
 	env := chooseEnv(g)
 
 	// Run kernel on the inputs
-	stream := p.TakeStream()
+	stream, err := p.TakeStreamFitting(env.streamSizeContaining(int(numSlots), kernelReveal))
+	if err != nil {
+		return err
+	}
 	defer p.ReturnStream(stream)
 	maxSlotsReveal := uint32(env.maxSlots(len(stream.cpuData), kernelReveal))
 	if numSlots > maxSlotsReveal {
@@ -49,12 +52,17 @@ var RevealChunk RevealChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 		} else {
 			sliceEnd = numSlots
 		}
-		err := <-reveal(g, publicCypherKey, cypher.GetSubBuffer(i, sliceEnd), result.GetSubBuffer(i, sliceEnd), env, stream)
+		err := <-reveal(g, publicCypherKey, SubRange(cypher, i, sliceEnd), SubRange(result, i, sliceEnd), env, stream)
 		if err != nil {
+			p.markFailed(stream, err)
 			return err
 		}
 	}
 
+	if p.SecretInputs() {
+		return p.wipeInputs(env, g, stream, kernelReveal, int(numSlots),
+			g.GetP().Bits(), publicCypherKey.Bits())
+	}
 	return nil
 }
 
@@ -65,29 +73,20 @@ var RevealChunk RevealChunkPrototype = func(p *StreamPool, g *cyclic.Group,
 // equal to the length of the template-instantiated BN on the GPU.
 // bnLength is a length in bits
 // TODO validate BN length in code (i.e. pick kernel variants based on bn length)
-func reveal(g *cyclic.Group, publicCypherKey *cyclic.Int, cypher *cyclic.IntBuffer, result *cyclic.IntBuffer, env gpumathsEnv, stream Stream) chan error {
+func reveal(g *cyclic.Group, publicCypherKey *cyclic.Int, cypher, result Operands, env gpumathsEnv, stream Stream) chan error {
 	// Return the result later, when the GPU job finishes
 	errors := make(chan error, 1)
 	go func() {
 		// Arrange memory into stream buffers
 		numSlots := uint32(cypher.Len())
 
-		constants := stream.getCpuConstantsWords(env, kernelReveal)
-		offset := 0
-		// Prime
+		// Prime, then the computed PublicCypherKey
+		stream.putConstants(env, kernelReveal, g.GetP().Bits(),
+			publicCypherKey.Bits())
 		bnLengthWords := env.getWordLen()
-		putBits(constants[offset:offset+bnLengthWords], g.GetP().Bits(), bnLengthWords)
-		offset += bnLengthWords
-		// The compted PublicCypherKey
-		putBits(constants[offset:offset+bnLengthWords], publicCypherKey.Bits(), bnLengthWords)
 
 		inputs := stream.getCpuInputsWords(env, kernelReveal, int(numSlots))
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			// Put the CypherPayload for this slot
-			putBits(inputs[offset:offset+bnLengthWords], cypher.Get(i).Bits(), bnLengthWords)
-			offset += bnLengthWords
-		}
+		packInputs(inputs, bnLengthWords, numSlots, cypher)
 
 		// Upload, run, wait for download
 		err := env.enqueue(stream, kernelReveal, int(numSlots))
@@ -106,12 +105,7 @@ func reveal(g *cyclic.Group, publicCypherKey *cyclic.Int, cypher *cyclic.IntBuff
 			return
 		}
 
-		offset = 0
-		for i := uint32(0); i < numSlots; i++ {
-			offsetend := offset + bnLengthWords
-			g.OverwriteBits(result.Get(i), results[offset:offsetend])
-			offset += bnLengthWords
-		}
+		unpackOutputs(g, results, bnLengthWords, numSlots, result)
 
 		errors <- nil
 	}()
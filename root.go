@@ -0,0 +1,71 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"fmt"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// root.go contains the types for taking e-th roots, x**(1/e) mod p, which is
+// the generic form of the reveal operation. The GPU calls are in root_gpu.go
+// and the CPU versions, which match cryptops.RootCoprime, are in root_cpu.go.
+
+// RootChunkPrototype computes result[i] = x[i]**(1/y[i]) for every slot
+type RootChunkPrototype func(p *StreamPool, g *cyclic.Group,
+	x, y, result Operands) error
+
+// RootSharedChunkPrototype computes result[i] = x[i]**(1/y) for every slot,
+// with the same exponent y for every slot
+type RootSharedChunkPrototype func(p *StreamPool, g *cyclic.Group,
+	y *cyclic.Int, x, result Operands) error
+
+// GetInputSize is how big chunk sizes should be to run the root operation
+func (RootChunkPrototype) GetInputSize() uint32 {
+	return 64
+}
+
+// GetName returns the name of the RootChunk operation
+func (RootChunkPrototype) GetName() string {
+	return "RootChunk"
+}
+
+// GetInputSize is how big chunk sizes should be to run the shared root operation
+func (RootSharedChunkPrototype) GetInputSize() uint32 {
+	return 64
+}
+
+// GetName returns the name of the RootSharedChunk operation
+func (RootSharedChunkPrototype) GetName() string {
+	return "RootSharedChunk"
+}
+
+// NotCoprimeError is returned when an exponent passed to a root operation has
+// no inverse mod p-1, so the root isn't uniquely defined
+// No slots are written if this error is returned
+type NotCoprimeError struct {
+	// Slot is the first slot whose exponent wasn't coprime to p-1. It's
+	// always 0 for RootSharedChunk.
+	Slot uint32
+	// Exponent is the offending exponent, in hex
+	Exponent string
+}
+
+func (e *NotCoprimeError) Error() string {
+	return fmt.Sprintf("exponent %v in slot %v isn't coprime to p-1", e.Exponent, e.Slot)
+}
+
+// checkCoprime returns a NotCoprimeError if y isn't coprime to p-1
+func checkCoprime(g *cyclic.Group, y *cyclic.Int, slot uint32) error {
+	gcd := large.NewInt(0).GCD(nil, nil, y.GetLargeInt(), g.GetPSub1())
+	if gcd.Cmp(large.NewInt(1)) != 0 {
+		return &NotCoprimeError{Slot: slot, Exponent: y.Text(16)}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+)
+
+// RootChunk computes the root of each slot of x with the exponent in the same
+// slot of y on the CPU
+var RootChunk RootChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	x, y, result Operands) error {
+	// Check every exponent first, so nothing is written on error
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		err := checkCoprime(g, y.Get(i), i)
+		if err != nil {
+			return err
+		}
+	}
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		cryptops.RootCoprime(g, x.Get(i), y.Get(i), result.Get(i))
+	}
+	return nil
+}
+
+// RootSharedChunk computes the root of each slot of x with the exponent y on
+// the CPU
+var RootSharedChunk RootSharedChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	y *cyclic.Int, x, result Operands) error {
+	err := checkCoprime(g, y, 0)
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		cryptops.RootCoprime(g, x.Get(i), y, result.Get(i))
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import "testing"
+
+// Shared test cases are located in root_test.go
+
+func TestRoot(t *testing.T) {
+	testRoot(t, nil)
+}
@@ -0,0 +1,50 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// root_gpu.go runs the root operations on existing kernels. With a shared
+// exponent, the reveal kernel already computes the root. With an exponent per
+// slot, the inverses mod p-1 are found on the host and the roots are computed
+// on the exp kernel.
+
+// RootChunk computes the root of each slot of x with the exponent in the same
+// slot of y
+// Precondition: All int buffers must have the same length
+var RootChunk RootChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	x, y, result Operands) error {
+	numSlots := uint32(x.Len())
+	inverses := g.NewIntBuffer(numSlots, g.NewInt(1))
+	for i := uint32(0); i < numSlots; i++ {
+		err := checkCoprime(g, y.Get(i), i)
+		if err != nil {
+			return err
+		}
+		inverse := large.NewInt(0).ModInverse(y.Get(i).GetLargeInt(), g.GetPSub1())
+		g.SetLargeInt(inverses.Get(i), inverse)
+	}
+	_, err := ExpChunk(p, g, x, inverses, result)
+	return err
+}
+
+// RootSharedChunk computes the root of each slot of x with the exponent y
+// Precondition: All int buffers must have the same length
+var RootSharedChunk RootSharedChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	y *cyclic.Int, x, result Operands) error {
+	err := checkCoprime(g, y, 0)
+	if err != nil {
+		return err
+	}
+	return RevealChunk(p, g, y, x, result)
+}
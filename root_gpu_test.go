@@ -0,0 +1,26 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import "testing"
+
+// Shared test cases are located in root_test.go
+
+func TestRoot(t *testing.T) {
+	streamPool, err := NewStreamPool(1, 65536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRoot(t, streamPool)
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
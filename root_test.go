@@ -0,0 +1,84 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+// root_test.go has the root test cases shared by the GPU and CPU builds
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"testing"
+)
+
+func TestCheckCoprime(t *testing.T) {
+	grp := makeTestGroup4096()
+	// p-1 = 2q for a safe prime, so odd exponents below q are coprime
+	for _, e := range []int64{1, 3, 65537} {
+		if err := checkCoprime(grp, grp.NewInt(e), 0); err != nil {
+			t.Errorf("%v should be coprime to p-1: %v", e, err)
+		}
+	}
+	err := checkCoprime(grp, grp.NewInt(6), 4)
+	notCoprime, ok := err.(*NotCoprimeError)
+	if !ok {
+		t.Fatalf("Expected a NotCoprimeError, got %v", err)
+	}
+	if notCoprime.Slot != 4 {
+		t.Errorf("Error should be for slot 4, but was for slot %v", notCoprime.Slot)
+	}
+}
+
+// testRoot checks that both root operations undo exponentiation, and that
+// even exponents are rejected without any slots being written
+func testRoot(t *testing.T, p *StreamPool) {
+	const numSlots = 10
+	grp := makeTestGroup4096()
+	x := grp.NewIntBuffer(numSlots, grp.NewInt(1))
+	y := grp.NewIntBuffer(numSlots, grp.NewInt(1))
+	for i := uint32(0); i < numSlots; i++ {
+		grp.SetLargeInt(x.Get(i), large.NewInt(int64(i)*7919+2))
+		grp.SetLargeInt(y.Get(i), large.NewInt(int64(i)*2+3))
+	}
+
+	result := grp.NewIntBuffer(numSlots, grp.NewInt(1))
+	err := RootChunk(p, grp, x, y, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < numSlots; i++ {
+		if grp.Exp(result.Get(i), y.Get(i), grp.NewInt(1)).Cmp(x.Get(i)) != 0 {
+			t.Errorf("RootChunk result in slot %v wasn't a root", i)
+		}
+	}
+
+	shared := grp.NewInt(65537)
+	err = RootSharedChunk(p, grp, shared, x, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < numSlots; i++ {
+		if grp.Exp(result.Get(i), shared, grp.NewInt(1)).Cmp(x.Get(i)) != 0 {
+			t.Errorf("RootSharedChunk result in slot %v wasn't a root", i)
+		}
+	}
+
+	untouched := grp.NewIntBuffer(numSlots, grp.NewInt(5))
+	grp.SetLargeInt(y.Get(6), large.NewInt(10))
+	err = RootChunk(p, grp, x, y, untouched)
+	if notCoprime, ok := err.(*NotCoprimeError); !ok || notCoprime.Slot != 6 {
+		t.Errorf("Expected a NotCoprimeError for slot 6, got %v", err)
+	}
+	err = RootSharedChunk(p, grp, grp.NewInt(4), x, untouched)
+	if _, ok := err.(*NotCoprimeError); !ok {
+		t.Errorf("Expected a NotCoprimeError for the shared exponent, got %v", err)
+	}
+	for i := uint32(0); i < numSlots; i++ {
+		if untouched.Get(i).GetLargeInt().Int64() != 5 {
+			t.Errorf("Slot %v was written even though the exponents were rejected", i)
+		}
+	}
+}
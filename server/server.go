@@ -0,0 +1,236 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+// Package server offers a machine's GPU to other nodes over gRPC
+// It runs the gpumaths operations that the client package sends it on a
+// StreamPool, or on whichever gpumaths.Ops it's made with, e.g.
+// gpumaths.CPUOps for testing without a GPU.
+package server
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/elixxir/gpumathsgo"
+	"gitlab.com/elixxir/gpumathsgo/internal/wire"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// Groups that the server keeps made, so clients can't fill its memory with
+// groups
+const maxCachedGroups = 16
+
+// Server runs offloaded operations on a stream pool
+type Server struct {
+	pool *gpumaths.StreamPool
+
+	// The operations are copied when the server is made, so that installing
+	// a client in the same process can't make the server call itself
+	ops gpumaths.Ops
+
+	groupsMux sync.Mutex
+	groups    map[string]*cyclic.Group
+}
+
+// New makes a server that runs the installed operations on pool
+// The server doesn't own the pool, so the caller still has to close it.
+func New(pool *gpumaths.StreamPool) *Server {
+	return NewWithOps(pool, gpumaths.InstalledOps())
+}
+
+// NewWithOps makes a server that runs ops on pool
+func NewWithOps(pool *gpumaths.StreamPool, ops gpumaths.Ops) *Server {
+	return &Server{
+		pool:   pool,
+		ops:    ops,
+		groups: make(map[string]*cyclic.Group),
+	}
+}
+
+// Register adds the offload service to gs
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&wire.ServiceDesc, s)
+}
+
+// Exp computes Operands[0]**Operands[1]
+func (s *Server) Exp(ctx context.Context, req *wire.OpRequest) (
+	*wire.OpResponse, error) {
+	g, ops, _, err := s.unpack(req, []wire.Domain{wire.GroupElements,
+		wire.Exponents}, 0)
+	if err != nil {
+		return nil, err
+	}
+	z := g.NewIntBuffer(req.NumSlots, g.NewInt(1))
+	_, err = s.ops.ExpChunk(s.pool, g, ops[0], ops[1], z)
+	if err != nil {
+		return nil, opError(wire.MethodExp, err)
+	}
+	return pack(g, req.NumSlots, z)
+}
+
+// ElGamal runs ElGamal with the key in Scalars[0] on Operands key,
+// privateKey, ecrKey and cypher, and returns the new ecrKey and cypher
+func (s *Server) ElGamal(ctx context.Context, req *wire.OpRequest) (
+	*wire.OpResponse, error) {
+	g, ops, scalars, err := s.unpack(req, []wire.Domain{wire.GroupElements,
+		wire.Exponents, wire.GroupElements, wire.GroupElements}, 1)
+	if err != nil {
+		return nil, err
+	}
+	err = s.ops.ElGamalChunk(s.pool, g, ops[0], ops[1], scalars[0], ops[2], ops[3])
+	if err != nil {
+		return nil, opError(wire.MethodElGamal, err)
+	}
+	return pack(g, req.NumSlots, ops[2], ops[3])
+}
+
+// Reveal removes the key in Scalars[0] from Operands[0]
+func (s *Server) Reveal(ctx context.Context, req *wire.OpRequest) (
+	*wire.OpResponse, error) {
+	g, ops, scalars, err := s.unpack(req, []wire.Domain{wire.GroupElements},
+		1)
+	if err != nil {
+		return nil, err
+	}
+	result := g.NewIntBuffer(req.NumSlots, g.NewInt(1))
+	err = s.ops.RevealChunk(s.pool, g, scalars[0], ops[0], result)
+	if err != nil {
+		return nil, opError(wire.MethodReveal, err)
+	}
+	return pack(g, req.NumSlots, result)
+}
+
+// Mul2 multiplies the two Operands
+func (s *Server) Mul2(ctx context.Context, req *wire.OpRequest) (
+	*wire.OpResponse, error) {
+	g, ops, _, err := s.unpack(req, []wire.Domain{wire.GroupElements,
+		wire.GroupElements}, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := g.NewIntBuffer(req.NumSlots, g.NewInt(1))
+	err = s.ops.Mul2Chunk(s.pool, g, ops[0], ops[1], result)
+	if err != nil {
+		return nil, opError(wire.MethodMul2, err)
+	}
+	return pack(g, req.NumSlots, result)
+}
+
+// Mul3 multiplies the three Operands
+func (s *Server) Mul3(ctx context.Context, req *wire.OpRequest) (
+	*wire.OpResponse, error) {
+	g, ops, _, err := s.unpack(req, []wire.Domain{wire.GroupElements,
+		wire.GroupElements, wire.GroupElements}, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := g.NewIntBuffer(req.NumSlots, g.NewInt(1))
+	err = s.ops.Mul3Chunk(s.pool, g, ops[0], ops[1], ops[2], result)
+	if err != nil {
+		return nil, opError(wire.MethodMul3, err)
+	}
+	return pack(g, req.NumSlots, result)
+}
+
+// unpack checks that req has an operand in each of domains and the right
+// number of scalars, and deserializes them
+// Scalars are public cypher keys, so they have to be in the group.
+func (s *Server) unpack(req *wire.OpRequest, domains []wire.Domain,
+	numScalars int) (*cyclic.Group, []*cyclic.IntBuffer, []*cyclic.Int,
+	error) {
+	numOperands := len(domains)
+	if len(req.Operands) != numOperands || len(req.Scalars) != numScalars {
+		return nil, nil, nil, status.Errorf(codes.InvalidArgument,
+			"expected %v operands and %v scalars, got %v and %v",
+			numOperands, numScalars, len(req.Operands), len(req.Scalars))
+	}
+	if req.NumSlots > wire.MaxSlotsPerCall {
+		return nil, nil, nil, status.Errorf(codes.InvalidArgument,
+			"%v slots is more than the limit of %v", req.NumSlots,
+			wire.MaxSlotsPerCall)
+	}
+	g, err := s.group(req.Group)
+	if err != nil {
+		return nil, nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	ops := make([]*cyclic.IntBuffer, numOperands)
+	for i := range req.Operands {
+		ops[i], err = wire.NewBuffer(g, req.Operands[i], req.NumSlots,
+			domains[i])
+		if err != nil {
+			return nil, nil, nil, status.Errorf(codes.InvalidArgument,
+				"operand %v: %v", i, err)
+		}
+	}
+	scalars := make([]*cyclic.Int, numScalars)
+	for i := range req.Scalars {
+		if len(req.Scalars[i]) > wire.IntLen(g) {
+			return nil, nil, nil, status.Errorf(codes.InvalidArgument,
+				"scalar %v is longer than the group's prime", i)
+		}
+		if !g.BytesInside(req.Scalars[i]) {
+			return nil, nil, nil, status.Errorf(codes.InvalidArgument,
+				"scalar %v isn't in the group", i)
+		}
+		scalars[i] = g.NewIntFromBytes(req.Scalars[i])
+	}
+	return g, ops, scalars, nil
+}
+
+// group returns the cyclic group that gr describes, making it the first
+// time the server sees it
+func (s *Server) group(gr wire.Group) (*cyclic.Group, error) {
+	key := gr.Key()
+	s.groupsMux.Lock()
+	defer s.groupsMux.Unlock()
+	if g, ok := s.groups[key]; ok {
+		return g, nil
+	}
+	g, err := gr.Cyclic()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.groups) >= maxCachedGroups {
+		// Forget any one of them to make room
+		for k := range s.groups {
+			delete(s.groups, k)
+			break
+		}
+	}
+	s.groups[key] = g
+	return g, nil
+}
+
+// pack serializes an operation's outputs
+func pack(g *cyclic.Group, numSlots uint32, results ...gpumaths.Operands) (
+	*wire.OpResponse, error) {
+	resp := &wire.OpResponse{Results: make([][]byte, len(results))}
+	for i := range results {
+		var err error
+		resp.Results[i], err = wire.PackOperands(g, results[i], 0, numSlots)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return resp, nil
+}
+
+// opError turns an error from an operation into a gRPC status
+// The pool being closed means the server is going away, so the client can
+// try again elsewhere.
+func opError(method string, err error) error {
+	jww.WARN.Printf("Offloaded %v failed: %v", method, err)
+	code := codes.Internal
+	if errors.Is(err, gpumaths.ErrStreamPoolClosed) {
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
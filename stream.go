@@ -0,0 +1,716 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"sync"
+	"time"
+)
+
+// stream.go contains the stream pool's bookkeeping, which is the same for
+// every build. Creating and destroying the streams themselves is done in
+// stream_gpu.go and stream_cpu.go.
+
+// ErrStreamPoolClosed is returned when taking a stream from a pool that's
+// been closed or destroyed
+var ErrStreamPoolClosed = errors.New("stream pool is closed")
+
+// ErrNoStreamAvailable is returned by TryTakeStream when every stream is in
+// use
+var ErrNoStreamAvailable = errors.New("no stream is available")
+
+// ErrNoHealthyStreams is returned when taking a stream from a pool whose
+// streams all broke and couldn't be replaced, since no stream would ever
+// become available. Growing the pool makes it usable again.
+var ErrNoHealthyStreams = errors.New("stream pool has no healthy streams left")
+
+// ErrStreamTimeout is returned by TakeStreamTimeout when no stream became
+// available in time
+var ErrStreamTimeout = errors.New("timed out waiting for a stream")
+
+// errNilStreamPool is returned when a stream pool method is called on nil
+var errNilStreamPool = errors.New("stream pool is nil")
+
+// Priority decides the order in which callers waiting for a stream get one.
+// Waiters with a higher priority are always served first, and waiters with
+// the same priority are served in the order they started waiting.
+type Priority int
+
+const (
+	// PriorityLow is for bulk work that can wait, like precomputation
+	PriorityLow Priority = -1
+	// PriorityNormal is the priority of pools that weren't given one
+	PriorityNormal Priority = 0
+	// PriorityHigh is for latency-sensitive work, like realtime phases
+	PriorityHigh Priority = 1
+)
+
+// StreamPool hands out streams to the ops that need them
+// The pool's streams can have different amounts of memory. Ops get the
+// smallest free stream that fits their whole input, so small jobs don't tie
+// up big streams.
+// Streams can be added and retired while the pool is in use with Grow and
+// Shrink.
+// A StreamPool returned by WithPriority or WithTag shares its streams with the
+// pool that it came from, so closing or destroying either closes both.
+// Optional improvements:
+//  - create streams with high priority to speed up kernels used for realtime
+type StreamPool struct {
+	*streamPoolState
+	// Priority used when this pool takes streams
+	priority Priority
+	// Tag that this pool's streams are charged to, if any
+	tag string
+	// Set if the ops should wipe their inputs from the streams they use
+	secretInputs bool
+}
+
+// streamPoolState is shared by all views of a stream pool
+type streamPoolState struct {
+	// Protects everything below, except for the fields used by Close/Destroy
+	mux sync.Mutex
+	// Every stream in the pool, used to destroy them
+	streams []Stream
+	// Memory size of the pool's biggest stream. Kept once the last stream is
+	// removed, so that Grow knows what size to replace it with.
+	largest int
+	// Streams that aren't in use
+	free []Stream
+	// Callers waiting for a stream, sorted by priority and then arrival
+	waiters []*streamWaiter
+	// Set when the pool stops handing out streams
+	closed bool
+	// Set once Destroy has destroyed the streams
+	destroyed bool
+	// Number of streams that Shrink will retire when they're returned
+	retiring int
+	// Health records of the pool's streams, by stream id
+	slots map[uintptr]*streamSlot
+	// Index to give the next new slot
+	nextSlot int
+	// Slots whose broken stream is being replaced
+	quarantined []*streamSlot
+	// Number of broken streams that couldn't be replaced
+	failedReplacements int
+	// Broken streams being replaced in the background, so Destroy can wait
+	// for them
+	replacing sync.WaitGroup
+	// Where each stream that's out was taken, by stream id. Only kept in
+	// builds with leak detection.
+	checkouts map[uintptr]*StreamCheckout
+	// Records the ops' invocations, if set
+	recorder *Recorder
+	// Usage and limits of each tag
+	tags map[string]*tagUsage
+
+	// Signalled when a stream is returned, so Close can check again
+	returned chan struct{}
+	// Only one Close can wait for the streams at a time
+	closeMux sync.Mutex
+	// Destroy only destroys the streams once, and remembers the result
+	destroyOnce sync.Once
+	destroyErr  error
+}
+
+// streamWaiter is a caller blocked in TakeStream
+type streamWaiter struct {
+	priority Priority
+	tag      string
+	// Memory size that the waiter asked for
+	memSize int
+	// Receives the stream when it's this waiter's turn. Closed if the pool
+	// closes first, or if the waiter fails.
+	ch chan Stream
+	// Why the waiter failed, set before ch is closed. Nil if the pool
+	// closed.
+	err error
+}
+
+// closedErr is the error for a waiter whose channel was closed
+func (w *streamWaiter) closedErr() error {
+	if w.err != nil {
+		return w.err
+	}
+	return ErrStreamPoolClosed
+}
+
+// StreamClass describes a group of streams with the same amount of memory
+type StreamClass struct {
+	// How many streams of this class to create
+	NumStreams int
+	// Size of each stream's memory, in bytes
+	MemSize int
+}
+
+// newStreamPool makes a pool that hands out the passed streams
+func newStreamPool(streams []Stream) *StreamPool {
+	state := &streamPoolState{
+		returned: make(chan struct{}, 1),
+		slots:    make(map[uintptr]*streamSlot),
+		tags:     make(map[string]*tagUsage),
+	}
+	state.watchForLeaks()
+	result := &StreamPool{streamPoolState: state}
+	for i := range streams {
+		result.addStream(streams[i], nil)
+	}
+	result.updateLargest()
+	return result
+}
+
+// WithPriority returns a view of the pool that takes streams with the passed
+// priority. Pass the view to the ops instead of the pool to run them at that
+// priority.
+func (sm *StreamPool) WithPriority(priority Priority) *StreamPool {
+	if sm == nil {
+		return nil
+	}
+	view := *sm
+	view.priority = priority
+	return &view
+}
+
+// Priority returns the priority that this pool takes streams with
+func (sm *StreamPool) Priority() Priority {
+	if sm == nil {
+		return PriorityNormal
+	}
+	return sm.priority
+}
+
+// WithSecretInputs returns a view of the pool whose ops treat their inputs as
+// secret, e.g. to run PermuteMul2Chunk or Mul2Chunk on keys. Once such an op
+// is done with a stream, it overwrites its inputs in the stream's pinned
+// memory and in device memory. ElGamalChunk always does this for its private
+// keys. The CPU ops don't use streams, so the view only matters with the gpu
+// tag.
+func (sm *StreamPool) WithSecretInputs() *StreamPool {
+	if sm == nil {
+		return nil
+	}
+	view := *sm
+	view.secretInputs = true
+	return &view
+}
+
+// SecretInputs is true if the ops treat inputs as secret on this pool
+func (sm *StreamPool) SecretInputs() bool {
+	return sm != nil && sm.secretInputs
+}
+
+// TakeStream gets any stream from the pool, blocking until one is available
+// Returns ErrStreamPoolClosed if the pool is closed before or while waiting.
+func (sm *StreamPool) TakeStream() (Stream, error) {
+	return sm.takeStream(context.Background(), 0)
+}
+
+// TakeStreamFitting gets the smallest stream with at least memSize bytes of
+// memory, blocking until one is available
+// If no stream in the pool is that big, it gets one of the biggest streams
+// instead, and the caller has to split its work up to fit.
+func (sm *StreamPool) TakeStreamFitting(memSize int) (Stream, error) {
+	return sm.takeStream(context.Background(), memSize)
+}
+
+// TryTakeStream gets a stream from the pool if one is available right now,
+// and returns ErrNoStreamAvailable otherwise, including when the pool's tag
+// has as many streams as it's allowed
+func (sm *StreamPool) TryTakeStream() (Stream, error) {
+	if !sm.isUsable() {
+		return Stream{}, errNilStreamPool
+	}
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+	if sm.closed {
+		return Stream{}, ErrStreamPoolClosed
+	}
+	if sm.noHealthyStreams() {
+		return Stream{}, ErrNoHealthyStreams
+	}
+	err := sm.checkQuota(sm.tag)
+	if err != nil {
+		return Stream{}, err
+	}
+	i := sm.findFree(0)
+	if i < 0 || sm.tagAtLimit(sm.tag) {
+		return Stream{}, ErrNoStreamAvailable
+	}
+	result := sm.removeFree(i)
+	sm.chargeCheckout(result, sm.tag)
+	sm.trackCheckout(result)
+	return result, nil
+}
+
+// TakeStreamTimeout gets a stream from the pool, waiting at most timeout for
+// one to become available. Returns ErrStreamTimeout if none did.
+func (sm *StreamPool) TakeStreamTimeout(timeout time.Duration) (Stream, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	s, err := sm.TakeStreamContext(ctx)
+	if err == context.DeadlineExceeded {
+		return s, ErrStreamTimeout
+	}
+	return s, err
+}
+
+// TakeStreamContext gets a stream from the pool, waiting until one is
+// available or ctx is done. If ctx is done first, its error is returned.
+func (sm *StreamPool) TakeStreamContext(ctx context.Context) (Stream, error) {
+	return sm.takeStream(ctx, 0)
+}
+
+// takeStream gets the smallest stream that fits memSize, waiting until one is
+// available or ctx is done
+func (sm *StreamPool) takeStream(ctx context.Context, memSize int) (Stream,
+	error) {
+	s, err := sm.waitForStream(ctx, memSize)
+	if err == nil && leakDetection {
+		sm.mux.Lock()
+		sm.trackCheckout(s)
+		sm.mux.Unlock()
+	}
+	return s, err
+}
+
+// waitForStream does the work of takeStream
+func (sm *StreamPool) waitForStream(ctx context.Context, memSize int) (Stream,
+	error) {
+	if !sm.isUsable() {
+		return Stream{}, errNilStreamPool
+	}
+	sm.mux.Lock()
+	if sm.closed {
+		sm.mux.Unlock()
+		return Stream{}, ErrStreamPoolClosed
+	}
+	if sm.noHealthyStreams() {
+		sm.mux.Unlock()
+		return Stream{}, ErrNoHealthyStreams
+	}
+	err := sm.checkQuota(sm.tag)
+	if err != nil {
+		sm.mux.Unlock()
+		return Stream{}, err
+	}
+	// No waiter could use any of the free streams, or it would have gotten
+	// it already, so there's no need to queue up behind anyone
+	if i := sm.findFree(memSize); i >= 0 && !sm.tagAtLimit(sm.tag) {
+		s := sm.removeFree(i)
+		sm.chargeCheckout(s, sm.tag)
+		sm.mux.Unlock()
+		return s, nil
+	}
+	w := &streamWaiter{
+		priority: sm.priority,
+		tag:      sm.tag,
+		memSize:  memSize,
+		ch:       make(chan Stream, 1),
+	}
+	sm.enqueueWaiter(w)
+	sm.mux.Unlock()
+
+	select {
+	case s, ok := <-w.ch:
+		if !ok {
+			return Stream{}, w.closedErr()
+		}
+		return s, nil
+	case <-ctx.Done():
+		sm.mux.Lock()
+		removed := sm.removeWaiter(w)
+		sm.mux.Unlock()
+		if removed {
+			return Stream{}, ctx.Err()
+		}
+		// The waiter was served or woken up at the same time as ctx was
+		// done, so its channel is ready
+		s, ok := <-w.ch
+		if !ok {
+			return Stream{}, w.closedErr()
+		}
+		return s, nil
+	}
+}
+
+// ReturnStream puts a stream taken with TakeStream back in the pool
+// Streams must still be returned after the pool is closed, so Close can tell
+// that they're no longer in use.
+// If a CUDA error happened on the stream, it's quarantined and replaced with a
+// new stream in the background instead of being put back.
+func (sm *StreamPool) ReturnStream(s Stream) {
+	if !sm.isUsable() || !s.isValid() {
+		return
+	}
+	sm.mux.Lock()
+	sm.trackReturn(s)
+	sm.chargeReturn(s)
+	slot := sm.slots[s.id()]
+	retire := sm.retiring > 0 && !sm.destroyed
+	broken := slot != nil && slot.failed && !sm.destroyed
+	if retire {
+		sm.retiring--
+		sm.removeStream(s)
+		sm.updateLargest()
+	} else if broken {
+		sm.removeStream(s)
+		sm.quarantined = append(sm.quarantined, slot)
+		sm.updateLargest()
+		sm.replacing.Add(1)
+	} else {
+		sm.free = append(sm.free, s)
+	}
+	sm.dispatch()
+	sm.mux.Unlock()
+
+	if retire {
+		err := destroyStreams([]Stream{s})
+		if err != nil {
+			jww.ERROR.Printf("Couldn't destroy retired stream: %v", err)
+		}
+	} else if broken {
+		// Making a stream takes a while, so the caller doesn't wait for it
+		go sm.replaceStream(s, slot)
+	}
+	select {
+	case sm.returned <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the pool from handing out streams, waits for every stream to
+// be returned, then destroys them
+// If ctx expires first, Close returns the context's error and the streams are
+// left alone. The pool stays closed, and Close can be called again to keep
+// waiting, or Destroy can be called to destroy the streams anyway.
+func (sm *StreamPool) Close(ctx context.Context) error {
+	if !sm.isUsable() {
+		return errNilStreamPool
+	}
+	sm.stopCheckouts()
+
+	sm.closeMux.Lock()
+	defer sm.closeMux.Unlock()
+	for {
+		sm.mux.Lock()
+		numStreams := len(sm.streams)
+		numOut := numStreams - len(sm.free)
+		sm.mux.Unlock()
+		if numOut <= 0 {
+			return sm.Destroy()
+		}
+		select {
+		case <-sm.returned:
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "%v of %v streams weren't returned",
+				numOut, numStreams)
+		}
+	}
+}
+
+// Destroy all the stream pool's streams
+// The streams' CPU buffers are wiped before they're freed.
+// This doesn't wait on any work to finish before destroying the streams. Use
+// Close to wait for streams to be returned first.
+// Destroy can be called more than once, and the pool stops handing out
+// streams after the first call.
+func (sm *StreamPool) Destroy() error {
+	if !sm.isUsable() {
+		return errNilStreamPool
+	}
+	sm.destroyOnce.Do(func() {
+		sm.stopCheckouts()
+		sm.mux.Lock()
+		sm.reportLeaksOnDestroy()
+		sm.destroyed = true
+		streams := sm.streams
+		sm.mux.Unlock()
+		// Replacements see that the pool is destroyed and destroy their
+		// streams themselves
+		sm.replacing.Wait()
+		sm.destroyErr = destroyStreams(streams)
+	})
+	return sm.destroyErr
+}
+
+// NumStreams returns how many streams the pool has, not counting streams that
+// Shrink is waiting to retire
+func (sm *StreamPool) NumStreams() int {
+	if !sm.isUsable() {
+		return 0
+	}
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+	return len(sm.streams) - sm.retiring
+}
+
+// Grow adds n streams to the pool, each as big as the pool's biggest stream
+// The new streams can be taken as soon as Grow returns. If every stream broke
+// and was removed, they're as big as the last biggest stream was.
+func (sm *StreamPool) Grow(n int) error {
+	if !sm.isUsable() {
+		return errNilStreamPool
+	}
+	sm.mux.Lock()
+	memSize := sm.largest
+	sm.mux.Unlock()
+	if memSize == 0 {
+		return errors.New("can't tell what size of stream to grow an " +
+			"empty pool with. Use GrowClass instead")
+	}
+	return sm.GrowClass(StreamClass{NumStreams: n, MemSize: memSize})
+}
+
+// GrowClass adds the streams of the passed class to the pool
+func (sm *StreamPool) GrowClass(class StreamClass) error {
+	if !sm.isUsable() {
+		return errNilStreamPool
+	}
+	if class.NumStreams < 0 {
+		return errors.Errorf("can't grow pool by %v streams",
+			class.NumStreams)
+	}
+	sm.mux.Lock()
+	closed := sm.closed
+	sm.mux.Unlock()
+	if closed {
+		return ErrStreamPoolClosed
+	}
+
+	// Creating streams takes a while, so don't hold up the pool for it
+	streams, err := createStreams(class.NumStreams, class.MemSize)
+	if err != nil {
+		return err
+	}
+
+	sm.mux.Lock()
+	if sm.closed {
+		sm.mux.Unlock()
+		err = destroyStreams(streams)
+		if err != nil {
+			return errors.Wrap(err, ErrStreamPoolClosed.Error())
+		}
+		return ErrStreamPoolClosed
+	}
+	for i := range streams {
+		sm.addStream(streams[i], nil)
+	}
+	sm.updateLargest()
+	sm.dispatch()
+	sm.mux.Unlock()
+	return nil
+}
+
+// Shrink retires n streams from the pool
+// Free streams are destroyed right away, smallest first. If there aren't
+// enough free streams, the rest are destroyed as they're returned, so streams
+// are never destroyed while in use. The pool must keep at least one stream.
+func (sm *StreamPool) Shrink(n int) error {
+	if !sm.isUsable() {
+		return errNilStreamPool
+	}
+	if n < 0 {
+		return errors.Errorf("can't shrink pool by %v streams", n)
+	}
+	sm.mux.Lock()
+	if sm.closed {
+		sm.mux.Unlock()
+		return ErrStreamPoolClosed
+	}
+	remaining := len(sm.streams) - sm.retiring - n
+	if remaining < 1 {
+		sm.mux.Unlock()
+		return errors.Errorf("can't shrink pool by %v streams: it only "+
+			"has %v", n, len(sm.streams)-sm.retiring)
+	}
+	var retired []Stream
+	for len(retired) < n && len(sm.free) > 0 {
+		s := sm.removeFree(sm.findFree(0))
+		sm.removeStream(s)
+		retired = append(retired, s)
+	}
+	sm.retiring += n - len(retired)
+	// Waiters for big streams might be able to use what's left now
+	sm.updateLargest()
+	sm.dispatch()
+	sm.mux.Unlock()
+
+	return destroyStreams(retired)
+}
+
+// isUsable is false for nil and zero-value pools
+func (sm *StreamPool) isUsable() bool {
+	return sm != nil && sm.streamPoolState != nil
+}
+
+// stopCheckouts makes TakeStream fail from now on, and wakes up anyone
+// waiting for a stream
+func (sm *StreamPool) stopCheckouts() {
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+	if sm.closed {
+		return
+	}
+	sm.closed = true
+	for i := range sm.waiters {
+		close(sm.waiters[i].ch)
+	}
+	sm.waiters = nil
+}
+
+// failWaiter takes w out of the queue and wakes it up with err
+// sm.mux must be held
+func (sm *StreamPool) failWaiter(w *streamWaiter, err error) {
+	sm.removeWaiter(w)
+	w.err = err
+	close(w.ch)
+}
+
+// noHealthyStreams is true if every stream in the pool broke and couldn't be
+// replaced, and none are still being replaced
+// sm.mux must be held
+func (sm *StreamPool) noHealthyStreams() bool {
+	return len(sm.streams) == 0 && len(sm.quarantined) == 0 &&
+		sm.failedReplacements > 0
+}
+
+// dispatch hands free streams to every waiter that can use one, in queue
+// order, skipping waiters whose tag has as many streams as it's allowed
+// Waiters whose tag used up its quota while they were queued fail with
+// ErrTagQuotaExceeded.
+// sm.mux must be held
+func (sm *StreamPool) dispatch() {
+	for i := 0; i < len(sm.waiters); {
+		w := sm.waiters[i]
+		err := sm.checkQuota(w.tag)
+		if err != nil {
+			sm.failWaiter(w, err)
+			continue
+		}
+		j := sm.findFree(w.memSize)
+		if j < 0 || sm.tagAtLimit(w.tag) {
+			i++
+			continue
+		}
+		sm.removeWaiter(w)
+		s := sm.removeFree(j)
+		sm.chargeCheckout(s, w.tag)
+		w.ch <- s
+	}
+}
+
+// addStream puts a new stream in the pool as a free stream
+// A new slot is made for it if slot is nil.
+// sm.mux must be held, except while creating the pool
+func (sm *StreamPool) addStream(s Stream, slot *streamSlot) {
+	if slot == nil {
+		slot = &streamSlot{index: sm.nextSlot}
+		sm.nextSlot++
+	}
+	sm.slots[s.id()] = slot
+	sm.streams = append(sm.streams, s)
+	sm.free = append(sm.free, s)
+}
+
+// removeStream takes s out of the list of the pool's streams
+// sm.mux must be held
+func (sm *StreamPool) removeStream(s Stream) {
+	delete(sm.slots, s.id())
+	for i := range sm.streams {
+		if sm.streams[i].id() == s.id() {
+			copy(sm.streams[i:], sm.streams[i+1:])
+			sm.streams = sm.streams[:len(sm.streams)-1]
+			return
+		}
+	}
+}
+
+// updateLargest finds the size of the pool's biggest stream again
+// If the pool has no streams left, the size of its last biggest stream is
+// kept, since there's nothing for it to fit.
+// sm.mux must be held, except while creating the pool
+func (sm *StreamPool) updateLargest() {
+	if len(sm.streams) == 0 {
+		return
+	}
+	sm.largest = 0
+	for i := range sm.streams {
+		if sm.streams[i].size() > sm.largest {
+			sm.largest = sm.streams[i].size()
+		}
+	}
+}
+
+// fits is true if s can be handed to a caller that asked for memSize bytes
+// If no stream is big enough, only the biggest streams fit.
+// sm.mux must be held
+func (sm *StreamPool) fits(s Stream, memSize int) bool {
+	if memSize > sm.largest {
+		memSize = sm.largest
+	}
+	return s.size() >= memSize
+}
+
+// findFree returns the index of the smallest free stream that fits memSize,
+// preferring the most recently returned one, or -1 if none do
+// sm.mux must be held
+func (sm *StreamPool) findFree(memSize int) int {
+	best := -1
+	for i := len(sm.free) - 1; i >= 0; i-- {
+		if sm.fits(sm.free[i], memSize) &&
+			(best < 0 || sm.free[i].size() < sm.free[best].size()) {
+			best = i
+		}
+	}
+	return best
+}
+
+// removeFree takes the free stream at index i out of the free list
+// sm.mux must be held
+func (sm *StreamPool) removeFree(i int) Stream {
+	s := sm.free[i]
+	copy(sm.free[i:], sm.free[i+1:])
+	sm.free = sm.free[:len(sm.free)-1]
+	return s
+}
+
+// enqueueWaiter puts w after every waiter with the same or higher priority
+// sm.mux must be held
+func (sm *StreamPool) enqueueWaiter(w *streamWaiter) {
+	i := len(sm.waiters)
+	for i > 0 && sm.waiters[i-1].priority < w.priority {
+		i--
+	}
+	sm.waiters = append(sm.waiters, nil)
+	copy(sm.waiters[i+1:], sm.waiters[i:])
+	sm.waiters[i] = w
+}
+
+// removeWaiter takes w out of the queue, and returns false if it had already
+// been taken out to be served or woken up
+// sm.mux must be held
+func (sm *StreamPool) removeWaiter(w *streamWaiter) bool {
+	for i := range sm.waiters {
+		if sm.waiters[i] == w {
+			copy(sm.waiters[i:], sm.waiters[i+1:])
+			sm.waiters[len(sm.waiters)-1] = nil
+			sm.waiters = sm.waiters[:len(sm.waiters)-1]
+			return true
+		}
+	}
+	return false
+}
+
+// numWaiters is the number of callers waiting for a stream
+func (sm *StreamPool) numWaiters() int {
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+	return len(sm.waiters)
+}
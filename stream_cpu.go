@@ -9,10 +9,27 @@
 
 package gpumaths
 
-import "errors"
+import (
+	"errors"
+	"sync/atomic"
+)
 
 // Stub out all exported symbols with reduced functionality
-type Stream struct{}
+type Stream struct {
+	// Tells stubbed streams apart
+	handle uint64
+	// Memory size the stream was created with
+	capacity int
+	// Tag that the stream's checkout is charged to, shared by its copies
+	account *streamAccount
+}
+
+// Stubbed streams don't launch kernels, so there aren't any launches to
+// record
+type kernelLaunch struct{}
+
+// Last id given to a stubbed stream
+var lastStreamId uint64
 
 func (s *Stream) GetMaxSlotsExp() int {
 	return 0
@@ -22,20 +39,54 @@ func (s *Stream) GetMaxSlotsElGamal() int {
 	return 0
 }
 
-type StreamPool struct{}
+// Stubbed streams don't hold anything, so they're always usable
+func (s Stream) isValid() bool {
+	return true
+}
 
 func NewStreamPool(numStreams int, memSize int) (*StreamPool, error) {
 	return nil, errors.New("gpumaths stubbed build doesn't support CUDA stream pool")
 }
 
-func (sm *StreamPool) TakeStream() Stream {
-	return Stream{}
+func NewStreamPoolClasses(classes ...StreamClass) (*StreamPool, error) {
+	return nil, errors.New("gpumaths stubbed build doesn't support CUDA stream pool")
+}
+
+// NewEmulatedStreamPool makes a pool of stand-in streams that don't hold any
+// memory. The CPU ops ignore their pool, so this is for testing code that
+// manages pools, e.g. taking, returning and growing them, and for recording
+// the CPU ops' invocations.
+func NewEmulatedStreamPool(numStreams int, memSize int) (*StreamPool, error) {
+	streams, err := createStreams(numStreams, memSize)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamPool(streams), nil
+}
+
+// Memory size of the stream
+func (s Stream) size() int {
+	return s.capacity
 }
 
-func (sm *StreamPool) ReturnStream(s Stream) {}
+// Identifies the stream within its pool
+func (s Stream) id() uintptr {
+	return uintptr(s.handle)
+}
+
+// Stubbed streams let the pool's bookkeeping be tested without CUDA
+func createStreams(numStreams int, capacity int) ([]Stream, error) {
+	streams := make([]Stream, numStreams)
+	for i := range streams {
+		streams[i].handle = atomic.AddUint64(&lastStreamId, 1)
+		streams[i].capacity = capacity
+		streams[i].account = &streamAccount{}
+	}
+	return streams, nil
+}
 
-func (sm *StreamPool) Destroy() error {
-	return errors.New("gpumaths stubbed build doesn't support CUDA stream pool")
+func destroyStreams(streams []Stream) error {
+	return nil
 }
 
 func MaxSlots(memSize int, op int) int {
@@ -18,6 +18,8 @@ package gpumaths
 */
 import "C"
 import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
 	"gitlab.com/xx_network/crypto/large"
 	"unsafe"
 )
@@ -30,6 +32,11 @@ type Stream struct {
 	cpuData []byte
 	// Same data but in words!
 	cpuDataWords large.Bits
+	// What's packed in the constants region, shared by copies of the stream
+	constants *constantsCache
+	// Tag that the stream's checkout is charged to, shared by copies of the
+	// stream
+	account *streamAccount
 }
 
 // Return the portion of the stream's CPU memory that's used for outputs
@@ -52,53 +59,135 @@ func (s *Stream) getCpuConstantsWords(g gpumathsEnv, kernel C.enum_kernel) large
 	return s.cpuDataWords[:g.getConstantsSizeWords(kernel)]
 }
 
-// Optional improvements:
-//  - create streams with high priority to speed up kernels used for realtime
-type StreamPool struct {
-	// Used to prevent concurrent access to streams
-	streamChan chan Stream
-	// Used to time-bound stream deletion. These are the same streams that you can get from the channel
-	streams []Stream
+// Overwrite the inputs of a kernel with zeroes
+// Operations with secret inputs call this once the upload is done, so the
+// secrets don't stay in the pinned memory until the next kernel overwrites
+// them. The copy in device memory is overwritten by wipeInputs.
+func (s *Stream) zeroCpuInputsWords(g gpumathsEnv, kernel C.enum_kernel, numItems int) {
+	inputs := s.getCpuInputsWords(g, kernel, numItems)
+	for i := range inputs {
+		inputs[i] = 0
+	}
 }
 
-// numStreams: Number of streams per device. 2 is usually fine
-func NewStreamPool(numStreams int, memSize int) (*StreamPool, error) {
-	// We should be able to init CUDA here and have it work, right?
-	err := initCuda()
+// wipeLaunch returns the kernel and number of slots to launch on zeroed
+// inputs to overwrite the inputs of kernel on numSlots slots in device memory
+// Every launch uploads its constants and inputs from the start of the
+// buffer, so a launch overwrites another's inputs if its own inputs end at or
+// after theirs. Mul2 is the cheapest kernel, so it's used if it can have
+// enough slots. Otherwise, kernel is launched again on its own slots. Mul3
+// needs this, as its inputs take up more of the stream than mul2's can.
+func wipeLaunch(env gpumathsEnv, memSize int, kernel C.enum_kernel, numSlots int) (C.enum_kernel, int) {
+	if maxSlots := env.maxSlots(memSize, kernel); numSlots > maxSlots {
+		numSlots = maxSlots
+	}
+	end := env.getConstantsSizeWords(kernel) + env.getInputSizeWords(kernel)*numSlots
+	mul2Start := env.getConstantsSizeWords(kernelMul2)
+	mul2SlotWords := env.getInputSizeWords(kernelMul2)
+	mul2Slots := 0
+	if end > mul2Start {
+		mul2Slots = (end - mul2Start + mul2SlotWords - 1) / mul2SlotWords
+	}
+	if mul2Slots <= env.maxSlots(memSize, kernelMul2) {
+		return kernelMul2, mul2Slots
+	}
+	return kernel, numSlots
+}
+
+// Overwrite the inputs of a kernel that ran on numSlots slots, in the pinned
+// memory and in device memory
+// Ops that ran the kernel more than once pass all of their slots, and the
+// largest launch that fits the stream is wiped. The native library can't
+// clear device memory by itself, so this uploads zeroes over the inputs as
+// the inputs of the launch from wipeLaunch. constants are kernel's, in case
+// it's launched again.
+func (s *Stream) wipeInputs(env gpumathsEnv, g *cyclic.Group, kernel C.enum_kernel, numSlots int,
+	constants ...large.Bits) error {
+	if numSlots == 0 {
+		return nil
+	}
+	// The wipe isn't one of the caller's kernels, so it isn't recorded
+	s.account.wiping = true
+	defer func() { s.account.wiping = false }()
+	wipeKernel, wipeSlots := wipeLaunch(env, len(s.cpuData), kernel, numSlots)
+	if wipeKernel == kernelMul2 {
+		constants = []large.Bits{g.GetP().Bits()}
+	}
+	s.zeroCpuInputsWords(env, wipeKernel, wipeSlots)
+	s.putConstants(env, wipeKernel, constants...)
+	err := env.enqueue(*s, wipeKernel, wipeSlots)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	// Each stream should support all operations if there's enough memory available
-	var result StreamPool
-	streams, err := createStreams(numStreams, memSize)
+	return get(*s)
+}
+
+// wipeInputs overwrites the inputs of an op's largest kernel on stream, and
+// marks the stream as failed if it can't
+func (sm *StreamPool) wipeInputs(env gpumathsEnv, g *cyclic.Group, stream Stream,
+	kernel C.enum_kernel, numSlots int, constants ...large.Bits) error {
+	err := stream.wipeInputs(env, g, kernel, numSlots, constants...)
 	if err != nil {
-		// TODO Destroy streams first before returning
-		return nil, err
+		// The stream is destroyed instead of being reused
+		sm.markFailed(stream, err)
+		return errors.WithMessage(err, "couldn't wipe secret inputs")
 	}
-	result.streams = streams
-	result.streamChan = make(chan Stream, len(streams))
-	for i := range result.streams {
-		result.streamChan <- result.streams[i]
+	return nil
+}
+
+// Overwrite the stream's entire CPU buffer with zeroes
+func (s *Stream) zeroCpuData() {
+	for i := range s.cpuData {
+		s.cpuData[i] = 0
 	}
+	s.constants.invalidate()
+}
 
-	return &result, err
+// Stream is usable if it points to a stream on the C side
+func (s Stream) isValid() bool {
+	return s.s != nil
 }
 
-// If you need to, it's also possible to create an equivalent method that times out
-// This method gets a stream from the channel
-func (sm *StreamPool) TakeStream() Stream {
-	return <-sm.streamChan
+// Memory size of the stream
+func (s Stream) size() int {
+	return len(s.cpuData)
 }
 
-func (sm *StreamPool) ReturnStream(s Stream) {
-	if s.s != nil {
-		sm.streamChan <- s
-	}
+// Identifies the stream within its pool
+func (s Stream) id() uintptr {
+	return uintptr(s.s)
+}
+
+// numStreams: Number of streams per device. 2 is usually fine
+func NewStreamPool(numStreams int, memSize int) (*StreamPool, error) {
+	return NewStreamPoolClasses(StreamClass{
+		NumStreams: numStreams,
+		MemSize:    memSize,
+	})
 }
 
-// Destroy all the stream pool's streams
-// This doesn't wait on any work to finish before destroying the streams.
-// If it's a problem in the future I'll have this method empty the channel before destroying the streams.
-func (sm *StreamPool) Destroy() error {
-	return destroyStreams(sm.streams)
+// NewStreamPoolClasses makes a pool with streams of every passed class
+// Use a few big streams for large batches and more small ones for small jobs,
+// instead of sizing every stream for the largest batch.
+func NewStreamPoolClasses(classes ...StreamClass) (*StreamPool, error) {
+	// We should be able to init CUDA here and have it work, right?
+	err := initCuda()
+	if err != nil {
+		return nil, err
+	}
+	var streams []Stream
+	for _, class := range classes {
+		classStreams, err := createStreams(class.NumStreams, class.MemSize)
+		if err != nil {
+			// Don't leak the classes that were already created
+			destroyErr := destroyStreams(streams)
+			if destroyErr != nil {
+				return nil, errors.Wrap(destroyErr, err.Error())
+			}
+			return nil, err
+		}
+		streams = append(streams, classStreams...)
+	}
+
+	return newStreamPool(streams), nil
 }
@@ -0,0 +1,191 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"sort"
+)
+
+// stream_health.go keeps track of the pool's streams' health. A stream that
+// had a CUDA error might be broken, so it's quarantined when it's returned
+// and a new stream is created to take its place.
+
+// streamSlot is a place for a stream in the pool
+// When a broken stream is replaced, the new stream takes over its slot, so
+// the slot's error count covers every stream that has been in it.
+type streamSlot struct {
+	index int
+	// Set when the slot's current stream has had an error
+	failed bool
+	// Number of errors in this slot
+	errors int
+	// Number of times the slot's stream has been replaced
+	replacements int
+	lastErr      error
+}
+
+// StreamMetrics describes one of a pool's streams
+type StreamMetrics struct {
+	// Identifies the stream's place in the pool. A stream that replaces a
+	// broken one takes over its slot.
+	Slot int
+	// Size of the stream's memory, in bytes. Zero while quarantined.
+	MemSize int
+	InUse   bool
+	// Set while a broken stream is being replaced
+	Quarantined bool
+	// Number of CUDA errors on the streams that have been in this slot
+	Errors int
+	// Number of times a broken stream in this slot has been replaced
+	Replacements int
+	// Latest error on this slot's streams, or empty if there hasn't been one
+	LastError string
+}
+
+// PoolMetrics describes the state of a stream pool
+type PoolMetrics struct {
+	// Every stream in the pool, ordered by slot
+	Streams []StreamMetrics
+	// Number of streams that aren't in use
+	Free int
+	// Number of callers waiting for a stream
+	Waiting int
+	// Number of streams that Shrink will retire when they're returned
+	Retiring int
+	// Number of broken streams that couldn't be replaced. The pool has
+	// that many fewer streams than it should.
+	FailedReplacements int
+	Closed             bool
+	// Usage of every tag that streams were taken for or that has limits,
+	// ordered by tag
+	Tags []TagMetrics
+}
+
+// Metrics returns a snapshot of the pool's state and its streams' health
+func (sm *StreamPool) Metrics() PoolMetrics {
+	if !sm.isUsable() {
+		return PoolMetrics{Closed: true}
+	}
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+
+	free := make(map[uintptr]bool, len(sm.free))
+	for i := range sm.free {
+		free[sm.free[i].id()] = true
+	}
+	result := PoolMetrics{
+		Streams:            make([]StreamMetrics, 0, len(sm.streams)+len(sm.quarantined)),
+		Free:               len(sm.free),
+		Waiting:            len(sm.waiters),
+		Retiring:           sm.retiring,
+		FailedReplacements: sm.failedReplacements,
+		Closed:             sm.closed,
+		Tags:               sm.tagMetrics(),
+	}
+	for i := range sm.streams {
+		metrics := sm.slots[sm.streams[i].id()].metrics()
+		metrics.MemSize = sm.streams[i].size()
+		metrics.InUse = !free[sm.streams[i].id()]
+		result.Streams = append(result.Streams, metrics)
+	}
+	for i := range sm.quarantined {
+		metrics := sm.quarantined[i].metrics()
+		metrics.Quarantined = true
+		result.Streams = append(result.Streams, metrics)
+	}
+	sort.Slice(result.Streams, func(i, j int) bool {
+		return result.Streams[i].Slot < result.Streams[j].Slot
+	})
+	return result
+}
+
+// metrics fills in the slot's part of its stream's metrics
+func (slot *streamSlot) metrics() StreamMetrics {
+	result := StreamMetrics{
+		Slot:         slot.index,
+		Errors:       slot.errors,
+		Replacements: slot.replacements,
+	}
+	if slot.lastErr != nil {
+		result.LastError = slot.lastErr.Error()
+	}
+	return result
+}
+
+// markFailed records a CUDA error on s
+// The stream will be quarantined and replaced when it's returned.
+func (sm *StreamPool) markFailed(s Stream, err error) {
+	if !sm.isUsable() {
+		return
+	}
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+	slot := sm.slots[s.id()]
+	if slot == nil {
+		return
+	}
+	slot.failed = true
+	slot.errors++
+	slot.lastErr = err
+	jww.WARN.Printf("Stream in slot %v failed and will be replaced: %v",
+		slot.index, err)
+}
+
+// createReplacement makes the stream that replaces a broken one
+// Tests swap it out to make replacements fail.
+var createReplacement = createStreams
+
+// replaceStream destroys a broken stream that was taken out of the pool and
+// puts a new one of the same size in its slot
+// If that was the last stream that could have been replaced, and the pool has
+// no others, the waiters fail with ErrNoHealthyStreams.
+func (sm *StreamPool) replaceStream(s Stream, slot *streamSlot) {
+	defer sm.replacing.Done()
+	err := destroyStreams([]Stream{s})
+	if err != nil {
+		jww.WARN.Printf("Couldn't destroy broken stream in slot %v: %v",
+			slot.index, err)
+	}
+	replacement, createErr := createReplacement(1, s.size())
+
+	sm.mux.Lock()
+	for i := range sm.quarantined {
+		if sm.quarantined[i] == slot {
+			sm.quarantined = append(sm.quarantined[:i], sm.quarantined[i+1:]...)
+			break
+		}
+	}
+	if createErr != nil {
+		sm.failedReplacements++
+		if sm.noHealthyStreams() {
+			for len(sm.waiters) > 0 {
+				sm.failWaiter(sm.waiters[0], ErrNoHealthyStreams)
+			}
+		}
+		sm.mux.Unlock()
+		jww.ERROR.Printf("Couldn't replace broken stream in slot %v: %v",
+			slot.index, createErr)
+		return
+	}
+	if sm.destroyed {
+		// The pool was destroyed while the replacement was being made
+		sm.mux.Unlock()
+		err = destroyStreams(replacement)
+		if err != nil {
+			jww.WARN.Printf("Couldn't destroy replacement stream: %v", err)
+		}
+		return
+	}
+	slot.failed = false
+	slot.replacements++
+	sm.addStream(replacement[0], slot)
+	sm.updateLargest()
+	sm.dispatch()
+	sm.mux.Unlock()
+}
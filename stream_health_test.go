@@ -0,0 +1,176 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+// A stream that had an error should be replaced instead of handed out again
+func TestStreamPool_ReplaceFailed(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	broken, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.markFailed(broken, errors.New("kernel launch failed"))
+	p.ReturnStream(broken)
+
+	// The replacement is made in the background
+	s, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.id() == broken.id() {
+		t.Error("Broken stream was handed out again")
+	}
+	if s.size() != broken.size() {
+		t.Errorf("Replacement has size %v, expected %v", s.size(),
+			broken.size())
+	}
+
+	metrics := p.Metrics()
+	if len(metrics.Streams) != 1 {
+		t.Fatalf("Pool has %v streams after replacement, expected 1",
+			len(metrics.Streams))
+	}
+	stream := metrics.Streams[0]
+	if stream.Errors != 1 || stream.Replacements != 1 ||
+		stream.LastError != "kernel launch failed" || !stream.InUse ||
+		stream.Quarantined {
+		t.Errorf("Unexpected metrics for replaced stream: %+v", stream)
+	}
+
+	// A healthy return shouldn't replace anything
+	p.ReturnStream(s)
+	s2, err := p.TryTakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2.id() != s.id() {
+		t.Error("Healthy stream was replaced")
+	}
+	p.ReturnStream(s2)
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Waiters should get the replacement for a broken stream
+func TestStreamPool_ReplaceFailedServesWaiter(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	broken, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := make(chan string, 1)
+	startWaiter(t, p, "waiter", order)
+	p.markFailed(broken, errors.New("illegal address"))
+	p.ReturnStream(broken)
+	<-order
+
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Once every stream has broken and couldn't be replaced, waiters and later
+// takes should fail instead of waiting forever
+func TestStreamPool_NoHealthyStreams(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	createReplacement = func(int, int) ([]Stream, error) {
+		return nil, errors.New("out of device memory")
+	}
+	defer func() { createReplacement = createStreams }()
+	broken, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiterErr := make(chan error, 1)
+	go func() {
+		s, err := p.TakeStream()
+		if err == nil {
+			p.ReturnStream(s)
+		}
+		waiterErr <- err
+	}()
+	for p.numWaiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	p.markFailed(broken, errors.New("illegal address"))
+	p.ReturnStream(broken)
+
+	if err = <-waiterErr; err != ErrNoHealthyStreams {
+		t.Errorf("Waiter got %v, expected ErrNoHealthyStreams", err)
+	}
+	if _, err = p.TakeStream(); err != ErrNoHealthyStreams {
+		t.Errorf("TakeStream got %v, expected ErrNoHealthyStreams", err)
+	}
+	if _, err = p.TryTakeStream(); err != ErrNoHealthyStreams {
+		t.Errorf("TryTakeStream got %v, expected ErrNoHealthyStreams", err)
+	}
+	if failed := p.Metrics().FailedReplacements; failed != 1 {
+		t.Errorf("Pool had %v failed replacements, expected 1", failed)
+	}
+
+	// New streams make the pool usable again, and they're as big as the
+	// broken one
+	err = p.Grow(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.TryTakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.size() != broken.size() {
+		t.Errorf("Grew the pool with a stream of size %v, expected %v",
+			s.size(), broken.size())
+	}
+	p.ReturnStream(s)
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Metrics should describe free, used and retiring streams
+func TestStreamPool_Metrics(t *testing.T) {
+	p := newTestStreamPoolClasses(t, StreamClass{NumStreams: 2, MemSize: 4096},
+		StreamClass{NumStreams: 1, MemSize: 8192})
+	s, err := p.TakeStreamFitting(8192)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Shrink(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := p.Metrics()
+	if len(metrics.Streams) != 1 || metrics.Free != 0 ||
+		metrics.Retiring != 0 || metrics.Closed {
+		t.Errorf("Unexpected pool metrics: %+v", metrics)
+	}
+	if metrics.Streams[0].Slot != 2 || metrics.Streams[0].MemSize != 8192 ||
+		!metrics.Streams[0].InUse {
+		t.Errorf("Unexpected stream metrics: %+v", metrics.Streams[0])
+	}
+	p.ReturnStream(s)
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Metrics().Closed {
+		t.Error("Closed pool's metrics should say so")
+	}
+}
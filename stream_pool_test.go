@@ -0,0 +1,574 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+// Makes a pool with small streams, which works in both builds
+func newTestStreamPool(t testing.TB, numStreams int) *StreamPool {
+	streams, err := createStreams(numStreams, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newStreamPool(streams)
+}
+
+// Close should destroy the pool right away if no streams are out
+func TestStreamPool_Close(t *testing.T) {
+	p := newTestStreamPool(t, 2)
+	err := p.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.TakeStream()
+	if err != ErrStreamPoolClosed {
+		t.Errorf("TakeStream after Close returned %v", err)
+	}
+	// Closing or destroying again is fine
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+	err = p.Destroy()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Close should wait for a stream that's out to be returned
+func TestStreamPool_CloseWaits(t *testing.T) {
+	p := newTestStreamPool(t, 2)
+	s, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan error)
+	go func() {
+		closed <- p.Close(context.Background())
+	}()
+	select {
+	case err = <-closed:
+		t.Fatalf("Close returned %v with a stream still out", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// New checkouts should fail while Close is waiting
+	_, err = p.TakeStream()
+	if err != ErrStreamPoolClosed {
+		t.Errorf("TakeStream while closing returned %v", err)
+	}
+
+	p.ReturnStream(s)
+	select {
+	case err = <-closed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't return after the stream was returned")
+	}
+}
+
+// Close should give up when the context expires, and it should be possible to
+// try again afterwards
+func TestStreamPool_CloseTimeout(t *testing.T) {
+	p := newTestStreamPool(t, 3)
+	s, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = p.Close(ctx)
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("Close returned %v, expected a deadline error", err)
+	}
+
+	p.ReturnStream(s)
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Waiting takers should be woken up when the pool closes
+func TestStreamPool_CloseWakesTakers(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	s, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken := make(chan error)
+	go func() {
+		_, err := p.TakeStream()
+		taken <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_ = p.Close(ctx)
+	select {
+	case err = <-taken:
+		if err != ErrStreamPoolClosed {
+			t.Errorf("Waiting TakeStream returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Waiting TakeStream wasn't woken up by Close")
+	}
+	p.ReturnStream(s)
+	err = p.Destroy()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Methods on a nil pool should return errors instead of panicking
+func TestStreamPool_Nil(t *testing.T) {
+	var p *StreamPool
+	_, err := p.TakeStream()
+	if err == nil {
+		t.Error("TakeStream on nil pool should fail")
+	}
+	if p.Close(context.Background()) == nil {
+		t.Error("Close on nil pool should fail")
+	}
+	if p.Destroy() == nil {
+		t.Error("Destroy on nil pool should fail")
+	}
+	p.ReturnStream(Stream{})
+}
+
+// TryTakeStream should only succeed when a stream is free
+func TestStreamPool_TryTakeStream(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	s, err := p.TryTakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.TryTakeStream()
+	if err != ErrNoStreamAvailable {
+		t.Errorf("TryTakeStream on empty pool returned %v", err)
+	}
+	p.ReturnStream(s)
+	s, err = p.TryTakeStream()
+	if err != nil {
+		t.Errorf("TryTakeStream after return failed: %v", err)
+	}
+	p.ReturnStream(s)
+
+	err = p.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.TryTakeStream()
+	if err != ErrStreamPoolClosed {
+		t.Errorf("TryTakeStream on destroyed pool returned %v", err)
+	}
+}
+
+// TakeStreamTimeout should give up, and stop waiting, when it times out
+func TestStreamPool_TakeStreamTimeout(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	s, err := p.TakeStreamTimeout(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.TakeStreamTimeout(10 * time.Millisecond)
+	if err != ErrStreamTimeout {
+		t.Errorf("TakeStreamTimeout on empty pool returned %v", err)
+	}
+	if p.numWaiters() != 0 {
+		t.Error("Timed out waiter was left in the queue")
+	}
+	// The returned stream must still go to the next caller
+	p.ReturnStream(s)
+	_, err = p.TryTakeStream()
+	if err != nil {
+		t.Errorf("Stream returned after a timeout was lost: %v", err)
+	}
+}
+
+// Starts a goroutine that takes a stream from p, and waits until it's queued
+// The goroutine sends its name on order once it has the stream.
+func startWaiter(t *testing.T, p *StreamPool, name string, order chan<- string) {
+	numWaiters := p.numWaiters()
+	go func() {
+		s, err := p.TakeStream()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		order <- name
+		p.ReturnStream(s)
+	}()
+	for p.numWaiters() == numWaiters {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Waiters should be served by priority first, then in arrival order
+func TestStreamPool_Priority(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	s, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only one stream exists, so each waiter returns it before the next
+	// one is served
+	order := make(chan string, 5)
+	startWaiter(t, p.WithPriority(PriorityLow), "low", order)
+	startWaiter(t, p, "normal1", order)
+	startWaiter(t, p.WithPriority(PriorityHigh), "high", order)
+	startWaiter(t, p.WithPriority(PriorityNormal), "normal2", order)
+	startWaiter(t, p.WithPriority(PriorityHigh+1), "higher", order)
+	p.ReturnStream(s)
+
+	expected := []string{"higher", "high", "normal1", "normal2", "low"}
+	for i := range expected {
+		select {
+		case name := <-order:
+			if name != expected[i] {
+				t.Errorf("Waiter %v was %v, expected %v", i, name,
+					expected[i])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Waiter %v was never served", expected[i])
+		}
+	}
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Views made with WithPriority should share their streams with the pool
+func TestStreamPool_WithPriority(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	high := p.WithPriority(PriorityHigh)
+	if high.Priority() != PriorityHigh || p.Priority() != PriorityNormal {
+		t.Error("WithPriority didn't set the view's priority alone")
+	}
+	s, err := high.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.TryTakeStream()
+	if err != ErrNoStreamAvailable {
+		t.Errorf("Stream taken from the view was still free in the pool: %v",
+			err)
+	}
+	p.ReturnStream(s)
+	err = high.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.TakeStream()
+	if err != ErrStreamPoolClosed {
+		t.Errorf("Closing the view didn't close the pool: %v", err)
+	}
+}
+
+// Makes a pool with streams of several sizes, which works in both builds
+func newTestStreamPoolClasses(t testing.TB, classes ...StreamClass) *StreamPool {
+	var streams []Stream
+	for _, class := range classes {
+		classStreams, err := createStreams(class.NumStreams, class.MemSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		streams = append(streams, classStreams...)
+	}
+	return newStreamPool(streams)
+}
+
+// TakeStreamFitting should pick the smallest stream that's big enough
+func TestStreamPool_TakeStreamFitting(t *testing.T) {
+	p := newTestStreamPoolClasses(t, StreamClass{NumStreams: 1, MemSize: 8192},
+		StreamClass{NumStreams: 2, MemSize: 4096})
+
+	small, err := p.TakeStreamFitting(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small.size() != 4096 {
+		t.Errorf("Got a stream of size %v for a small job", small.size())
+	}
+	big, err := p.TakeStreamFitting(5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if big.size() != 8192 {
+		t.Errorf("Got a stream of size %v for a big job", big.size())
+	}
+	p.ReturnStream(big)
+	// Nothing is this big, so the biggest stream should be used
+	huge, err := p.TakeStreamFitting(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if huge.size() != 8192 {
+		t.Errorf("Got a stream of size %v for a huge job", huge.size())
+	}
+	// Small jobs can use big streams if they're all that's left
+	small2, err := p.TakeStreamFitting(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ReturnStream(huge)
+	small3, err := p.TakeStreamFitting(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small3.size() != 8192 {
+		t.Errorf("Got a stream of size %v when only the big one was free",
+			small3.size())
+	}
+	p.ReturnStream(small)
+	p.ReturnStream(small2)
+	p.ReturnStream(small3)
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// A returned stream should go to the first waiter that it's big enough for
+func TestStreamPool_FittingWaiters(t *testing.T) {
+	p := newTestStreamPoolClasses(t, StreamClass{NumStreams: 1, MemSize: 8192},
+		StreamClass{NumStreams: 1, MemSize: 4096})
+	big, err := p.TakeStreamFitting(8192)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := p.TakeStreamFitting(4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bigTaken := make(chan Stream, 1)
+	go func() {
+		s, err := p.TakeStreamFitting(8192)
+		if err != nil {
+			t.Error(err)
+		}
+		bigTaken <- s
+	}()
+	for p.numWaiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	smallTaken := make(chan Stream, 1)
+	go func() {
+		s, err := p.TakeStreamFitting(100)
+		if err != nil {
+			t.Error(err)
+		}
+		smallTaken <- s
+	}()
+	for p.numWaiters() == 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The small stream can't be used by the first waiter, so the second
+	// one should get it
+	p.ReturnStream(small)
+	select {
+	case s := <-smallTaken:
+		if s.size() != 4096 {
+			t.Errorf("Small waiter got a stream of size %v", s.size())
+		}
+		p.ReturnStream(s)
+	case <-bigTaken:
+		t.Fatal("Big waiter got a small stream")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Small waiter wasn't served")
+	}
+	p.ReturnStream(big)
+	select {
+	case s := <-bigTaken:
+		p.ReturnStream(s)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Big waiter wasn't served")
+	}
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Grow should add streams that can be taken right away, and serve waiters
+func TestStreamPool_Grow(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	s, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken := make(chan Stream, 1)
+	go func() {
+		s, err := p.TakeStream()
+		if err != nil {
+			t.Error(err)
+		}
+		taken <- s
+	}()
+	for p.numWaiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	err = p.Grow(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.NumStreams() != 3 {
+		t.Errorf("Pool has %v streams after growing, expected 3",
+			p.NumStreams())
+	}
+	var grown Stream
+	select {
+	case grown = <-taken:
+		if grown.size() != s.size() {
+			t.Errorf("Grown stream has size %v, expected %v", grown.size(),
+				s.size())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Waiter wasn't served by the new streams")
+	}
+	other, err := p.TryTakeStream()
+	if err != nil {
+		t.Errorf("Second new stream wasn't free: %v", err)
+	}
+
+	p.ReturnStream(s)
+	p.ReturnStream(grown)
+	p.ReturnStream(other)
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Grow(1) != ErrStreamPoolClosed {
+		t.Error("Grow should fail after the pool is closed")
+	}
+}
+
+// Shrink should retire free streams right away, and streams in use only once
+// they're returned
+func TestStreamPool_Shrink(t *testing.T) {
+	p := newTestStreamPool(t, 3)
+	s1, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One stream is free, so one of the retirements has to wait
+	err = p.Shrink(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.NumStreams() != 1 {
+		t.Errorf("Pool has %v streams after shrinking, expected 1",
+			p.NumStreams())
+	}
+	_, err = p.TryTakeStream()
+	if err != ErrNoStreamAvailable {
+		t.Errorf("Free stream wasn't retired: %v", err)
+	}
+	err = p.Shrink(1)
+	if err == nil {
+		t.Error("Shrink should refuse to retire the last stream")
+	}
+
+	// The first stream returned is retired instead of being reused
+	p.ReturnStream(s1)
+	_, err = p.TryTakeStream()
+	if err != ErrNoStreamAvailable {
+		t.Errorf("Returned stream wasn't retired: %v", err)
+	}
+	p.ReturnStream(s2)
+	s, err := p.TryTakeStream()
+	if err != nil {
+		t.Errorf("Stream returned after the retirements was lost: %v", err)
+	}
+	p.ReturnStream(s)
+
+	p.mux.Lock()
+	numStreams, numFree := len(p.streams), len(p.free)
+	p.mux.Unlock()
+	if numStreams != 1 || numFree != 1 {
+		t.Errorf("Pool has %v streams and %v free after retiring, "+
+			"expected 1 and 1", numStreams, numFree)
+	}
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Growing and shrinking while streams are in use shouldn't lose any streams
+func TestStreamPool_ResizeWhileInUse(t *testing.T) {
+	p := newTestStreamPool(t, 4)
+	done := make(chan struct{})
+	workersDone := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		go func() {
+			defer func() { workersDone <- struct{}{} }()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s, err := p.TakeStreamTimeout(time.Second)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				time.Sleep(100 * time.Microsecond)
+				p.ReturnStream(s)
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		err := p.Grow(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Shrink(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	for i := 0; i < 8; i++ {
+		<-workersDone
+	}
+	if p.NumStreams() != 4 {
+		t.Errorf("Pool has %v streams, expected 4", p.NumStreams())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := p.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mux.Lock()
+	if len(p.streams) != 4 {
+		t.Errorf("%v streams were left to destroy, expected 4",
+			len(p.streams))
+	}
+	p.mux.Unlock()
+}
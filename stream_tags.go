@@ -0,0 +1,240 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// stream_tags.go accounts for the pool's use by tag, e.g. by round ID, so
+// that rounds sharing a pool can be measured and kept from taking every
+// stream. Pass the ops a view from WithTag to charge their streams to a tag.
+// Kernel launches and slots are counted by the GPU build's kernels. The CPU
+// ops don't take streams, so without the gpu tag only streams taken directly
+// from the pool are counted.
+
+// ErrTagQuotaExceeded is returned when taking a stream for a tag that has
+// used up one of its quotas
+var ErrTagQuotaExceeded = errors.New("tag's quota is used up")
+
+// TagLimits limits what a tag can use. Zero fields are unlimited.
+type TagLimits struct {
+	// Most streams that the tag can have out at once. Callers past the
+	// limit wait for one of the tag's streams to be returned, even if other
+	// streams are free.
+	MaxStreams int
+	// Once the tag has used this much, taking a stream for it fails with
+	// ErrTagQuotaExceeded. Work on streams that are already out isn't
+	// stopped, so a tag can go over its quotas by up to a stream's worth.
+	MaxStreamTime time.Duration
+	MaxSlots      uint64
+	MaxLaunches   uint64
+}
+
+// TagMetrics describes what a tag has used
+type TagMetrics struct {
+	Tag    string
+	Limits TagLimits
+	// Number of the tag's streams that are out now
+	StreamsInUse int
+	// Number of callers waiting for a stream for the tag
+	Waiting int
+	// Total time that the tag's streams were out, not counting streams that
+	// haven't been returned yet
+	StreamTime time.Duration
+	// Number of slots that the tag's kernels ran on
+	Slots uint64
+	// Number of kernels launched on the tag's streams
+	Launches uint64
+}
+
+// tagUsage is the accounting for one tag
+type tagUsage struct {
+	// Updated by kernel launches without holding the pool's lock. First in
+	// the struct so they're aligned for atomic access.
+	slots    uint64
+	launches uint64
+	limits   TagLimits
+	inUse    int
+	// Time that returned streams were out
+	streamTime time.Duration
+}
+
+// streamAccount is shared by the copies of a stream, and says which tag its
+// current checkout is charged to and where its kernel launches are recorded
+type streamAccount struct {
+	// nil while the stream is free or out without a tag
+	usage *tagUsage
+	since time.Time
+	// Recorder of the pool that the stream was taken from, or nil
+	recorder *Recorder
+	// Kernel launch that's being recorded until its results are downloaded
+	launch *kernelLaunch
+	// Set while the stream's secret inputs are being wiped
+	wiping bool
+}
+
+// countLaunch charges a kernel launch on numSlots slots to the stream's tag
+// Wiping secret inputs isn't charged, so a tag's quota doesn't depend on
+// whether its inputs are secret.
+func (a *streamAccount) countLaunch(numSlots int) {
+	if a == nil || a.usage == nil || a.wiping {
+		return
+	}
+	atomic.AddUint64(&a.usage.launches, 1)
+	atomic.AddUint64(&a.usage.slots, uint64(numSlots))
+}
+
+// WithTag returns a view of the pool that charges the streams it takes to
+// tag. Pass the view to the ops instead of the pool to account for them.
+// An empty tag isn't accounted for.
+func (sm *StreamPool) WithTag(tag string) *StreamPool {
+	if sm == nil {
+		return nil
+	}
+	view := *sm
+	view.tag = tag
+	return &view
+}
+
+// Tag returns the tag that this pool charges its streams to
+func (sm *StreamPool) Tag() string {
+	if sm == nil {
+		return ""
+	}
+	return sm.tag
+}
+
+// SetTagLimits limits what tag can use from now on
+func (sm *StreamPool) SetTagLimits(tag string, limits TagLimits) {
+	if !sm.isUsable() || tag == "" {
+		return
+	}
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+	sm.usageFor(tag).limits = limits
+	// A higher concurrency limit can let waiters in
+	sm.dispatch()
+}
+
+// ForgetTag drops tag's accounting and limits, e.g. when its round is over
+// It should only be called once the tag's streams have been returned.
+func (sm *StreamPool) ForgetTag(tag string) {
+	if !sm.isUsable() {
+		return
+	}
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+	delete(sm.tags, tag)
+	sm.dispatch()
+}
+
+// usageFor returns tag's accounting, adding it if it's new
+// sm.mux must be held
+func (sm *StreamPool) usageFor(tag string) *tagUsage {
+	usage, ok := sm.tags[tag]
+	if !ok {
+		usage = &tagUsage{}
+		sm.tags[tag] = usage
+	}
+	return usage
+}
+
+// checkQuota returns ErrTagQuotaExceeded if tag has used up a quota
+// sm.mux must be held
+func (sm *StreamPool) checkQuota(tag string) error {
+	usage, ok := sm.tags[tag]
+	if tag == "" || !ok {
+		return nil
+	}
+	limits := usage.limits
+	switch {
+	case limits.MaxStreamTime > 0 && usage.streamTime >= limits.MaxStreamTime:
+		return errors.Wrapf(ErrTagQuotaExceeded, "%v used %v of stream "+
+			"time", tag, usage.streamTime)
+	case limits.MaxSlots > 0 &&
+		atomic.LoadUint64(&usage.slots) >= limits.MaxSlots:
+		return errors.Wrapf(ErrTagQuotaExceeded, "%v used %v slots", tag,
+			atomic.LoadUint64(&usage.slots))
+	case limits.MaxLaunches > 0 &&
+		atomic.LoadUint64(&usage.launches) >= limits.MaxLaunches:
+		return errors.Wrapf(ErrTagQuotaExceeded, "%v launched %v kernels",
+			tag, atomic.LoadUint64(&usage.launches))
+	}
+	return nil
+}
+
+// tagAtLimit is true if tag has as many streams out as it's allowed
+// sm.mux must be held
+func (sm *StreamPool) tagAtLimit(tag string) bool {
+	usage, ok := sm.tags[tag]
+	return tag != "" && ok && usage.limits.MaxStreams > 0 &&
+		usage.inUse >= usage.limits.MaxStreams
+}
+
+// chargeCheckout charges s to tag until it's returned, and records its
+// kernel launches if the pool is recording
+// sm.mux must be held
+func (sm *StreamPool) chargeCheckout(s Stream, tag string) {
+	if s.account == nil {
+		return
+	}
+	s.account.recorder = sm.recorder
+	if tag == "" {
+		return
+	}
+	usage := sm.usageFor(tag)
+	usage.inUse++
+	s.account.usage = usage
+	s.account.since = time.Now()
+}
+
+// chargeReturn ends the checkout of s
+// sm.mux must be held
+func (sm *StreamPool) chargeReturn(s Stream) {
+	if s.account == nil {
+		return
+	}
+	s.account.recorder = nil
+	s.account.launch = nil
+	if s.account.usage == nil {
+		return
+	}
+	usage := s.account.usage
+	usage.inUse--
+	usage.streamTime += time.Since(s.account.since)
+	s.account.usage = nil
+}
+
+// tagMetrics describes every tag that the pool is accounting for
+// sm.mux must be held
+func (sm *StreamPool) tagMetrics() []TagMetrics {
+	waiting := make(map[string]int)
+	for _, w := range sm.waiters {
+		waiting[w.tag]++
+	}
+	result := make([]TagMetrics, 0, len(sm.tags))
+	for tag, usage := range sm.tags {
+		result = append(result, TagMetrics{
+			Tag:          tag,
+			Limits:       usage.limits,
+			StreamsInUse: usage.inUse,
+			Waiting:      waiting[tag],
+			StreamTime:   usage.streamTime,
+			Slots:        atomic.LoadUint64(&usage.slots),
+			Launches:     atomic.LoadUint64(&usage.launches),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Tag < result[j].Tag
+	})
+	return result
+}
@@ -0,0 +1,68 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"testing"
+)
+
+// Kernels run for a tagged view should be counted for its tag, and its
+// launch quota should stop later ops
+func TestStreamPool_TagLaunches(t *testing.T) {
+	p := newTestPool(t)
+	const n = 100
+	g := makeTestGroup2048()
+	x := randomTestBuffer(g, n, 1)
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	p.SetTagLimits("round", TagLimits{MaxLaunches: 1})
+	round := p.WithTag("round")
+
+	// The stream is too small for every slot, so this launches more than
+	// one kernel
+	err := Mul2Chunk(round, g, x, x, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := getTagMetrics(t, p, "round")
+	if metrics.Slots != n || metrics.Launches < 2 {
+		t.Errorf("Unexpected metrics: %+v", metrics)
+	}
+	err = Mul2Chunk(round, g, x, x, result)
+	if errors.Cause(err) != ErrTagQuotaExceeded {
+		t.Errorf("Expected ErrTagQuotaExceeded, got %v", err)
+	}
+}
+
+// Wiping a secret view's inputs shouldn't count towards its launch quota
+func TestStreamPool_TagLaunches_SecretInputs(t *testing.T) {
+	p := newTestPool(t)
+	const n = 8
+	g := makeTestGroup2048()
+	x := randomTestBuffer(g, n, 1)
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	p.SetTagLimits("secret", TagLimits{MaxLaunches: 2})
+	secret := p.WithTag("secret").WithSecretInputs()
+
+	for i := 0; i < 2; i++ {
+		err := Mul2Chunk(secret, g, x, x, result)
+		if err != nil {
+			t.Fatalf("Op %v: %v", i, err)
+		}
+	}
+	metrics := getTagMetrics(t, p, "secret")
+	if metrics.Slots != 2*n || metrics.Launches != 2 {
+		t.Errorf("Unexpected metrics: %+v", metrics)
+	}
+	err := Mul2Chunk(secret, g, x, x, result)
+	if errors.Cause(err) != ErrTagQuotaExceeded {
+		t.Errorf("Expected ErrTagQuotaExceeded, got %v", err)
+	}
+}
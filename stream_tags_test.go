@@ -0,0 +1,198 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+)
+
+// Returns the metrics of tag, or fails if the pool doesn't have any
+func getTagMetrics(t *testing.T, p *StreamPool, tag string) TagMetrics {
+	for _, metrics := range p.Metrics().Tags {
+		if metrics.Tag == tag {
+			return metrics
+		}
+	}
+	t.Fatalf("No metrics for %v", tag)
+	return TagMetrics{}
+}
+
+// Streams taken from a tagged view should be charged to its tag while
+// they're out, and untagged streams shouldn't be charged at all
+func TestStreamPool_WithTag(t *testing.T) {
+	p := newTestStreamPool(t, 2)
+	defer p.Destroy()
+	round := p.WithTag("round 1").WithPriority(PriorityHigh)
+	if round.Tag() != "round 1" || p.Tag() != "" {
+		t.Error("WithTag didn't set the view's tag alone")
+	}
+
+	s, err := round.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	untagged, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := getTagMetrics(t, p, "round 1")
+	if metrics.StreamsInUse != 1 || metrics.StreamTime != 0 {
+		t.Errorf("Unexpected metrics with a stream out: %+v", metrics)
+	}
+	time.Sleep(10 * time.Millisecond)
+	round.ReturnStream(s)
+	p.ReturnStream(untagged)
+
+	tags := p.Metrics().Tags
+	if len(tags) != 1 {
+		t.Fatalf("Expected metrics for one tag, got %+v", tags)
+	}
+	if tags[0].StreamsInUse != 0 || tags[0].StreamTime < 10*time.Millisecond {
+		t.Errorf("Unexpected metrics after returning: %+v", tags[0])
+	}
+
+	p.ForgetTag("round 1")
+	if len(p.Metrics().Tags) != 0 {
+		t.Error("ForgetTag didn't drop the tag's metrics")
+	}
+}
+
+// A tag at its stream limit should wait, even with free streams, without
+// holding up other tags
+func TestStreamPool_TagMaxStreams(t *testing.T) {
+	p := newTestStreamPool(t, 3)
+	p.SetTagLimits("big", TagLimits{MaxStreams: 1})
+	big := p.WithTag("big")
+	s, err := big.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = big.TryTakeStream()
+	if err != ErrNoStreamAvailable {
+		t.Errorf("TryTakeStream past the limit should fail, got %v", err)
+	}
+
+	order := make(chan string, 1)
+	startWaiter(t, big, "big", order)
+	if waiting := getTagMetrics(t, p, "big").Waiting; waiting != 1 {
+		t.Errorf("Expected 1 waiter for big, got %v", waiting)
+	}
+	small, err := p.WithTag("small").TryTakeStream()
+	if err != nil {
+		t.Fatalf("Tag under its limit wasn't served: %v", err)
+	}
+	select {
+	case <-order:
+		t.Error("Waiter was served past its tag's limit")
+	default:
+	}
+
+	big.ReturnStream(s)
+	select {
+	case <-order:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Waiter wasn't served when its tag's stream was returned")
+	}
+	p.ReturnStream(small)
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Raising a tag's stream limit should serve its waiters
+func TestStreamPool_SetTagLimits_Raise(t *testing.T) {
+	p := newTestStreamPool(t, 2)
+	defer p.Destroy()
+	p.SetTagLimits("round", TagLimits{MaxStreams: 1})
+	round := p.WithTag("round")
+	s, err := round.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer round.ReturnStream(s)
+
+	order := make(chan string, 1)
+	startWaiter(t, round, "round", order)
+	p.SetTagLimits("round", TagLimits{MaxStreams: 2})
+	select {
+	case <-order:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Waiter wasn't served when its tag's limit was raised")
+	}
+}
+
+// Taking a stream for a tag that used up its stream time should fail
+func TestStreamPool_TagQuota(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	defer p.Destroy()
+	p.SetTagLimits("round", TagLimits{MaxStreamTime: time.Millisecond})
+	round := p.WithTag("round")
+	s, err := round.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	round.ReturnStream(s)
+
+	_, err = round.TakeStream()
+	if errors.Cause(err) != ErrTagQuotaExceeded {
+		t.Errorf("Expected ErrTagQuotaExceeded, got %v", err)
+	}
+	_, err = round.TryTakeStream()
+	if errors.Cause(err) != ErrTagQuotaExceeded {
+		t.Errorf("Expected ErrTagQuotaExceeded, got %v", err)
+	}
+	// Other tags aren't affected
+	s, err = p.WithTag("other").TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ReturnStream(s)
+}
+
+// Waiters that were queued before their tag used up its quota should fail
+// instead of getting a stream
+func TestStreamPool_TagQuota_Waiting(t *testing.T) {
+	p := newTestStreamPool(t, 1)
+	defer p.Destroy()
+	p.SetTagLimits("round", TagLimits{MaxStreamTime: time.Millisecond})
+	round := p.WithTag("round")
+	s, err := round.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		s, err := round.TakeStream()
+		if err == nil {
+			round.ReturnStream(s)
+		}
+		errs <- err
+	}()
+	for getTagMetrics(t, p, "round").Waiting == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(2 * time.Millisecond)
+	round.ReturnStream(s)
+	select {
+	case err = <-errs:
+		if errors.Cause(err) != ErrTagQuotaExceeded {
+			t.Errorf("Expected ErrTagQuotaExceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Waiter wasn't failed when its tag's stream was returned")
+	}
+	if getTagMetrics(t, p, "round").Waiting != 0 {
+		t.Error("Failed waiter is still queued")
+	}
+}
@@ -9,7 +9,10 @@
 
 package gpumaths
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestMaxSlots(t *testing.T) {
 	env := gpumaths4096{}
@@ -21,3 +24,226 @@ func TestMaxSlots(t *testing.T) {
 		t.Errorf("The same memory should be able to hold about 2x powm odd slots as elgamal slots, but the actual mem size capacity ratio was %v off from that", offOfHalf/2)
 	}
 }
+
+// Secret exponents shouldn't be left in the stream's memory after an exp
+func TestExpZeroesInputs(t *testing.T) {
+	const numSlots = 8
+	g := makeTestGroup4096()
+	env := chooseEnv(g)
+	x := g.NewIntBuffer(numSlots, g.NewInt(3))
+	y := g.NewIntBuffer(numSlots, g.NewInt(0x5ec2e7))
+	z := g.NewIntBuffer(numSlots, g.NewInt(1))
+
+	streamPool, err := NewStreamPool(1, env.streamSizeContaining(numSlots, kernelPowmOdd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ExpChunk(streamPool, g, x, y, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := streamPool.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, word := range stream.getCpuInputsWords(env, kernelPowmOdd, numSlots) {
+		if word != 0 {
+			t.Fatalf("Input word %v wasn't zeroed", i)
+		}
+	}
+	// The results should still be there
+	if z.Get(0).Cmp(g.Exp(x.Get(0), y.Get(0), g.NewInt(1))) != 0 {
+		t.Error("Zeroing the inputs changed the result")
+	}
+	streamPool.ReturnStream(stream)
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZeroCpuData(t *testing.T) {
+	streams, err := createStreams(1, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range streams[0].cpuData {
+		streams[0].cpuData[i] = 0xa5
+	}
+	streams[0].zeroCpuData()
+	for i, b := range streams[0].cpuData {
+		if b != 0 {
+			t.Fatalf("Byte %v wasn't zeroed", i)
+		}
+	}
+	err = destroyStreams(streams)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Small jobs should be able to run on a small stream while the big stream is
+// busy
+func TestStreamPoolClasses(t *testing.T) {
+	g := makeTestGroup4096()
+	env := chooseEnv(g)
+	bigSize := env.streamSizeContaining(1024, kernelMul2)
+	smallSize := env.streamSizeContaining(16, kernelMul2)
+	streamPool, err := NewStreamPoolClasses(
+		StreamClass{NumStreams: 1, MemSize: bigSize},
+		StreamClass{NumStreams: 1, MemSize: smallSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	big, err := streamPool.TakeStreamFitting(bigSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if big.size() != bigSize {
+		t.Errorf("Big job got a stream of size %v", big.size())
+	}
+
+	x := g.NewIntBuffer(16, g.NewInt(3))
+	y := g.NewIntBuffer(16, g.NewInt(5))
+	z := g.NewIntBuffer(16, g.NewInt(1))
+	err = Mul2Chunk(streamPool, g, x, y, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if z.Get(15).Cmp(g.NewInt(15)) != 0 {
+		t.Error("Mul2Chunk on the small stream gave the wrong result")
+	}
+	streamPool.ReturnStream(big)
+	err = streamPool.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ops on a view with secret inputs should wipe them from the device with an
+// extra kernel, and leave the stream's inputs zeroed
+func TestStreamPool_WithSecretInputs(t *testing.T) {
+	const numSlots = 8
+	g := makeTestGroup2048()
+	env := chooseEnv(g)
+	x := randomTestBuffer(g, numSlots, 1)
+	result := g.NewIntBuffer(numSlots, g.NewInt(1))
+	p := newTestPool(t)
+
+	err := Mul2Chunk(p.WithTag("public"), g, x, x, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if launches := getTagMetrics(t, p, "public").Launches; launches != 1 {
+		t.Errorf("Public inputs shouldn't be wiped, but there were %v launches",
+			launches)
+	}
+
+	secret := p.WithTag("secret").WithSecretInputs()
+	if !secret.SecretInputs() || p.SecretInputs() {
+		t.Error("Only the view should have secret inputs")
+	}
+	err = Mul2Chunk(secret, g, x, x, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if launches := getTagMetrics(t, p, "secret").Launches; launches != 1 {
+		t.Errorf("Wiping secret inputs shouldn't be charged to the tag, but "+
+			"there were %v launches", launches)
+	}
+	expected := g.NewInt(1)
+	for i := uint32(0); i < numSlots; i++ {
+		g.Mul(x.Get(i), x.Get(i), expected)
+		if result.Get(i).Cmp(expected) != 0 {
+			t.Errorf("Wiping the inputs changed the result in slot %v", i)
+		}
+	}
+	stream, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.ReturnStream(stream)
+	for i, word := range stream.getCpuInputsWords(env, kernelMul2, numSlots) {
+		if word != 0 {
+			t.Fatalf("Input word %v wasn't zeroed", i)
+		}
+	}
+}
+
+// The launch that wipes a kernel's inputs should upload over all of them,
+// even when the kernel's inputs take up more of the stream than mul2's can
+func TestWipeLaunch(t *testing.T) {
+	envs := []gpumathsEnv{&gpumathsEnv2048, &gpumathsEnv3200, &gpumathsEnv4096}
+	for _, env := range envs {
+		for name, pk := range pipelineKernels {
+			for _, memSize := range []int{5000, 88888, 1 << 20} {
+				numSlots := env.maxSlots(memSize, pk.kernel)
+				end := env.getConstantsSizeWords(pk.kernel) +
+					env.getInputSizeWords(pk.kernel)*numSlots
+				kernel, slots := wipeLaunch(env, memSize, pk.kernel, numSlots)
+				if slots > env.maxSlots(memSize, kernel) {
+					t.Errorf("%v on %v bytes: wipe launch has %v slots, "+
+						"more than fit", name, memSize, slots)
+				}
+				wipeEnd := env.getConstantsSizeWords(kernel) +
+					env.getInputSizeWords(kernel)*slots
+				if wipeEnd < end {
+					t.Errorf("%v on %v bytes: wipe uploads %v words, but "+
+						"the inputs end at %v", name, memSize, wipeEnd, end)
+				}
+			}
+		}
+	}
+}
+
+// A secret mul3 that fills its stream should have all of its inputs wiped
+// The emulated library doesn't keep device memory, so the wipe launch is
+// checked to upload over the inputs, and the pinned memory to be zeroed.
+func TestStreamPool_WithSecretInputs_FullMul3(t *testing.T) {
+	g := makeTestGroup2048()
+	env := chooseEnv(g)
+	p := newTestPool(t)
+	stream, err := p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	numSlots := env.maxSlots(stream.size(), kernelMul3)
+	kernel, slots := wipeLaunch(env, stream.size(), kernelMul3, numSlots)
+	wipeEnd := env.getConstantsSizeWords(kernel) +
+		env.getInputSizeWords(kernel)*slots
+	inputsEnd := env.getConstantsSizeWords(kernelMul3) +
+		env.getInputSizeWords(kernelMul3)*numSlots
+	if wipeEnd < inputsEnd {
+		t.Errorf("Wipe uploads %v words, but the inputs end at %v", wipeEnd,
+			inputsEnd)
+	}
+	p.ReturnStream(stream)
+
+	x := randomTestBuffer(g, uint32(numSlots), 1)
+	y := randomTestBuffer(g, uint32(numSlots), 2)
+	z := randomTestBuffer(g, uint32(numSlots), 3)
+	result := g.NewIntBuffer(uint32(numSlots), g.NewInt(1))
+	err = Mul3Chunk(p.WithSecretInputs(), g, x, y, z, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := g.NewInt(1)
+	for i := uint32(0); i < uint32(numSlots); i++ {
+		g.Mul(x.Get(i), y.Get(i), expected)
+		g.Mul(expected, z.Get(i), expected)
+		if result.Get(i).Cmp(expected) != 0 {
+			t.Fatalf("Wiping the inputs changed the result in slot %v", i)
+		}
+	}
+	stream, err = p.TakeStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.ReturnStream(stream)
+	for i, word := range stream.getCpuInputsWords(env, kernelMul3, numSlots) {
+		if word != 0 {
+			t.Fatalf("Input word %v of %v wasn't zeroed", i,
+				numSlots*env.getInputSizeWords(kernelMul3))
+		}
+	}
+}
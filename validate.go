@@ -0,0 +1,34 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// validate.go contains the types for running the group membership check
+// against the GPU. The actual GPU call is in validate_gpu.go and is marked to
+// require `-tags gpu` in your build. validate_cpu.go holds the CPU fallback.
+
+// ValidateChunkPrototype checks that every slot of x is in the group.
+// A slot passes if its value lies in [1, p) and, when the subgroup order q is
+// non-nil, x**q = 1 mod p. The indices of the slots that failed either check
+// are returned in ascending order.
+type ValidateChunkPrototype func(p *StreamPool, g *cyclic.Group, q *large.Int,
+	x Operands) ([]uint32, error)
+
+// GetInputSize is how big chunk sizes should be to run the validate operation
+func (ValidateChunkPrototype) GetInputSize() uint32 {
+	return 64
+}
+
+// GetName returns the name of the ValidateChunk operation
+func (ValidateChunkPrototype) GetName() string {
+	return "ValidateChunk"
+}
@@ -0,0 +1,37 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// ValidateChunk checks the group membership of every slot of x on the CPU.
+// Unlike the other stubbed operations, group checks are needed by nodes
+// without a GPU too, so this doesn't return an error.
+var ValidateChunk ValidateChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	q *large.Int, x Operands) ([]uint32, error) {
+	var failed []uint32
+	var exponent *cyclic.Int
+	if q != nil {
+		exponent = g.NewIntFromLargeInt(q)
+	}
+	one := g.NewInt(1)
+	result := g.NewInt(1)
+	for i := uint32(0); i < uint32(x.Len()); i++ {
+		if !g.Inside(x.Get(i).GetLargeInt()) {
+			failed = append(failed, i)
+		} else if exponent != nil && g.Exp(x.Get(i), exponent, result).Cmp(one) != 0 {
+			failed = append(failed, i)
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,49 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build !linux !gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/xx_network/crypto/large"
+	"reflect"
+	"testing"
+)
+
+func TestValidateChunk(t *testing.T) {
+	grp := makeTestGroup4096()
+	q := large.NewInt(1).Sub(grp.GetP(), large.NewInt(1))
+	q.RightShift(q, 1)
+
+	x := grp.NewIntBuffer(6, grp.NewInt(1))
+	// 4 is a quadratic residue, so it's in the subgroup of order q
+	grp.SetLargeInt(x.Get(0), large.NewInt(4))
+	// -1 isn't a quadratic residue because p = 3 mod 4
+	grp.SetLargeInt(x.Get(1), large.NewInt(1).Sub(grp.GetP(), large.NewInt(1)))
+	// SetLargeInt won't write values outside the group, but SetBytes will
+	grp.SetBytes(x.Get(2), []byte{0})
+	grp.SetBytes(x.Get(3), grp.GetP().Bytes())
+	grp.SetLargeInt(x.Get(4), large.NewInt(1))
+	grp.SetLargeInt(x.Get(5), large.NewInt(2))
+
+	failed, err := ValidateChunk(nil, grp, q, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failed, []uint32{1, 2, 3}) {
+		t.Errorf("Unexpected failing slots %v", failed)
+	}
+
+	failed, err = ValidateChunk(nil, grp, nil, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failed, []uint32{2, 3}) {
+		t.Errorf("Only the out of range slots should fail without q, but got %v", failed)
+	}
+}
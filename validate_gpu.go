@@ -0,0 +1,144 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+/*#cgo LDFLAGS: -Llib -lpowmosm75 -Wl,-rpath -Wl,./lib:/opt/xxnetwork/lib
+#cgo CFLAGS: -I./cgbnBindings/powm -I/opt/xxnetwork/include
+#include <powm_odd_export.h>
+*/
+import "C"
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+)
+
+// validate_gpu.go contains the CUDA ops for the validate operation. The range
+// check is done on the host, and the subgroup check x**q = 1 runs on the
+// powm odd kernel. validate(...) performs the actual call into the library
+// and ValidateChunk implements the streaming interface function called by
+// the server implementation.
+
+// ValidateChunk checks the group membership of every slot of x
+// Slots that are out of range are not uploaded for the subgroup check
+var ValidateChunk ValidateChunkPrototype = func(p *StreamPool, g *cyclic.Group,
+	q *large.Int, x Operands) ([]uint32, error) {
+	numSlots := uint32(x.Len())
+
+	// The range check is cheap, so there's no need to do it on the GPU
+	valid := make([]bool, numSlots)
+	for i := uint32(0); i < numSlots; i++ {
+		valid[i] = g.Inside(x.Get(i).GetLargeInt())
+	}
+
+	if q != nil {
+		// Run kernel on the inputs
+		env := chooseEnv(g)
+		stream, err := p.TakeStreamFitting(env.streamSizeContaining(int(numSlots), kernelPowmOdd))
+		if err != nil {
+			return nil, err
+		}
+		defer p.ReturnStream(stream)
+		maxSlotsValidate := uint32(env.maxSlots(len(stream.cpuData), kernelPowmOdd))
+		if numSlots > maxSlotsValidate {
+			jww.WARN.Printf("Running multiple kernels for ValidateChunk. Performance may be degraded")
+		}
+		for i := uint32(0); i < numSlots; i += maxSlotsValidate {
+			sliceEnd := i
+			// Don't slice beyond the end of the input slice
+			if i+maxSlotsValidate <= numSlots {
+				sliceEnd += maxSlotsValidate
+			} else {
+				sliceEnd = numSlots
+			}
+			err := <-validate(g, q, SubRange(x, i, sliceEnd), valid[i:sliceEnd], env, stream)
+			if err != nil {
+				p.markFailed(stream, err)
+				return nil, err
+			}
+		}
+	}
+
+	var failed []uint32
+	for i := uint32(0); i < numSlots; i++ {
+		if !valid[i] {
+			failed = append(failed, i)
+		}
+	}
+	return failed, nil
+}
+
+// validate raises each slot of x to the power of q on the GPU and clears the
+// valid flag of any slot where the result isn't 1
+// Slots that are already invalid have 1 uploaded in their place, so the
+// kernel never sees a value outside the group
+func validate(g *cyclic.Group, q *large.Int, x Operands, valid []bool,
+	env gpumathsEnv, stream Stream) chan error {
+	// Return the result later, when the GPU job finishes
+	resultChan := make(chan error, 1)
+	go func() {
+		// Arrange memory into stream buffers
+		numSlots := uint32(x.Len())
+
+		stream.putConstants(env, kernelPowmOdd, g.GetP().Bits())
+		bnLengthWords := env.getWordLen()
+
+		one := large.Bits{1}
+		inputs := stream.getCpuInputsWords(env, kernelPowmOdd, int(numSlots))
+		forEachSlotRange(numSlots, func(begin, end uint32) {
+			offset := int(begin) * 2 * bnLengthWords
+			for i := begin; i < end; i++ {
+				if valid[i] {
+					putBits(inputs[offset:offset+bnLengthWords], x.Get(i).Bits(), bnLengthWords)
+				} else {
+					putBits(inputs[offset:offset+bnLengthWords], one, bnLengthWords)
+				}
+				offset += bnLengthWords
+				putBits(inputs[offset:offset+bnLengthWords], q.Bits(), bnLengthWords)
+				offset += bnLengthWords
+			}
+		})
+
+		// Upload, run, wait for download
+		err := env.enqueue(stream, kernelPowmOdd, int(numSlots))
+		if err != nil {
+			resultChan <- err
+			return
+		}
+
+		results := stream.getCpuOutputsWords(env, kernelPowmOdd, int(numSlots))
+
+		// Wait on things to finish with Cuda
+		err = get(stream)
+		if err != nil {
+			resultChan <- err
+			return
+		}
+
+		// The result is 1 if the lowest word is 1 and every other word is 0
+		forEachSlotRange(numSlots, func(begin, end uint32) {
+			offset := int(begin) * bnLengthWords
+			for i := begin; i < end; i++ {
+				if results[offset] != 1 {
+					valid[i] = false
+				}
+				for j := offset + 1; j < offset+bnLengthWords; j++ {
+					if results[j] != 0 {
+						valid[i] = false
+					}
+				}
+				offset += bnLengthWords
+			}
+		})
+
+		resultChan <- nil
+	}()
+	return resultChan
+}
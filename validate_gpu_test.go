@@ -0,0 +1,80 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+//+build linux,gpu
+
+package gpumaths
+
+import (
+	"gitlab.com/elixxir/crypto/cyclic"
+	"gitlab.com/xx_network/crypto/large"
+	"reflect"
+	"testing"
+)
+
+// Helper functions shared by tests are located in gpu_test.go
+
+// initValidate makes a buffer where the even slots are quadratic residues,
+// which are in the subgroup of order q = (p-1)/2, and the odd slots aren't
+// The last few slots are out of range entirely
+func initValidate(batchSize uint32) (*cyclic.Group, *large.Int, *cyclic.IntBuffer, []uint32) {
+	grp := initTestGroup()
+	q := large.NewInt(1).Sub(grp.GetP(), large.NewInt(1))
+	q.RightShift(q, 1)
+
+	x := initRandomIntBuffer(grp, batchSize, 42, 0)
+	minusOne := grp.NewIntFromLargeInt(large.NewInt(1).Sub(grp.GetP(), large.NewInt(1)))
+	var expected []uint32
+	for i := uint32(0); i < batchSize-2; i++ {
+		// Squaring always results in a quadratic residue
+		grp.Mul(x.Get(i), x.Get(i), x.Get(i))
+		if i%2 == 1 {
+			// -1 isn't a quadratic residue because p = 3 mod 4
+			grp.Mul(x.Get(i), minusOne, x.Get(i))
+			expected = append(expected, i)
+		}
+	}
+	// SetLargeInt won't write values outside the group, but SetBytes will
+	grp.SetBytes(x.Get(batchSize-2), []byte{0})
+	grp.SetBytes(x.Get(batchSize-1), grp.GetP().Bytes())
+	expected = append(expected, batchSize-2, batchSize-1)
+
+	return grp, q, x, expected
+}
+
+func TestValidateChunk(t *testing.T) {
+	const batchSize = 20
+	grp, q, x, expected := initValidate(batchSize)
+
+	// Ensure correct behavior if the stream doesn't have enough memory to process the whole chunk
+	env := chooseEnv(grp)
+	streamPool, err := NewStreamPool(1, env.streamSizeContaining(batchSize, kernelPowmOdd)/3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := ValidateChunk(streamPool, grp, q, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failed, expected) {
+		t.Errorf("Failing slots didn't match.\nGot:      %v\nExpected: %v", failed, expected)
+	}
+
+	// Without a subgroup order, only the range check should run
+	failed, err = ValidateChunk(streamPool, grp, nil, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failed, []uint32{batchSize - 2, batchSize - 1}) {
+		t.Errorf("Only the out of range slots should fail without q, but got %v", failed)
+	}
+
+	err = streamPool.Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
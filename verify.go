@@ -0,0 +1,173 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"math/rand"
+)
+
+// verify.go checks a sample of the ops' results against CPUOps, to catch a
+// GPU that computes wrong results without reporting an error.
+
+// ErrVerificationFailed is returned by VerifiedOps when a sampled slot's
+// result differs from the CPU's
+var ErrVerificationFailed = errors.New("result didn't match the CPU's")
+
+// VerifiedOps wraps ops so that each call computes a random sample of its
+// slots again with CPUOps, each slot with probability rate
+// A slot that differs fails the call with ErrVerificationFailed. The outputs
+// are still written, since they're written in place. The sampled inputs are
+// copied before ops runs, so outputs can be inputs.
+func VerifiedOps(ops Ops, rate float64) Ops {
+	return Ops{
+		ExpChunk: func(p *StreamPool, g *cyclic.Group, x, y,
+			z Operands) (*cyclic.IntBuffer, error) {
+			slots := sampleSlots(z.Len(), rate)
+			in := gather(g, slots, x, y)
+			result, err := ops.ExpChunk(p, g, x, y, z)
+			if err != nil || len(slots) == 0 {
+				return result, err
+			}
+			expected := newBuffers(g, len(slots), 1)
+			_, err = CPUOps.ExpChunk(nil, g, in[0], in[1], expected[0])
+			if err != nil {
+				return nil, err
+			}
+			return result, compareSlots("ExpChunk", slots, expected, z)
+		},
+		ElGamalChunk: func(p *StreamPool, g *cyclic.Group, key,
+			privateKey Operands, publicCypherKey *cyclic.Int, ecrKey,
+			cypher Operands) error {
+			slots := sampleSlots(ecrKey.Len(), rate)
+			in := gather(g, slots, key, privateKey, ecrKey, cypher)
+			err := ops.ElGamalChunk(p, g, key, privateKey, publicCypherKey,
+				ecrKey, cypher)
+			if err != nil || len(slots) == 0 {
+				return err
+			}
+			err = CPUOps.ElGamalChunk(nil, g, in[0], in[1],
+				publicCypherKey, in[2], in[3])
+			if err != nil {
+				return err
+			}
+			return compareSlots("ElGamalChunk", slots, in[2:], ecrKey,
+				cypher)
+		},
+		RevealChunk: func(p *StreamPool, g *cyclic.Group,
+			publicCypherKey *cyclic.Int, cypher, result Operands) error {
+			slots := sampleSlots(result.Len(), rate)
+			in := gather(g, slots, cypher)
+			err := ops.RevealChunk(p, g, publicCypherKey, cypher, result)
+			if err != nil || len(slots) == 0 {
+				return err
+			}
+			expected := newBuffers(g, len(slots), 1)
+			err = CPUOps.RevealChunk(nil, g, publicCypherKey, in[0],
+				expected[0])
+			if err != nil {
+				return err
+			}
+			return compareSlots("RevealChunk", slots, expected, result)
+		},
+		Mul2Chunk: func(p *StreamPool, g *cyclic.Group, x, y,
+			result Operands) error {
+			return verifyMul2(func() error {
+				return ops.Mul2Chunk(p, g, x, y, result)
+			}, rate, g, x, y, result)
+		},
+		Mul2Slice: func(p *StreamPool, g *cyclic.Group, x *cyclic.IntBuffer,
+			y, result []*cyclic.Int) error {
+			return verifyMul2(func() error {
+				return ops.Mul2Slice(p, g, x, y, result)
+			}, rate, g, x, IntSlice(y), IntSlice(result))
+		},
+		Mul3Chunk: func(p *StreamPool, g *cyclic.Group, x, y, z,
+			result Operands) error {
+			slots := sampleSlots(result.Len(), rate)
+			in := gather(g, slots, x, y, z)
+			err := ops.Mul3Chunk(p, g, x, y, z, result)
+			if err != nil || len(slots) == 0 {
+				return err
+			}
+			expected := newBuffers(g, len(slots), 1)
+			err = CPUOps.Mul3Chunk(nil, g, in[0], in[1], in[2], expected[0])
+			if err != nil {
+				return err
+			}
+			return compareSlots("Mul3Chunk", slots, expected, result)
+		},
+	}
+}
+
+// verifyMul2 runs a product of x and y and checks a sample of its slots, for
+// Mul2Chunk and Mul2Slice
+func verifyMul2(run func() error, rate float64, g *cyclic.Group, x, y,
+	result Operands) error {
+	slots := sampleSlots(result.Len(), rate)
+	in := gather(g, slots, x, y)
+	err := run()
+	if err != nil || len(slots) == 0 {
+		return err
+	}
+	expected := newBuffers(g, len(slots), 1)
+	err = CPUOps.Mul2Chunk(nil, g, in[0], in[1], expected[0])
+	if err != nil {
+		return err
+	}
+	return compareSlots("Mul2Chunk", slots, expected, result)
+}
+
+// sampleSlots picks each of n slots with probability rate
+func sampleSlots(n int, rate float64) []uint32 {
+	var slots []uint32
+	for i := 0; i < n; i++ {
+		if rate >= 1 || rand.Float64() < rate {
+			slots = append(slots, uint32(i))
+		}
+	}
+	return slots
+}
+
+// newBuffers makes num buffers of n ints
+func newBuffers(g *cyclic.Group, n, num int) []*cyclic.IntBuffer {
+	result := make([]*cyclic.IntBuffer, num)
+	for i := range result {
+		result[i] = g.NewIntBuffer(uint32(n), g.NewInt(1))
+	}
+	return result
+}
+
+// gather copies the sampled slots of each operand into a buffer
+// Set copies any value, so exponents can be 0.
+func gather(g *cyclic.Group, slots []uint32,
+	operands ...Operands) []*cyclic.IntBuffer {
+	result := newBuffers(g, len(slots), len(operands))
+	for i, o := range operands {
+		for j, slot := range slots {
+			g.Set(result[i].Get(uint32(j)), o.Get(slot))
+		}
+	}
+	return result
+}
+
+// compareSlots returns ErrVerificationFailed if an output's sampled slots
+// differ from the CPU's results for them
+func compareSlots(name string, slots []uint32, expected []*cyclic.IntBuffer,
+	outputs ...Operands) error {
+	for i, o := range outputs {
+		for j, slot := range slots {
+			if o.Get(slot).Cmp(expected[i].Get(uint32(j))) != 0 {
+				return errors.Wrapf(ErrVerificationFailed,
+					"%v output %v in slot %v", name, i, slot)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+///////////////////////////////////////////////////////////////////////////////
+// Copyright © 2020 xx network SEZC                                          //
+//                                                                           //
+// Use of this source code is governed by a license that can be found in the //
+// LICENSE file                                                              //
+///////////////////////////////////////////////////////////////////////////////
+
+package gpumaths
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/crypto/cryptops"
+	"gitlab.com/elixxir/crypto/cyclic"
+	"testing"
+)
+
+// Correct results should pass verification, even when outputs are inputs
+// and exponents are 0
+func TestVerifiedOps(t *testing.T) {
+	const n = 6
+	g := makeTestGroup2048()
+	ops := VerifiedOps(CPUOps, 1)
+	x := randomTestBuffer(g, n, 1)
+	y := randomTestBuffer(g, n, 2)
+	g.SetUint64(y.Get(2), 0)
+	publicCypherKey := g.NewInt(65537)
+
+	z := g.NewIntBuffer(n, g.NewInt(1))
+	_, err := ops.ExpChunk(nil, g, x, y, z)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ecrKey := randomTestBuffer(g, n, 3)
+	cypher := randomTestBuffer(g, n, 4)
+	expectedEcrKey := ecrKey.DeepCopy()
+	expectedCypher := cypher.DeepCopy()
+	for i := uint32(0); i < n; i++ {
+		cryptops.ElGamal(g, x.Get(i), y.Get(i), publicCypherKey,
+			expectedEcrKey.Get(i), expectedCypher.Get(i))
+	}
+	err = ops.ElGamalChunk(nil, g, x, y, publicCypherKey, ecrKey, cypher)
+	if err != nil {
+		t.Error(err)
+	}
+	for i := uint32(0); i < n; i++ {
+		if ecrKey.Get(i).Cmp(expectedEcrKey.Get(i)) != 0 ||
+			cypher.Get(i).Cmp(expectedCypher.Get(i)) != 0 {
+			t.Errorf("ElGamalChunk was wrong in slot %v", i)
+		}
+	}
+
+	err = ops.RevealChunk(nil, g, publicCypherKey, x, z)
+	if err != nil {
+		t.Error(err)
+	}
+	product := x.DeepCopy()
+	err = ops.Mul2Chunk(nil, g, product, x, product)
+	if err != nil {
+		t.Error(err)
+	}
+	ySlice := make([]*cyclic.Int, n)
+	resultSlice := make([]*cyclic.Int, n)
+	for i := range ySlice {
+		ySlice[i] = y.Get(uint32(i))
+		resultSlice[i] = z.Get(uint32(i))
+	}
+	err = ops.Mul2Slice(nil, g, x, ySlice, resultSlice)
+	if err != nil {
+		t.Error(err)
+	}
+	err = ops.Mul3Chunk(nil, g, product, x, x, product)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// Wrong results should fail verification, unless nothing is sampled
+func TestVerifiedOps_Mismatch(t *testing.T) {
+	const n = 4
+	g := makeTestGroup2048()
+	x := randomTestBuffer(g, n, 1)
+	result := g.NewIntBuffer(n, g.NewInt(1))
+	broken := CPUOps
+	// Writes x instead of x*y into the last slot
+	broken.Mul2Chunk = func(p *StreamPool, g *cyclic.Group, x, y,
+		result Operands) error {
+		err := CPUOps.Mul2Chunk(p, g, x, y, result)
+		last := uint32(result.Len() - 1)
+		g.Set(result.Get(last), x.Get(last))
+		return err
+	}
+
+	err := VerifiedOps(broken, 1).Mul2Chunk(nil, g, x, x, result)
+	if errors.Cause(err) != ErrVerificationFailed {
+		t.Errorf("Wrong product gave %v, expected ErrVerificationFailed",
+			err)
+	}
+	err = VerifiedOps(broken, 0).Mul2Chunk(nil, g, x, x, result)
+	if err != nil {
+		t.Errorf("Nothing should have been verified, but got %v", err)
+	}
+}